@@ -0,0 +1,174 @@
+package kapacitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type AggregateNode struct {
+	node
+	a *pipeline.AggregateNode
+}
+
+// Create a new AggregateNode which computes several aggregates of each batch.
+func newAggregateNode(et *ExecutingTask, n *pipeline.AggregateNode, l *log.Logger) (*AggregateNode, error) {
+	if len(n.Aggregates) == 0 {
+		return nil, errors.New("aggregate node must have at least one aggregate")
+	}
+	names := make(map[string]bool, len(n.Aggregates))
+	for _, a := range n.Aggregates {
+		if names[a.As] {
+			return nil, fmt.Errorf("duplicate aggregate name %q, use .as() to rename the aggregates", a.As)
+		}
+		names[a.As] = true
+	}
+	an := &AggregateNode{
+		node: node{Node: n, et: et, logger: l},
+		a:    n,
+	}
+	an.node.runF = an.runAggregate
+	return an, nil
+}
+
+func (a *AggregateNode) runAggregate([]byte) error {
+	for b, ok := a.ins[0].NextBatch(); ok; b, ok = a.ins[0].NextBatch() {
+		if len(b.Points) == 0 {
+			continue
+		}
+		fields := make(models.Fields, len(a.a.Aggregates))
+		for _, agg := range a.a.Aggregates {
+			if value, ok := a.aggregate(agg, b.Points); ok {
+				fields[agg.As] = value
+			}
+		}
+		p := models.Point{
+			Name:       b.Name,
+			Group:      b.Group,
+			Dimensions: models.SortedKeys(b.Tags),
+			Tags:       b.Tags,
+			Fields:     fields,
+			Time:       b.TMax,
+		}
+		for _, child := range a.outs {
+			err := child.CollectPoint(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compute a single aggregate of the points.
+// Returns false if the aggregate has no value, i.e. no point had the field.
+func (a *AggregateNode) aggregate(agg *pipeline.Aggregate, points []models.BatchPoint) (interface{}, bool) {
+	switch agg.Method {
+	case "count":
+		count := int64(0)
+		for _, p := range points {
+			if _, ok := p.Fields[agg.Field]; ok {
+				count++
+			}
+		}
+		return count, true
+	case "first":
+		for _, p := range points {
+			if v, ok := p.Fields[agg.Field]; ok {
+				return v, true
+			}
+		}
+		return nil, false
+	case "last":
+		for i := len(points) - 1; i >= 0; i-- {
+			if v, ok := points[i].Fields[agg.Field]; ok {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		v, ok := p.Fields[agg.Field]
+		if !ok {
+			continue
+		}
+		f, ok := numToFloat(v)
+		if !ok {
+			a.logger.Printf("E! cannot apply %s to type %T", agg.Method, v)
+			continue
+		}
+		values = append(values, f)
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	switch agg.Method {
+	case "sum":
+		return sum(values), true
+	case "mean":
+		return sum(values) / float64(len(values)), true
+	case "median":
+		sort.Float64s(values)
+		l := len(values)
+		if l%2 == 0 {
+			return (values[l/2-1] + values[l/2]) / 2, true
+		}
+		return values[l/2], true
+	case "min":
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min, true
+	case "max":
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max, true
+	case "spread":
+		min, max := values[0], values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+		return max - min, true
+	case "stddev":
+		// The sample standard deviation is undefined for a single value.
+		if len(values) < 2 {
+			return nil, false
+		}
+		mean := sum(values) / float64(len(values))
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		return math.Sqrt(variance / float64(len(values)-1)), true
+	case "percentile":
+		// Use the nearest rank, the same as influxql.percentile.
+		sort.Float64s(values)
+		i := int(math.Floor(float64(len(values))*agg.Percentile/100.0+0.5)) - 1
+		if i < 0 || i >= len(values) {
+			return nil, false
+		}
+		return values[i], true
+	}
+	a.logger.Printf("E! unknown aggregate %s", agg.Method)
+	return nil, false
+}
+
+func sum(values []float64) float64 {
+	s := 0.0
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
@@ -0,0 +1,255 @@
+package kapacitor
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+const (
+	anomalyField = "is_anomaly"
+	hoursPerWeek = 7 * 24
+)
+
+type AnomalyNode struct {
+	node
+	a *pipeline.AnomalyNode
+
+	// Protects the groups, which are read concurrently by snapshots.
+	mu     sync.Mutex
+	groups map[models.GroupID]*anomalyHistory
+}
+
+// The history of a group, its fields are exported so that it can be encoded in snapshots.
+type anomalyHistory struct {
+	// The last values, oldest first, for the zscore and mad algorithms.
+	Values []float64
+	// The sum and count of the values of each hour, for the seasonal algorithm.
+	// Hours are counted from the Unix epoch.
+	Hours map[int64]anomalyHour
+}
+
+type anomalyHour struct {
+	Sum   float64
+	Count int64
+}
+
+// Create a new AnomalyNode which scores the values of a field.
+func newAnomalyNode(et *ExecutingTask, n *pipeline.AnomalyNode, l *log.Logger) (*AnomalyNode, error) {
+	switch n.Algorithm {
+	case "zscore", "mad", "seasonal":
+	default:
+		return nil, fmt.Errorf("unknown anomaly algorithm %q", n.Algorithm)
+	}
+	if n.History < 2 {
+		return nil, errors.New("anomaly history must be at least 2")
+	}
+	an := &AnomalyNode{
+		node:   node{Node: n, et: et, logger: l},
+		a:      n,
+		groups: make(map[models.GroupID]*anomalyHistory),
+	}
+	an.node.runF = an.runAnomaly
+	return an, nil
+}
+
+func (a *AnomalyNode) runAnomaly(snapshot []byte) error {
+	if len(snapshot) > 0 {
+		err := a.restore(snapshot)
+		if err != nil {
+			return err
+		}
+	}
+	switch a.Wants() {
+	case pipeline.StreamEdge:
+		for p, ok := a.ins[0].NextPoint(); ok; p, ok = a.ins[0].NextPoint() {
+			fields, ok := a.score(p.Group, p.Fields, p.Time)
+			if !ok {
+				continue
+			}
+			p.Fields = fields
+			for _, child := range a.outs {
+				err := child.CollectPoint(p)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case pipeline.BatchEdge:
+		for b, ok := a.ins[0].NextBatch(); ok; b, ok = a.ins[0].NextBatch() {
+			points := make([]models.BatchPoint, 0, len(b.Points))
+			for _, p := range b.Points {
+				fields, ok := a.score(b.Group, p.Fields, p.Time)
+				if !ok {
+					continue
+				}
+				p.Fields = fields
+				points = append(points, p)
+			}
+			b.Points = points
+			for _, child := range a.outs {
+				err := child.CollectBatch(b)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Score the value of the field and add it to the history of the group.
+// Returns the fields with the score and anomaly flag added,
+// or false if the point has no numeric value for the field.
+func (a *AnomalyNode) score(group models.GroupID, fields models.Fields, t time.Time) (models.Fields, bool) {
+	v, ok := fields[a.a.Field]
+	if !ok {
+		a.logger.Printf("E! point missing field %s", a.a.Field)
+		return nil, false
+	}
+	value, ok := numToFloat(v)
+	if !ok {
+		a.logger.Printf("E! cannot apply anomaly to type %T", v)
+		return nil, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h := a.groups[group]
+	if h == nil {
+		h = &anomalyHistory{Hours: make(map[int64]anomalyHour)}
+		a.groups[group] = h
+	}
+
+	var score float64
+	hour := t.Unix() / 3600
+	switch a.a.Algorithm {
+	case "zscore":
+		score = zscore(value, h.Values)
+		h.add(value, int(a.a.History))
+	case "mad":
+		score = madScore(value, h.Values)
+		h.add(value, int(a.a.History))
+	case "seasonal":
+		baseline := make([]float64, 0, a.a.Weeks)
+		for w := int64(1); w <= a.a.Weeks; w++ {
+			if s, ok := h.Hours[hour-w*hoursPerWeek]; ok {
+				baseline = append(baseline, s.Sum/float64(s.Count))
+			}
+		}
+		score = zscore(value, baseline)
+		s, ok := h.Hours[hour]
+		if !ok {
+			// Forget the hours that are no longer part of any baseline.
+			for hr := range h.Hours {
+				if hr < hour-a.a.Weeks*hoursPerWeek {
+					delete(h.Hours, hr)
+				}
+			}
+		}
+		s.Sum += value
+		s.Count++
+		h.Hours[hour] = s
+	}
+
+	fields = fields.Copy()
+	fields[a.a.As] = score
+	fields[anomalyField] = math.Abs(score) > a.a.Threshold
+	return fields, true
+}
+
+// Add a value to the history keeping at most size values.
+func (h *anomalyHistory) add(value float64, size int) {
+	h.Values = append(h.Values, value)
+	if len(h.Values) > size {
+		h.Values = append(h.Values[:0], h.Values[len(h.Values)-size:]...)
+	}
+}
+
+// The number of standard deviations value is from the mean of the history.
+func zscore(value float64, history []float64) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range history {
+		mean += v
+	}
+	mean /= float64(len(history))
+	variance := 0.0
+	for _, v := range history {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(history)-1))
+	if stddev == 0 {
+		return 0
+	}
+	return (value - mean) / stddev
+}
+
+// The modified z-score of value, based on the median absolute deviation of the history.
+func madScore(value float64, history []float64) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	median := medianOf(history)
+	deviations := make([]float64, len(history))
+	for i, v := range history {
+		deviations[i] = math.Abs(v - median)
+	}
+	mad := medianOf(deviations)
+	if mad == 0 {
+		return 0
+	}
+	// Scale the MAD so that the score is comparable to a z-score for normal distributions.
+	return 0.6745 * (value - median) / mad
+}
+
+// Return the median of the values without modifying them.
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	l := len(sorted)
+	if l%2 == 0 {
+		return (sorted[l/2-1] + sorted[l/2]) / 2
+	}
+	return sorted[l/2]
+}
+
+func (a *AnomalyNode) snapshot() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(a.groups)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *AnomalyNode) restore(snapshot []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	groups := make(map[models.GroupID]*anomalyHistory)
+	err := gob.NewDecoder(bytes.NewReader(snapshot)).Decode(&groups)
+	if err != nil {
+		return fmt.Errorf("failed to restore anomaly snapshot: %v", err)
+	}
+	for _, h := range groups {
+		if h.Hours == nil {
+			h.Hours = make(map[int64]anomalyHour)
+		}
+	}
+	a.groups = groups
+	return nil
+}
@@ -0,0 +1,144 @@
+package kapacitor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+func newTestAnomalyNode(algorithm string, weeks int64) *AnomalyNode {
+	return &AnomalyNode{
+		node: node{logger: logger},
+		a: &pipeline.AnomalyNode{
+			Field:     "value",
+			Algorithm: algorithm,
+			History:   100,
+			Weeks:     weeks,
+			Threshold: 3.0,
+			As:        "anomaly_score",
+		},
+		groups: make(map[models.GroupID]*anomalyHistory),
+	}
+}
+
+// Score each value at the given times and return the last score and anomaly flag.
+func scoreValues(t *testing.T, a *AnomalyNode, values []float64, times []time.Time) (float64, bool) {
+	var fields models.Fields
+	for i, v := range values {
+		var ok bool
+		fields, ok = a.score("", models.Fields{"value": v}, times[i])
+		if !ok {
+			t.Fatalf("value %v was not scored", v)
+		}
+	}
+	return fields["anomaly_score"].(float64), fields["is_anomaly"].(bool)
+}
+
+func hourly(n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = time.Unix(int64(i)*3600, 0)
+	}
+	return times
+}
+
+func TestAnomaly_Scores(t *testing.T) {
+	testCases := []struct {
+		algorithm string
+		values    []float64
+		score     float64
+		anomaly   bool
+	}{
+		{
+			algorithm: "zscore",
+			values:    []float64{10},
+			score:     0,
+		},
+		{
+			algorithm: "zscore",
+			values:    []float64{10, 12, 10, 12, 11},
+			score:     0,
+		},
+		{
+			algorithm: "zscore",
+			values:    []float64{10, 12, 10, 12, 17},
+			score:     6 / math.Sqrt(4.0/3.0),
+			anomaly:   true,
+		},
+		{
+			algorithm: "zscore",
+			values:    []float64{10, 10, 10, 10, 17},
+			score:     0,
+		},
+		{
+			// The outlier in the history hides the anomaly from the zscore.
+			algorithm: "zscore",
+			values:    []float64{10, 12, 10, 100, 12, 10, 30},
+			score:     0.118953089787159,
+		},
+		{
+			algorithm: "mad",
+			values:    []float64{10, 12, 10, 100, 12, 10, 30},
+			score:     0.6745 * 19 / 1,
+			anomaly:   true,
+		},
+	}
+	for _, tc := range testCases {
+		a := newTestAnomalyNode(tc.algorithm, 0)
+		score, anomaly := scoreValues(t, a, tc.values, hourly(len(tc.values)))
+		if math.Abs(score-tc.score) > 1e-9 {
+			t.Errorf("%s %v: unexpected score: got %v exp %v", tc.algorithm, tc.values, score, tc.score)
+		}
+		if anomaly != tc.anomaly {
+			t.Errorf("%s %v: unexpected anomaly: got %v exp %v", tc.algorithm, tc.values, anomaly, tc.anomaly)
+		}
+	}
+}
+
+func TestAnomaly_Seasonal(t *testing.T) {
+	a := newTestAnomalyNode("seasonal", 2)
+	week := time.Duration(hoursPerWeek) * time.Hour
+	start := time.Unix(0, 0)
+	times := []time.Time{
+		start,
+		start.Add(week),
+		start.Add(week + time.Hour),
+		// Only the same hour of the last two weeks are used.
+		start.Add(2 * week),
+	}
+	score, anomaly := scoreValues(t, a, []float64{10, 12, 1000, 30}, times)
+	if exp := 19 / math.Sqrt(2); math.Abs(score-exp) > 1e-9 || !anomaly {
+		t.Errorf("unexpected score: got %v %v exp %v true", score, anomaly, exp)
+	}
+
+	// The first week is forgotten once it is no longer part of a baseline.
+	scoreValues(t, a, []float64{30}, []time.Time{start.Add(3*week + time.Hour)})
+	if _, ok := a.groups[""].Hours[0]; ok {
+		t.Error("expected the first week to be forgotten")
+	}
+}
+
+func TestAnomaly_Snapshot(t *testing.T) {
+	values := []float64{10, 12, 10, 12, 17}
+	times := hourly(len(values))
+
+	a := newTestAnomalyNode("zscore", 0)
+	scoreValues(t, a, values[:4], times[:4])
+	snapshot, err := a.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestAnomalyNode("zscore", 0)
+	if err := restored.restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := scoreValues(t, restored, values[4:], times[4:])
+	exp, _ := scoreValues(t, a, values[4:], times[4:])
+	if got != exp {
+		t.Errorf("unexpected score after restore: got %v exp %v", got, exp)
+	}
+}
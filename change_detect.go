@@ -0,0 +1,119 @@
+package kapacitor
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type ChangeDetectNode struct {
+	node
+	c *pipeline.ChangeDetectNode
+
+	// The last emitted values and time of each group.
+	last map[models.GroupID]*changeDetectState
+}
+
+type changeDetectState struct {
+	values map[string]interface{}
+	time   time.Time
+}
+
+// Create a new ChangeDetectNode which emits points only when their fields change.
+func newChangeDetectNode(et *ExecutingTask, n *pipeline.ChangeDetectNode, l *log.Logger) (*ChangeDetectNode, error) {
+	if n.MinDelta < 0 {
+		return nil, errors.New("minDelta must not be negative")
+	}
+	if n.MaxSilence < 0 {
+		return nil, errors.New("maxSilence must not be negative")
+	}
+	cn := &ChangeDetectNode{
+		node: node{Node: n, et: et, logger: l},
+		c:    n,
+		last: make(map[models.GroupID]*changeDetectState),
+	}
+	cn.node.runF = cn.runChangeDetect
+	return cn, nil
+}
+
+func (c *ChangeDetectNode) runChangeDetect([]byte) error {
+	switch c.Wants() {
+	case pipeline.StreamEdge:
+		for p, ok := c.ins[0].NextPoint(); ok; p, ok = c.ins[0].NextPoint() {
+			if c.shouldEmit(p.Group, p.Fields, p.Time) {
+				for _, child := range c.outs {
+					err := child.CollectPoint(p)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case pipeline.BatchEdge:
+		for b, ok := c.ins[0].NextBatch(); ok; b, ok = c.ins[0].NextBatch() {
+			points := make([]models.BatchPoint, 0, len(b.Points))
+			for _, p := range b.Points {
+				if c.shouldEmit(b.Group, p.Fields, p.Time) {
+					points = append(points, p)
+				}
+			}
+			b.Points = points
+			for _, child := range c.outs {
+				err := child.CollectBatch(b)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Determine whether the fields changed since the last emitted point of the group,
+// and if so record them as the last emitted values.
+func (c *ChangeDetectNode) shouldEmit(group models.GroupID, fields models.Fields, t time.Time) bool {
+	state := c.last[group]
+	if state == nil {
+		state = &changeDetectState{values: make(map[string]interface{}, len(c.c.FieldList))}
+		c.last[group] = state
+	}
+	emit := len(state.values) == 0 ||
+		(c.c.MaxSilence > 0 && t.Sub(state.time) >= c.c.MaxSilence)
+	if !emit {
+		for _, field := range c.c.FieldList {
+			value, ok := fields[field]
+			if ok && c.changed(state.values[field], value) {
+				emit = true
+				break
+			}
+		}
+	}
+	if emit {
+		for _, field := range c.c.FieldList {
+			if value, ok := fields[field]; ok {
+				state.values[field] = value
+			}
+		}
+		state.time = t
+	}
+	return emit
+}
+
+// Determine whether value is a change from the previous value.
+// Numeric values must differ by at least MinDelta.
+func (c *ChangeDetectNode) changed(prev, value interface{}) bool {
+	if prev == nil {
+		return true
+	}
+	p, pok := numToFloat(prev)
+	v, vok := numToFloat(value)
+	if pok && vok {
+		delta := math.Abs(v - p)
+		return delta != 0 && delta >= c.c.MinDelta
+	}
+	return prev != value
+}
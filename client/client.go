@@ -0,0 +1,264 @@
+// Package client is a Go client for the HTTP API of the Kapacitor server.
+//
+// Tasks are managed through the versioned /v1/ API,
+// templates, recordings, replays and users through the original API.
+//
+// Every method takes a context to cancel the request,
+// and Config.Timeout limits the duration of each request.
+// Errors returned by the server are of type *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultURL = "http://localhost:9092"
+
+type Config struct {
+	// The URL of the Kapacitor server, defaults to DefaultURL.
+	URL string
+	// The timeout of each request, requests do not time out if zero.
+	Timeout time.Duration
+	// The credentials of the user when authentication is enabled.
+	// A token is used instead of the username and password if set.
+	Username string
+	Password string
+	Token    string
+	// The TLS configuration of HTTPS connections, e.g. the client certificate.
+	TLSConfig *tls.Config
+	// The User-Agent header of the requests.
+	UserAgent string
+}
+
+// Client makes requests to a Kapacitor server, it is safe for concurrent use.
+type Client struct {
+	url        *url.URL
+	username   string
+	password   string
+	token      string
+	userAgent  string
+	httpClient *http.Client
+}
+
+func New(c Config) (*Client, error) {
+	if c.URL == "" {
+		c.URL = DefaultURL
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of URL %s, must be http or https", u.Scheme, c.URL)
+	}
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = "KapacitorClient"
+	}
+	return &Client{
+		url:       u,
+		username:  c.Username,
+		password:  c.Password,
+		token:     c.Token,
+		userAgent: userAgent,
+		httpClient: &http.Client{
+			Timeout: c.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: c.TLSConfig,
+			},
+		},
+	}, nil
+}
+
+// Error is an error response of the Kapacitor server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// IsNotFound reports whether the error is a not found response of the server.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Make a request and decode the JSON response into result if not nil.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body io.Reader, result interface{}) (*http.Response, error) {
+	u := *c.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// Both APIs return errors as JSON objects, only the case of the key differs.
+		e := struct {
+			Error string
+		}{}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		return resp, &Error{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+	if result != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp, fmt.Errorf("invalid response: %s", err)
+		}
+	}
+	return resp, nil
+}
+
+// Encode a value as a JSON request body.
+func jsonBody(v interface{}) (io.Reader, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Ping the server, returning the round trip time and the version of the server.
+func (c *Client) Ping(ctx context.Context) (time.Duration, string, error) {
+	start := time.Now()
+	resp, err := c.do(ctx, "GET", "/ping", nil, nil, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	return time.Since(start), resp.Header.Get("X-Kapacitor-Version"), nil
+}
+
+// Set the log level of the server, one of debug, info, warn, error or off.
+func (c *Client) LogLevel(ctx context.Context, level string) error {
+	params := url.Values{}
+	params.Set("level", level)
+	_, err := c.do(ctx, "POST", "/loglevel", params, nil, nil)
+	return err
+}
+
+// TaskType is the type of a task, template or recording.
+type TaskType int
+
+const (
+	InvalidTaskType TaskType = iota
+	StreamTask
+	BatchTask
+)
+
+func ParseTaskType(s string) (TaskType, error) {
+	switch s {
+	case "stream":
+		return StreamTask, nil
+	case "batch":
+		return BatchTask, nil
+	default:
+		return InvalidTaskType, fmt.Errorf("unknown task type %q, must be stream or batch", s)
+	}
+}
+
+func (t TaskType) String() string {
+	switch t {
+	case StreamTask:
+		return "stream"
+	case BatchTask:
+		return "batch"
+	default:
+		return "invalid"
+	}
+}
+
+func (t TaskType) MarshalText() ([]byte, error) {
+	if t != StreamTask && t != BatchTask {
+		return nil, fmt.Errorf("invalid task type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalJSON decodes the name of the type,
+// or the number the original API uses where 0 is stream and 1 is batch.
+func (t *TaskType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		tt, err := ParseTaskType(s)
+		*t = tt
+		return err
+	}
+	n, err := strconv.Atoi(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid task type %s", data)
+	}
+	*t = TaskType(n + 1)
+	return nil
+}
+
+// A database and retention policy.
+type DBRP struct {
+	Database        string `json:"db"`
+	RetentionPolicy string `json:"rp"`
+}
+
+func (d DBRP) String() string {
+	return fmt.Sprintf("%q.%q", d.Database, d.RetentionPolicy)
+}
+
+// A typed value for a var declared in a template.
+// Durations and regexes are represented as strings.
+type Var struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Format a duration as an InfluxQL duration literal.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	case d%time.Millisecond == 0:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	default:
+		return strconv.FormatInt(int64(d/time.Microsecond), 10) + "u"
+	}
+}
+
+var errNoID = errors.New("must provide an id")
@@ -0,0 +1,522 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/client"
+	"github.com/influxdata/kapacitor/services/auth"
+	"github.com/influxdata/kapacitor/services/deadman"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/replay"
+	"github.com/influxdata/kapacitor/services/task_store"
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/models"
+)
+
+const version = "test-version"
+
+const streamScript = `stream
+	.from().measurement('cpu')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.count('value'))
+`
+
+const templateScript = `var measurement = 'cpu'
+var period = 10s
+
+stream
+	.from().measurement(measurement)
+	.window()
+		.period(period)
+		.every(period)
+	.mapReduce(influxql.count('value'))
+`
+
+var dbrps = []client.DBRP{{Database: "db", RetentionPolicy: "rp"}}
+
+type LogService struct{}
+
+func (l LogService) NewLogger(prefix string, flag int) *log.Logger {
+	return log.New(ioutil.Discard, prefix, flag)
+}
+
+// Server runs the real HTTP handlers of the task, replay and auth services.
+type Server struct {
+	*httptest.Server
+	dir        string
+	TaskMaster *kapacitor.TaskMaster
+	TaskStore  *task_store.Service
+	Replay     *replay.Service
+	Auth       *auth.Service
+}
+
+// Open a server, authentication is enabled if any users are given.
+func OpenServer(t *testing.T, users ...auth.UserConfig) *Server {
+	dir, err := ioutil.TempDir("", "kapacitor-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := LogService{}.NewLogger("", 0)
+	s := &Server{dir: dir}
+
+	s.TaskMaster = kapacitor.NewTaskMaster(LogService{})
+	s.TaskMaster.DeadmanService = deadman.NewService(deadman.NewConfig(), l)
+	if err := s.TaskMaster.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	hc := httpd.NewConfig()
+	hc.AuthEnabled = len(users) > 0
+	h := httpd.NewService(hc, l)
+	h.Handler.PointsWriter = s.TaskMaster
+	h.Handler.Version = version
+	s.TaskMaster.HTTPDService = h
+
+	ac := auth.NewConfig()
+	ac.Dir = dir
+	ac.Users = users
+	s.Auth = auth.NewService(ac, l)
+	s.Auth.HTTPDService = h
+	h.Handler.AuthService = s.Auth
+
+	tc := task_store.NewConfig()
+	tc.Dir = dir
+	s.TaskStore = task_store.NewService(tc, l)
+	s.TaskStore.HTTPDService = h
+	s.TaskStore.TaskMaster = s.TaskMaster
+	s.TaskMaster.TaskStore = s.TaskStore
+
+	rc := replay.NewConfig()
+	rc.Dir = dir
+	s.Replay = replay.NewService(rc, l)
+	s.Replay.HTTPDService = h
+	s.Replay.TaskStore = s.TaskStore
+	s.Replay.TaskMaster = s.TaskMaster
+
+	for _, o := range []interface {
+		Open() error
+	}{s.Auth, s.TaskStore, s.Replay} {
+		if err := o.Open(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Server = httptest.NewServer(h.Handler)
+	return s
+}
+
+func (s *Server) Close() {
+	s.Server.Close()
+	s.Replay.Close()
+	s.TaskStore.Close()
+	s.Auth.Close()
+	s.TaskMaster.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *Server) Client(t *testing.T, c client.Config) *client.Client {
+	c.URL = s.URL
+	cli, err := client.New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func expectStatus(t *testing.T, err error, code int) {
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != code {
+		t.Fatalf("expected error with status %d, got %v", code, err)
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	s := OpenServer(t)
+	defer s.Close()
+	cli := s.Client(t, client.Config{})
+
+	_, v, err := cli.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != version {
+		t.Errorf("unexpected version got %q exp %q", v, version)
+	}
+}
+
+func TestClient_Tasks(t *testing.T) {
+	s := OpenServer(t)
+	defer s.Close()
+	cli := s.Client(t, client.Config{})
+	ctx := context.Background()
+
+	task, err := cli.CreateTask(ctx, client.CreateTaskOptions{
+		ID:     "testTask",
+		Type:   client.StreamTask,
+		DBRPs:  dbrps,
+		Script: streamScript,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID != "testTask" || task.Type != client.StreamTask || task.Status != client.Disabled {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if !reflect.DeepEqual(task.DBRPs, dbrps) {
+		t.Errorf("unexpected dbrps got %v exp %v", task.DBRPs, dbrps)
+	}
+
+	_, err = cli.CreateTask(ctx, client.CreateTaskOptions{
+		ID:     "testTask",
+		Type:   client.StreamTask,
+		DBRPs:  dbrps,
+		Script: streamScript,
+	})
+	expectStatus(t, err, http.StatusConflict)
+
+	if err := cli.Enable(ctx, "testTask"); err != nil {
+		t.Fatal(err)
+	}
+	task, err = cli.Task(ctx, "testTask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != client.Enabled || !task.Executing {
+		t.Errorf("expected task to be enabled and executing %+v", task)
+	}
+
+	// Updating the script reloads the enabled task.
+	updated := streamScript + "\t.httpOut('count')\n"
+	task, err = cli.UpdateTask(ctx, "testTask", client.UpdateTaskOptions{Script: updated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Script != updated || !task.Executing {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if err := cli.Reload(ctx, "testTask"); err != nil {
+		t.Fatal(err)
+	}
+	task, err = cli.Task(ctx, "testTask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Executing {
+		t.Errorf("expected reloaded task to be executing %+v", task)
+	}
+	if err := cli.Disable(ctx, "testTask"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Page through the tasks.
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := cli.CreateTask(ctx, client.CreateTaskOptions{
+			ID:     id,
+			Type:   client.StreamTask,
+			DBRPs:  dbrps,
+			Script: streamScript,
+			Status: client.Enabled,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks, err := cli.ListTasks(ctx, &client.ListTasksOptions{
+		Offset: 1,
+		Limit:  2,
+		Fields: []string{"status"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []client.Task{
+		{ID: "b", Status: client.Enabled},
+		{ID: "c", Status: client.Enabled},
+	}
+	if !reflect.DeepEqual(tasks, exp) {
+		t.Errorf("unexpected tasks got %+v exp %+v", tasks, exp)
+	}
+
+	errs, err := cli.LintTask(ctx, client.LintOptions{
+		Type:   client.StreamTask,
+		Script: "var x = 1\n" + streamScript + "\t.httpOut('count')\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Line != 1 || errs[0].Msg != "var x is declared but never used" {
+		t.Errorf("unexpected lint errors %+v", errs)
+	}
+
+	if err := cli.DeleteTask(ctx, "testTask"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cli.Task(ctx, "testTask")
+	if !client.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err.Error() != "unknown task testTask" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestClient_Templates(t *testing.T) {
+	s := OpenServer(t)
+	defer s.Close()
+	cli := s.Client(t, client.Config{})
+	ctx := context.Background()
+
+	if err := cli.SaveTemplate(ctx, "testTemplate", client.StreamTask, templateScript); err != nil {
+		t.Fatal(err)
+	}
+	_, err := cli.CreateTask(ctx, client.CreateTaskOptions{
+		ID:         "testTask",
+		TemplateID: "testTemplate",
+		DBRPs:      dbrps,
+		Vars: map[string]client.Var{
+			"period": {Type: "duration", Value: "1m"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := cli.Template(ctx, "testTemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Type != client.StreamTask || tmpl.Script != templateScript {
+		t.Errorf("unexpected template %+v", tmpl)
+	}
+	if !reflect.DeepEqual(tmpl.Tasks, []string{"testTask"}) {
+		t.Errorf("unexpected template tasks %v", tmpl.Tasks)
+	}
+	expVars := map[string]client.Var{
+		"measurement": {Type: "string", Value: "cpu"},
+		"period":      {Type: "duration", Value: "10s"},
+	}
+	if !reflect.DeepEqual(tmpl.Vars, expVars) {
+		t.Errorf("unexpected template vars got %v exp %v", tmpl.Vars, expVars)
+	}
+
+	templates, err := cli.ListTemplates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 || templates[0].ID != "testTemplate" || templates[0].Type != client.StreamTask {
+		t.Errorf("unexpected templates %+v", templates)
+	}
+
+	// Templates cannot be deleted while tasks are defined from them.
+	if err := cli.DeleteTemplate(ctx, "testTemplate"); err == nil {
+		t.Error("expected error deleting template with tasks")
+	}
+	if err := cli.DeleteTask(ctx, "testTask"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.DeleteTemplate(ctx, "testTemplate"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cli.Template(ctx, "testTemplate")
+	if !client.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestClient_RecordReplay(t *testing.T) {
+	s := OpenServer(t)
+	defer s.Close()
+	cli := s.Client(t, client.Config{})
+	ctx := context.Background()
+
+	_, err := cli.CreateTask(ctx, client.CreateTaskOptions{
+		ID:     "testTask",
+		Type:   client.StreamTask,
+		DBRPs:  dbrps,
+		Script: streamScript,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := cli.Record(ctx, client.RecordOptions{
+		Kind:     client.StreamRecording,
+		TaskID:   "testTask",
+		Duration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream is recorded until a point is past the duration.
+	points, err := models.ParsePointsString("cpu value=1 0\ncpu value=2 1000000000\ncpu value=3 2000000000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.TaskMaster.WritePoints(&cluster.WritePointsRequest{
+		Database:        "db",
+		RetentionPolicy: "rp",
+		Points:          points,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := cli.GetRecording(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != id || r.Type != client.StreamTask || r.Error != "" {
+		t.Errorf("unexpected recording %+v", r)
+	}
+
+	recordings, err := cli.ListRecordings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 1 || recordings[0].ID != id || recordings[0].Type != client.StreamTask {
+		t.Errorf("unexpected recordings %+v", recordings)
+	}
+
+	err = cli.Replay(ctx, client.ReplayOptions{
+		TaskID:      "testTask",
+		RecordingID: id,
+		Fast:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.DeleteRecording(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	recordings, err = cli.ListRecordings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 0 {
+		t.Errorf("expected no recordings, got %+v", recordings)
+	}
+
+	_, err = cli.Record(ctx, client.RecordOptions{
+		Kind:     client.StreamRecording,
+		TaskID:   "unknown",
+		Duration: time.Second,
+	})
+	if !client.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	s := OpenServer(t, auth.UserConfig{
+		Name:      "admin",
+		Password:  "secret",
+		Privilege: "admin",
+	})
+	defer s.Close()
+	ctx := context.Background()
+
+	// Ping does not require authentication.
+	anonymous := s.Client(t, client.Config{})
+	if _, _, err := anonymous.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err := anonymous.ListTasks(ctx, nil)
+	expectStatus(t, err, http.StatusUnauthorized)
+	_, err = s.Client(t, client.Config{Username: "admin", Password: "wrong"}).ListUsers(ctx)
+	expectStatus(t, err, http.StatusUnauthorized)
+
+	admin := s.Client(t, client.Config{Username: "admin", Password: "secret"})
+	err = admin.SaveUser(ctx, client.SaveUserOptions{
+		Name:      "bob",
+		Password:  "bob-secret",
+		Privilege: "read",
+		Teams:     []string{"ops"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := admin.ListUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []client.User{
+		{Name: "admin", Privilege: "admin"},
+		{Name: "bob", Privilege: "read", Teams: []string{"ops"}},
+	}
+	if !reflect.DeepEqual(users, exp) {
+		t.Errorf("unexpected users got %+v exp %+v", users, exp)
+	}
+
+	bob := s.Client(t, client.Config{Username: "bob", Password: "bob-secret"})
+	token, err := bob.CreateToken(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withToken := s.Client(t, client.Config{Token: token})
+	if _, err := withToken.ListTasks(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = withToken.CreateTask(ctx, client.CreateTaskOptions{
+		ID:     "testTask",
+		Type:   client.StreamTask,
+		DBRPs:  dbrps,
+		Script: streamScript,
+	})
+	expectStatus(t, err, http.StatusForbidden)
+	_, err = withToken.CreateToken(ctx, "admin")
+	expectStatus(t, err, http.StatusForbidden)
+
+	if err := bob.RevokeTokens(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = withToken.ListTasks(ctx, nil)
+	expectStatus(t, err, http.StatusUnauthorized)
+
+	if err := admin.DeleteUser(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = bob.ListTasks(ctx, nil)
+	expectStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	cli, err := client.New(client.Config{URL: ts.URL, Timeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cli.Ping(context.Background()); err == nil {
+		t.Error("expected timeout error")
+	}
+
+	cli, err = client.New(client.Config{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := cli.Ping(ctx); err == nil {
+		t.Error("expected canceled error")
+	}
+}
+
+func TestNew_InvalidURL(t *testing.T) {
+	if _, err := client.New(client.Config{URL: "localhost:9092"}); err == nil {
+		t.Error("expected error for URL without scheme")
+	}
+}
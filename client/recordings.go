@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Recording struct {
+	ID      string
+	Type    TaskType
+	Size    int64
+	Created time.Time
+	// The error of a failed recording.
+	Error string
+	Owner string
+	Team  string
+}
+
+// The kinds of recordings.
+const (
+	// Record the stream of data of a task for a duration.
+	StreamRecording = "stream"
+	// Record the result of the queries of a batch task over a time range.
+	BatchRecording = "batch"
+	// Record the result of an arbitrary query.
+	QueryRecording = "query"
+)
+
+type RecordOptions struct {
+	// One of StreamRecording, BatchRecording or QueryRecording.
+	Kind string
+	// The task to record, for stream and batch recordings.
+	TaskID string
+	// How long to record a stream.
+	Duration time.Duration
+	// The time range of a batch recording,
+	// either from Start or from Past ago until Stop or now.
+	Start time.Time
+	Past  time.Duration
+	Stop  time.Time
+	// The query of a query recording and the type of the tasks to replay it to.
+	Query     string
+	QueryType TaskType
+	// The team to share the recording with, defaults to the team of the task.
+	Team *string
+}
+
+type ReplayOptions struct {
+	TaskID      string
+	RecordingID string
+	// Replay the data as fast as possible instead of in real time.
+	Fast bool
+	// Use the times of the recorded data instead of adjusting them to now.
+	RecordingTime bool
+}
+
+// Start a recording and return its ID, use GetRecording to wait for it to finish.
+func (c *Client) Record(ctx context.Context, o RecordOptions) (string, error) {
+	params := url.Values{}
+	params.Set("type", o.Kind)
+	switch o.Kind {
+	case StreamRecording:
+		params.Set("name", o.TaskID)
+		params.Set("duration", formatDuration(o.Duration))
+	case BatchRecording:
+		params.Set("name", o.TaskID)
+		if !o.Start.IsZero() {
+			params.Set("start", o.Start.Format(time.RFC3339))
+		}
+		if o.Past != 0 {
+			params.Set("past", formatDuration(o.Past))
+		}
+		if !o.Stop.IsZero() {
+			params.Set("stop", o.Stop.Format(time.RFC3339))
+		}
+	case QueryRecording:
+		params.Set("query", o.Query)
+		params.Set("ttype", o.QueryType.String())
+	default:
+		return "", errors.New("unknown recording kind " + strconv.Quote(o.Kind))
+	}
+	if o.Team != nil {
+		params.Set("team", *o.Team)
+	}
+	var r struct {
+		RecordingID string
+	}
+	_, err := c.do(ctx, "POST", "/record", params, nil, &r)
+	return r.RecordingID, err
+}
+
+// Get a recording, waiting for it to finish if it is still running.
+// The Error of the recording is set if it failed.
+func (c *Client) GetRecording(ctx context.Context, id string) (Recording, error) {
+	params := url.Values{}
+	params.Set("id", id)
+	var r Recording
+	if _, err := c.do(ctx, "GET", "/record", params, nil, &r); err != nil {
+		return Recording{}, err
+	}
+	fixRecordingType(&r)
+	return r, nil
+}
+
+// List the recordings with the given IDs, or all recordings if none are given.
+func (c *Client) ListRecordings(ctx context.Context, ids ...string) ([]Recording, error) {
+	params := url.Values{}
+	if len(ids) > 0 {
+		params.Set("rids", strings.Join(ids, ","))
+	}
+	var r struct {
+		Recordings []Recording
+	}
+	if _, err := c.do(ctx, "GET", "/recordings", params, nil, &r); err != nil {
+		return nil, err
+	}
+	for i := range r.Recordings {
+		fixRecordingType(&r.Recordings[i])
+	}
+	return r.Recordings, nil
+}
+
+func (c *Client) DeleteRecording(ctx context.Context, id string) error {
+	if id == "" {
+		return errNoID
+	}
+	params := url.Values{}
+	params.Set("rid", id)
+	_, err := c.do(ctx, "DELETE", "/recording", params, nil, nil)
+	return err
+}
+
+// Replay a recording to a task, returning once the task has processed all the data.
+// The task need not be enabled, it is run in isolation from the other tasks.
+func (c *Client) Replay(ctx context.Context, o ReplayOptions) error {
+	params := url.Values{}
+	params.Set("name", o.TaskID)
+	params.Set("id", o.RecordingID)
+	if o.Fast {
+		params.Set("clock", "fast")
+	}
+	if o.RecordingTime {
+		params.Set("rec-time", "true")
+	}
+	_, err := c.do(ctx, "POST", "/replay", params, nil, nil)
+	return err
+}
+
+// The server omits the type of stream recordings as it is the zero value.
+func fixRecordingType(r *Recording) {
+	if r.Type == InvalidTaskType && r.Error == "" {
+		r.Type = StreamTask
+	}
+}
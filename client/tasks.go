@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const tasksPath = "/v1/tasks"
+
+// TaskStatus is whether a task is enabled.
+type TaskStatus string
+
+const (
+	Enabled  TaskStatus = "enabled"
+	Disabled TaskStatus = "disabled"
+)
+
+type Task struct {
+	ID         string         `json:"id"`
+	TemplateID string         `json:"template-id,omitempty"`
+	Type       TaskType       `json:"type"`
+	DBRPs      []DBRP         `json:"dbrps"`
+	Script     string         `json:"script"`
+	Vars       map[string]Var `json:"vars,omitempty"`
+	Dot        string         `json:"dot"`
+	Status     TaskStatus     `json:"status"`
+	Executing  bool           `json:"executing"`
+	Error      string         `json:"error"`
+	Owner      string         `json:"owner,omitempty"`
+	Team       string         `json:"team,omitempty"`
+}
+
+// The definition of a new task.
+// A task is defined either by a script and type or by a template and its vars.
+type CreateTaskOptions struct {
+	ID         string         `json:"id"`
+	TemplateID string         `json:"template-id,omitempty"`
+	Type       TaskType       `json:"type,omitempty"`
+	DBRPs      []DBRP         `json:"dbrps,omitempty"`
+	Script     string         `json:"script,omitempty"`
+	Vars       map[string]Var `json:"vars,omitempty"`
+	Status     TaskStatus     `json:"status,omitempty"`
+	Team       string         `json:"team,omitempty"`
+}
+
+// The changes to a task, zero fields are left unchanged.
+// Enabled tasks are reloaded if their definition changes.
+type UpdateTaskOptions struct {
+	TemplateID string   `json:"template-id,omitempty"`
+	Type       TaskType `json:"type,omitempty"`
+	DBRPs      []DBRP   `json:"dbrps,omitempty"`
+	Script     string   `json:"script,omitempty"`
+	// The vars replace all the vars of the task if not nil,
+	// an empty map resets the vars to the defaults of the template.
+	Vars   map[string]Var `json:"vars"`
+	Status TaskStatus     `json:"status,omitempty"`
+	// The team to share the task with, an empty team stops sharing the task.
+	Team *string `json:"team,omitempty"`
+}
+
+type ListTasksOptions struct {
+	// The number of tasks to skip.
+	Offset int
+	// The maximum number of tasks to return, the server default if zero.
+	Limit int
+	// The fields of the tasks to return, all fields if empty.
+	Fields []string
+}
+
+// A problem found while linting a TICKscript.
+type LintError struct {
+	Line int
+	Char int
+	Msg  string
+}
+
+// The TICKscript to lint, either a script and its type, an existing task or a template and vars.
+type LintOptions struct {
+	Script     string
+	Type       TaskType
+	TaskID     string
+	TemplateID string
+	Vars       map[string]Var
+}
+
+// The path of a task, it is escaped when the request URL is encoded.
+func taskPath(id string) string {
+	return tasksPath + "/" + id
+}
+
+func (c *Client) CreateTask(ctx context.Context, o CreateTaskOptions) (Task, error) {
+	if o.ID == "" {
+		return Task{}, errNoID
+	}
+	body, err := jsonBody(o)
+	if err != nil {
+		return Task{}, err
+	}
+	var t Task
+	_, err = c.do(ctx, "POST", tasksPath, nil, body, &t)
+	return t, err
+}
+
+func (c *Client) UpdateTask(ctx context.Context, id string, o UpdateTaskOptions) (Task, error) {
+	body, err := jsonBody(o)
+	if err != nil {
+		return Task{}, err
+	}
+	var t Task
+	_, err = c.do(ctx, "PATCH", taskPath(id), nil, body, &t)
+	return t, err
+}
+
+func (c *Client) Task(ctx context.Context, id string) (Task, error) {
+	var t Task
+	_, err := c.do(ctx, "GET", taskPath(id), nil, nil, &t)
+	return t, err
+}
+
+// List a page of the tasks ordered by ID.
+func (c *Client) ListTasks(ctx context.Context, o *ListTasksOptions) ([]Task, error) {
+	params := url.Values{}
+	if o != nil {
+		if o.Offset > 0 {
+			params.Set("offset", strconv.Itoa(o.Offset))
+		}
+		if o.Limit > 0 {
+			params.Set("limit", strconv.Itoa(o.Limit))
+		}
+		if len(o.Fields) > 0 {
+			params.Set("fields", strings.Join(o.Fields, ","))
+		}
+	}
+	var r struct {
+		Tasks []Task `json:"tasks"`
+	}
+	_, err := c.do(ctx, "GET", tasksPath, params, nil, &r)
+	return r.Tasks, err
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id string) error {
+	_, err := c.do(ctx, "DELETE", taskPath(id), nil, nil, nil)
+	return err
+}
+
+// Enable and start a task.
+func (c *Client) Enable(ctx context.Context, id string) error {
+	_, err := c.UpdateTask(ctx, id, UpdateTaskOptions{Status: Enabled})
+	return err
+}
+
+// Disable and stop a task.
+func (c *Client) Disable(ctx context.Context, id string) error {
+	_, err := c.UpdateTask(ctx, id, UpdateTaskOptions{Status: Disabled})
+	return err
+}
+
+// Disable then enable a task.
+func (c *Client) Reload(ctx context.Context, id string) error {
+	if err := c.Disable(ctx, id); err != nil {
+		return err
+	}
+	return c.Enable(ctx, id)
+}
+
+// Lint a TICKscript without defining a task.
+func (c *Client) LintTask(ctx context.Context, o LintOptions) ([]LintError, error) {
+	params := url.Values{}
+	var body io.Reader
+	switch {
+	case o.TemplateID != "":
+		params.Set("template", o.TemplateID)
+		if len(o.Vars) > 0 {
+			var err error
+			body, err = jsonBody(o.Vars)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case o.Script != "":
+		params.Set("type", o.Type.String())
+		body = strings.NewReader(o.Script)
+	case o.TaskID != "":
+		params.Set("name", o.TaskID)
+	default:
+		return nil, errors.New("must provide a script, task or template")
+	}
+	var r struct {
+		Errors []LintError
+	}
+	_, err := c.do(ctx, "POST", "/task/validate", params, body, &r)
+	return r.Errors, err
+}
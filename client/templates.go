@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+type Template struct {
+	ID     string   `json:"Name"`
+	Type   TaskType `json:"Type"`
+	Script string   `json:"TICKscript"`
+	// The vars declared in the template with their default values.
+	Vars map[string]Var `json:"Vars"`
+	// The IDs of the tasks defined from the template.
+	Tasks []string `json:"Tasks"`
+	Owner string   `json:"Owner,omitempty"`
+	Team  string   `json:"Team,omitempty"`
+}
+
+// Create or update a template.
+// The type may be left invalid and the script empty to keep those of an existing template.
+// The tasks defined from the template are validated against it and reloaded if enabled.
+func (c *Client) SaveTemplate(ctx context.Context, id string, tt TaskType, script string) error {
+	if id == "" {
+		return errNoID
+	}
+	params := url.Values{}
+	params.Set("name", id)
+	if tt != InvalidTaskType {
+		params.Set("type", tt.String())
+	}
+	_, err := c.do(ctx, "POST", "/template", params, strings.NewReader(script), nil)
+	return err
+}
+
+func (c *Client) Template(ctx context.Context, id string) (Template, error) {
+	params := url.Values{}
+	params.Set("name", id)
+	var t Template
+	_, err := c.do(ctx, "GET", "/template", params, nil, &t)
+	return t, err
+}
+
+// List the templates with the given IDs, or all templates if none are given.
+// Only the ID and type of the templates are returned.
+func (c *Client) ListTemplates(ctx context.Context, ids ...string) ([]Template, error) {
+	params := url.Values{}
+	if len(ids) > 0 {
+		params.Set("templates", strings.Join(ids, ","))
+	}
+	var r struct {
+		Templates []Template
+	}
+	_, err := c.do(ctx, "GET", "/templates", params, nil, &r)
+	return r.Templates, err
+}
+
+func (c *Client) DeleteTemplate(ctx context.Context, id string) error {
+	if id == "" {
+		return errNoID
+	}
+	params := url.Values{}
+	params.Set("name", id)
+	_, err := c.do(ctx, "DELETE", "/template", params, nil, nil)
+	return err
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+type User struct {
+	Name string
+	// One of read, write or admin.
+	Privilege string
+	Teams     []string
+}
+
+// The changes to a user, a new user must have a password and a privilege.
+// Zero fields are left unchanged.
+type SaveUserOptions struct {
+	Name      string
+	Password  string
+	Privilege string
+	// The teams of the user, an empty non nil slice removes the user from all teams.
+	Teams []string
+}
+
+// Create or update a user, requires the admin privilege.
+func (c *Client) SaveUser(ctx context.Context, o SaveUserOptions) error {
+	if o.Name == "" {
+		return errNoID
+	}
+	params := url.Values{}
+	params.Set("name", o.Name)
+	if o.Privilege != "" {
+		params.Set("privilege", o.Privilege)
+	}
+	if o.Teams != nil {
+		params.Set("teams", strings.Join(o.Teams, ","))
+	}
+	_, err := c.do(ctx, "POST", "/user", params, strings.NewReader(o.Password), nil)
+	return err
+}
+
+// List the users, requires the admin privilege.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var r struct {
+		Users []User
+	}
+	_, err := c.do(ctx, "GET", "/users", nil, nil, &r)
+	return r.Users, err
+}
+
+// Delete a user, requires the admin privilege.
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	if name == "" {
+		return errNoID
+	}
+	params := url.Values{}
+	params.Set("name", name)
+	_, err := c.do(ctx, "DELETE", "/user", params, nil, nil)
+	return err
+}
+
+// Create a token for a user, users may create tokens for themselves
+// and admins for any user.
+func (c *Client) CreateToken(ctx context.Context, name string) (string, error) {
+	params := url.Values{}
+	params.Set("name", name)
+	var r struct {
+		Token string
+	}
+	_, err := c.do(ctx, "POST", "/user/token", params, nil, &r)
+	return r.Token, err
+}
+
+// Revoke all the tokens of a user.
+func (c *Client) RevokeTokens(ctx context.Context, name string) error {
+	params := url.Values{}
+	params.Set("name", name)
+	_, err := c.do(ctx, "DELETE", "/user/token", params, nil, nil)
+	return err
+}
@@ -1,25 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
-	"github.com/influxdata/kapacitor"
-	"github.com/influxdata/kapacitor/services/replay"
-	"github.com/influxdata/kapacitor/services/task_store"
+	"github.com/influxdata/kapacitor/client"
+	"github.com/influxdata/kapacitor/tick"
+	"github.com/influxdb/influxdb/influxql"
 )
 
 // These variables are populated via the Go linker.
@@ -29,12 +31,19 @@ var (
 	branch  string
 )
 
-var defaultURL = "http://localhost:9092"
+var defaultURL = client.DefaultURL
 
 var mainFlags = flag.NewFlagSet("main", flag.ExitOnError)
 var kapacitordURL = mainFlags.String("url", "", "the URL http(s)://host:port of the kapacitord server. Defaults to the KAPACITOR_URL environment variable or "+defaultURL+" if not set.")
+var kapacitordUsername = mainFlags.String("username", "", "the username to authenticate with when authentication is enabled. Defaults to the KAPACITOR_USERNAME environment variable.")
+var kapacitordPassword = mainFlags.String("password", "", "the password to authenticate with. Defaults to the KAPACITOR_PASSWORD environment variable.")
+var kapacitordToken = mainFlags.String("token", "", "a token to authenticate with instead of a username and password. Defaults to the KAPACITOR_TOKEN environment variable.")
+var kapacitordCA = mainFlags.String("ca", "", "path to the PEM encoded CA certificates to verify the kapacitord server with, instead of the system CAs. Defaults to the KAPACITOR_CA environment variable.")
+var kapacitordCert = mainFlags.String("cert", "", "path to the PEM encoded client certificate, when the kapacitord server verifies client certificates. Defaults to the KAPACITOR_CERT environment variable.")
+var kapacitordKey = mainFlags.String("key", "", "path to the PEM encoded private key of the client certificate, if not contained in the certificate file. Defaults to the KAPACITOR_KEY environment variable.")
+var kapacitordInsecure = mainFlags.Bool("insecure-skip-verify", false, "do not verify the certificate of the kapacitord server.")
 
-var kapacitorEndpoint string
+var cli *client.Client
 
 var l = log.New(os.Stderr, "[run] ", log.LstdFlags)
 
@@ -43,19 +52,23 @@ Usage: kapacitor [options] [command] [args]
 
 Commands:
 
-	record   record the result of a query or a snapshot of the current stream data.
-	define   create/update a task.
-	replay   replay a recording to a task.
-	enable   enable and start running a task with live data.
-	disable  stop running a task.
-	reload   reload a running task with an updated task definition.
-	push     publish a task definition to another Kapacitor instance. Not implemented yet.
-	delete   delete a task or a recording.
-	list     list information about tasks or recordings.
-	show     display detailed information about a task.
-	help     get help for a command.
-	level    sets the logging level on the kapacitord server.
-	version  displays the Kapacitor version info.
+	record           record the result of a query or a snapshot of the current stream data.
+	define           create/update a task.
+	define-template  create/update a template.
+	lint             check a task or TICKscript for problems without defining it.
+	replay           replay a recording to a task.
+	enable           enable and start running a task with live data.
+	disable          stop running a task.
+	reload           reload a running task with an updated task definition.
+	push             publish a task definition to another Kapacitor instance. Not implemented yet.
+	delete           delete a task, template or recording.
+	list             list information about tasks, templates or recordings.
+	show             display detailed information about a task.
+	show-template    display detailed information about a template.
+	help             get help for a command.
+	level            sets the logging level on the kapacitord server.
+	user             manage the users of the kapacitord server.
+	version          displays the Kapacitor version info.
 
 Options:
 `
@@ -70,15 +83,30 @@ func main() {
 
 	mainFlags.Parse(os.Args[1:])
 
-	url := defaultURL
-	if urlEnv := os.Getenv("KAPACITOR_URL"); urlEnv != "" {
-		url = urlEnv
-	}
-	if *kapacitordURL != "" {
-		url = *kapacitordURL
+	// Configure TLS
+	tlsConfig, err := newTLSConfig(
+		stringOrEnv(*kapacitordCA, "KAPACITOR_CA"),
+		stringOrEnv(*kapacitordCert, "KAPACITOR_CERT"),
+		stringOrEnv(*kapacitordKey, "KAPACITOR_KEY"),
+		*kapacitordInsecure,
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
 
-	kapacitorEndpoint = url
+	cli, err = client.New(client.Config{
+		URL:       stringOrEnv(*kapacitordURL, "KAPACITOR_URL"),
+		Username:  stringOrEnv(*kapacitordUsername, "KAPACITOR_USERNAME"),
+		Password:  stringOrEnv(*kapacitordPassword, "KAPACITOR_PASSWORD"),
+		Token:     stringOrEnv(*kapacitordToken, "KAPACITOR_TOKEN"),
+		TLSConfig: tlsConfig,
+		UserAgent: "KapacitorCLI/" + version,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 
 	args := mainFlags.Args()
 
@@ -107,6 +135,14 @@ func main() {
 		defineFlags.Parse(args)
 		commandArgs = defineFlags.Args()
 		commandF = doDefine
+	case "define-template":
+		defineTemplateFlags.Parse(args)
+		commandArgs = defineTemplateFlags.Args()
+		commandF = doDefineTemplate
+	case "lint":
+		lintFlags.Parse(args)
+		commandArgs = lintFlags.Args()
+		commandF = doLint
 	case "replay":
 		replayFlags.Parse(args)
 		commandArgs = replayFlags.Args()
@@ -129,9 +165,15 @@ func main() {
 	case "show":
 		commandArgs = args
 		commandF = doShow
+	case "show-template":
+		commandArgs = args
+		commandF = doShowTemplate
 	case "level":
 		commandArgs = args
 		commandF = doLevel
+	case "user":
+		commandArgs = args
+		commandF = doUser
 	case "version":
 		commandArgs = args
 		commandF = doVersion
@@ -140,7 +182,7 @@ func main() {
 		usage()
 	}
 
-	err := commandF(commandArgs)
+	err = commandF(commandArgs)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(3)
@@ -151,17 +193,85 @@ func main() {
 func init() {
 	replayFlags.Usage = replayUsage
 	defineFlags.Usage = defineUsage
+	defineTemplateFlags.Usage = defineTemplateUsage
+	lintFlags.Usage = lintUsage
 	recordFlags.Usage = recordUsage
 }
 
 // helper methods
 
-type responseError struct {
-	Err string `json:"Error"`
+// Return the value if it is set or else the value of the environment variable.
+func stringOrEnv(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
 }
 
-func (e responseError) Error() string {
-	return e.Err
+// Create the TLS configuration from the CA, client certificate and key files.
+// The key defaults to the certificate file.
+func newTLSConfig(ca, cert, key string, insecure bool) (*tls.Config, error) {
+	tc := &tls.Config{InsecureSkipVerify: insecure}
+	if ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+	if cert != "" {
+		if key == "" {
+			key = cert
+		}
+		c, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{c}
+	} else if key != "" {
+		return nil, errors.New("must pass cert flag when passing key flag")
+	}
+	return tc, nil
+}
+
+// Parse an InfluxQL duration literal passed to a flag.
+func parseDuration(flag, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := influxql.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s duration %q", flag, value)
+	}
+	return d, nil
+}
+
+// Parse an RFC3339 time passed to a flag.
+func parseTime(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q, must be RFC3339", flag, value)
+	}
+	return t, nil
+}
+
+// Read a JSON file of vars.
+func readVars(path string) (map[string]client.Var, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]client.Var)
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("invalid vars file %s: %s", path, err)
+	}
+	return vars, nil
 }
 
 // Help
@@ -179,6 +289,10 @@ func doHelp(args []string) error {
 			recordFlags.Usage()
 		case "define":
 			defineFlags.Usage()
+		case "define-template":
+			defineTemplateFlags.Usage()
+		case "lint":
+			lintFlags.Usage()
 		case "replay":
 			replayFlags.Usage()
 		case "enable":
@@ -193,8 +307,12 @@ func doHelp(args []string) error {
 			listUsage()
 		case "show":
 			showUsage()
+		case "show-template":
+			showTemplateUsage()
 		case "level":
 			levelUsage()
+		case "user":
+			userUsage()
 		case "help":
 			helpUsage()
 		case "version":
@@ -223,6 +341,8 @@ var (
 	rtype  = recordFlags.String("type", "", "the type of the recording to save (stream|batch). If recording a query.")
 
 	rdur = recordFlags.String("duration", "", "how long to record the data stream. If recording a stream.")
+
+	rteam = recordFlags.String("team", "", "the team to share the recording with. Defaults to the team of the task.")
 )
 
 func recordUsage() {
@@ -267,55 +387,50 @@ Options:
 
 func doRecord(args []string) error {
 
-	v := url.Values{}
-	v.Add("type", args[0])
+	o := client.RecordOptions{
+		Kind:   args[0],
+		TaskID: *rname,
+		Query:  *rquery,
+	}
+	var err error
 	switch args[0] {
-	case "stream":
-		v.Add("name", *rname)
-		v.Add("duration", *rdur)
-	case "batch":
+	case client.StreamRecording:
+		if o.Duration, err = parseDuration("duration", *rdur); err != nil {
+			return err
+		}
+	case client.BatchRecording:
 		if *rstart != "" && *rpast != "" {
 			return errors.New("cannot set both start and past flags.")
 		}
-		v.Add("name", *rname)
-		v.Add("start", *rstart)
-		v.Add("stop", *rstop)
-		v.Add("past", *rpast)
-	case "query":
-		v.Add("query", *rquery)
-		v.Add("ttype", *rtype)
+		if o.Start, err = parseTime("start", *rstart); err != nil {
+			return err
+		}
+		if o.Stop, err = parseTime("stop", *rstop); err != nil {
+			return err
+		}
+		if o.Past, err = parseDuration("past", *rpast); err != nil {
+			return err
+		}
+	case client.QueryRecording:
+		if o.QueryType, err = client.ParseTaskType(*rtype); err != nil {
+			return err
+		}
 	default:
-		return fmt.Errorf("Unknown record type %q, expected 'stream' or 'query'", args[0])
+		return fmt.Errorf("Unknown record type %q, expected 'stream', 'batch' or 'query'", args[0])
 	}
-	r, err := http.Post(kapacitorEndpoint+"/record?"+v.Encode(), "application/octetstream", nil)
+	if isFlagSet(recordFlags, "team") {
+		o.Team = rteam
+	}
+	id, err := cli.Record(context.Background(), o)
 	if err != nil {
 		return err
 	}
-	defer r.Body.Close()
-
-	// Decode valid response
-	type resp struct {
-		RecordingID string `json:"RecordingID"`
-		Error       string `json:"Error"`
-	}
-	d := json.NewDecoder(r.Body)
-	rp := resp{}
-	d.Decode(&rp)
-	if rp.Error != "" {
-		return errors.New(rp.Error)
-	}
 
-	v = url.Values{}
-	v.Add("id", rp.RecordingID)
-	r, err = http.Get(kapacitorEndpoint + "/record?" + v.Encode())
+	// Wait for the recording to finish
+	ri, err := cli.GetRecording(context.Background(), id)
 	if err != nil {
 		return err
 	}
-	defer r.Body.Close()
-
-	d = json.NewDecoder(r.Body)
-	ri := replay.RecordingInfo{}
-	d.Decode(&ri)
 	if ri.Error != "" {
 		return errors.New(ri.Error)
 	}
@@ -330,6 +445,10 @@ var (
 	dname       = defineFlags.String("name", "", "the task name")
 	dtick       = defineFlags.String("tick", "", "path to the TICKscript")
 	dtype       = defineFlags.String("type", "", "the task type (stream|batch)")
+	dtemplate   = defineFlags.String("template", "", "the name of a template to define the task from, instead of a TICKscript")
+	dvars       = defineFlags.String("vars", "", "path to a JSON file of vars overriding the defaults of the template")
+	dfmt        = defineFlags.Bool("fmt", false, "format the TICKscript before defining the task, the file itself is not modified")
+	dteam       = defineFlags.String("team", "", "the team to share the task with, only the owner of the task may change it. Pass an empty team to stop sharing the task.")
 	ddbrp       = make(dbrps, 0)
 )
 
@@ -337,7 +456,7 @@ func init() {
 	defineFlags.Var(&ddbrp, "dbrp", `a database and retention policy pair of the form "db"."rp" the quotes are optional. The flag can be specified multiple times.`)
 }
 
-type dbrps []kapacitor.DBRP
+type dbrps []client.DBRP
 
 func (d *dbrps) String() string {
 	return fmt.Sprint(*d)
@@ -346,7 +465,7 @@ func (d *dbrps) String() string {
 // Parse string of the form "db"."rp" where the quotes are optional but can include escaped quotes
 // within the strings.
 func (d *dbrps) Set(value string) error {
-	dbrp := kapacitor.DBRP{}
+	dbrp := client.DBRP{}
 	if len(value) == 0 {
 		return fmt.Errorf("dbrp cannot be empty")
 	}
@@ -420,6 +539,32 @@ For example:
 
     NOTE: you must specify all 'dbrp' flags you desire if you wish to modify them.
 
+    If the task is enabled it is reloaded with the updated definition.
+
+    The TICKscript can be formatted into its canonical form before it is defined, see tickfmt.
+
+    $ kapacitor define -name my_task -tick path/to/TICKscript -fmt
+
+    A task can also be defined from a template, see 'kapacitor help define-template'.
+    The vars file is a JSON object mapping var names to their type and value.
+
+    $ kapacitor define -name my_task -template my_template -vars path/to/vars.json -dbrp mydb.myrp
+
+    Example vars file:
+
+    {
+        "crit": {"type": "float", "value": 80},
+        "period": {"type": "duration", "value": "10m"}
+    }
+
+    NOTE: the vars file replaces all vars of the task, omitted vars use the template defaults.
+
+    When authentication is enabled the task is owned by its creator and can be shared with one of their teams.
+
+    $ kapacitor define -name my_task -team ops
+
+    Members of the 'ops' team can now modify, enable and disable the task, see 'kapacitor help user'.
+
 Options:
 
 `
@@ -427,6 +572,16 @@ Options:
 	defineFlags.PrintDefaults()
 }
 
+// Whether the flag was passed, even if its value is empty.
+func isFlagSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
 func doDefine(args []string) error {
 
 	if *dname == "" {
@@ -435,39 +590,235 @@ func doDefine(args []string) error {
 		os.Exit(2)
 	}
 
-	var f io.Reader
+	if *dtemplate != "" && *dtick != "" {
+		fmt.Fprintln(os.Stderr, "Cannot pass both tick and template flags.")
+		defineFlags.Usage()
+		os.Exit(2)
+	}
+	if *dvars != "" && *dtemplate == "" {
+		fmt.Fprintln(os.Stderr, "Must pass template flag when passing vars flag.")
+		defineFlags.Usage()
+		os.Exit(2)
+	}
+	if *dfmt && *dtick == "" {
+		fmt.Fprintln(os.Stderr, "Must pass tick flag when passing fmt flag.")
+		defineFlags.Usage()
+		os.Exit(2)
+	}
+
+	o := client.UpdateTaskOptions{
+		TemplateID: *dtemplate,
+		DBRPs:      ddbrp,
+	}
 	if *dtick != "" {
+		script, err := ioutil.ReadFile(*dtick)
+		if err != nil {
+			return err
+		}
+		o.Script = string(script)
+		if *dfmt {
+			o.Script, err = tick.Format(o.Script)
+			if err != nil {
+				if e, ok := err.(*tick.Error); ok {
+					e.Name = *dtick
+				}
+				return err
+			}
+		}
+	}
+	if *dvars != "" {
+		var err error
+		o.Vars, err = readVars(*dvars)
+		if err != nil {
+			return err
+		}
+	}
+	if *dtype != "" {
 		var err error
-		f, err = os.Open(*dtick)
+		o.Type, err = client.ParseTaskType(*dtype)
 		if err != nil {
 			return err
 		}
 	}
-	v := url.Values{}
-	v.Add("name", *dname)
-	v.Add("type", *dtype)
-	if len(ddbrp) > 0 {
-		b, err := json.Marshal(ddbrp)
+	if isFlagSet(defineFlags, "team") {
+		o.Team = dteam
+	}
+
+	// Update the task if it exists or else create it.
+	ctx := context.Background()
+	_, err := cli.Task(ctx, *dname)
+	switch {
+	case err == nil:
+		_, err = cli.UpdateTask(ctx, *dname, o)
+	case client.IsNotFound(err):
+		_, err = cli.CreateTask(ctx, client.CreateTaskOptions{
+			ID:         *dname,
+			TemplateID: o.TemplateID,
+			Type:       o.Type,
+			DBRPs:      o.DBRPs,
+			Script:     o.Script,
+			Vars:       o.Vars,
+			Team:       *dteam,
+		})
+	}
+	return err
+}
+
+// Define Template
+var (
+	defineTemplateFlags = flag.NewFlagSet("define-template", flag.ExitOnError)
+	dtname              = defineTemplateFlags.String("name", "", "the template name")
+	dttick              = defineTemplateFlags.String("tick", "", "path to the TICKscript")
+	dttype              = defineTemplateFlags.String("type", "", "the template type (stream|batch)")
+)
+
+func defineTemplateUsage() {
+	var u = `Usage: kapacitor define-template [options]
+
+Create or update a template.
+
+A template is a TICKscript whose var declarations with literal values
+can be overridden by each task defined from the template.
+
+For example:
+
+    var crit = 80
+    var period = 10m
+
+    stream
+        .from().measurement('cpu')
+        .window().period(period).every(period)
+        .mapReduce(influxql.mean('value'))
+        .alert().crit(lambda: "mean" > crit)
+
+    $ kapacitor define-template -name my_template -tick path/to/TICKscript -type stream
+
+If an option is absent it will be left unmodified.
+Updating a template redefines all tasks defined from it and reloads those that are enabled.
+
+When authentication is enabled only the owner of a template may update or delete it,
+and only while they may modify every task defined from it.
+
+Options:
+
+`
+	fmt.Fprintln(os.Stderr, u)
+	defineTemplateFlags.PrintDefaults()
+}
+
+func doDefineTemplate(args []string) error {
+
+	if *dtname == "" {
+		fmt.Fprintln(os.Stderr, "Must always pass name flag.")
+		defineTemplateFlags.Usage()
+		os.Exit(2)
+	}
+
+	var script []byte
+	if *dttick != "" {
+		var err error
+		script, err = ioutil.ReadFile(*dttick)
+		if err != nil {
+			return err
+		}
+	}
+	var tt client.TaskType
+	if *dttype != "" {
+		var err error
+		tt, err = client.ParseTaskType(*dttype)
+		if err != nil {
+			return err
+		}
+	}
+	return cli.SaveTemplate(context.Background(), *dtname, tt, string(script))
+}
+
+// Lint
+var (
+	lintFlags = flag.NewFlagSet("lint", flag.ExitOnError)
+	lname     = lintFlags.String("name", "", "the name of an existing task to lint")
+	ltick     = lintFlags.String("tick", "", "path to the TICKscript")
+	ltype     = lintFlags.String("type", "", "the task type (stream|batch)")
+	ltemplate = lintFlags.String("template", "", "the name of a template to lint")
+	lvars     = lintFlags.String("vars", "", "path to a JSON file of vars for the template")
+)
+
+func lintUsage() {
+	var u = `Usage: kapacitor lint [options]
+
+Check a TICKscript for problems without defining a task.
+
+Reports unused vars, type errors in lambda expressions,
+mismatched edge types, nodes that cannot receive data
+and nodes whose output is never used.
+
+For example:
+
+    Lint a TICKscript file.
+
+    $ kapacitor lint -tick path/to/TICKscript -type stream
+
+    Lint an existing task.
+
+    $ kapacitor lint -name my_task
+
+    Lint a template with a set of vars.
+
+    $ kapacitor lint -template my_template -vars path/to/vars.json
+
+Options:
+
+`
+	fmt.Fprintln(os.Stderr, u)
+	lintFlags.PrintDefaults()
+}
+
+func doLint(args []string) error {
+
+	if *ltick == "" && *lname == "" && *ltemplate == "" {
+		fmt.Fprintln(os.Stderr, "Must pass one of the tick, name or template flags.")
+		lintFlags.Usage()
+		os.Exit(2)
+	}
+
+	o := client.LintOptions{
+		TaskID:     *lname,
+		TemplateID: *ltemplate,
+	}
+	if *ltick != "" {
+		script, err := ioutil.ReadFile(*ltick)
+		if err != nil {
+			return err
+		}
+		o.Script = string(script)
+		o.Type, err = client.ParseTaskType(*ltype)
+		if err != nil {
+			return err
+		}
+	} else if *lvars != "" {
+		var err error
+		o.Vars, err = readVars(*lvars)
 		if err != nil {
 			return err
 		}
-		v.Add("dbrps", string(b))
 	}
-	r, err := http.Post(kapacitorEndpoint+"/task?"+v.Encode(), "application/octetstream", f)
+	errs, err := cli.LintTask(context.Background(), o)
 	if err != nil {
 		return err
 	}
-	defer r.Body.Close()
-
-	// Decode valid response
-	type resp struct {
-		Error string `json:"Error"`
+	// Prefix errors with the file or task they refer to.
+	name := *ltick
+	if name == "" {
+		name = *lname
+	}
+	if name == "" {
+		name = *ltemplate
 	}
-	d := json.NewDecoder(r.Body)
-	rp := resp{}
-	d.Decode(&rp)
-	if rp.Error != "" {
-		return errors.New(rp.Error)
+	for _, e := range errs {
+		fmt.Fprintf(os.Stdout, "%s:%d:%d: %s\n", name, e.Line, e.Char, e.Msg)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems", len(errs))
 	}
 	return nil
 }
@@ -500,31 +851,12 @@ Options:
 }
 
 func doReplay(args []string) error {
-
-	v := url.Values{}
-	v.Add("name", *rtname)
-	v.Add("id", *rid)
-	v.Add("rec-time", strconv.FormatBool(*rrec))
-	if *rfast {
-		v.Add("clock", "fast")
-	}
-	r, err := http.Post(kapacitorEndpoint+"/replay?"+v.Encode(), "application/octetstream", nil)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
-
-	// Decode valid response
-	type resp struct {
-		Error string `json:"Error"`
-	}
-	d := json.NewDecoder(r.Body)
-	rp := resp{}
-	d.Decode(&rp)
-	if rp.Error != "" {
-		return errors.New(rp.Error)
-	}
-	return nil
+	return cli.Replay(context.Background(), client.ReplayOptions{
+		TaskID:        *rtname,
+		RecordingID:   *rid,
+		Fast:          *rfast,
+		RecordingTime: *rrec,
+	})
 }
 
 // Enable
@@ -544,23 +876,10 @@ func doEnable(args []string) error {
 	}
 
 	for _, name := range args {
-		v := url.Values{}
-		v.Add("name", name)
-		r, err := http.Post(kapacitorEndpoint+"/enable?"+v.Encode(), "application/octetstream", nil)
+		err := cli.Enable(context.Background(), name)
 		if err != nil {
 			return err
 		}
-		defer r.Body.Close()
-		// Decode valid response
-		type resp struct {
-			Error string `json:"Error"`
-		}
-		d := json.NewDecoder(r.Body)
-		rp := resp{}
-		d.Decode(&rp)
-		if rp.Error != "" {
-			return errors.New(rp.Error)
-		}
 	}
 	return nil
 }
@@ -583,23 +902,10 @@ func doDisable(args []string) error {
 	}
 
 	for _, name := range args {
-		v := url.Values{}
-		v.Add("name", name)
-		r, err := http.Post(kapacitorEndpoint+"/disable?"+v.Encode(), "application/octetstream", nil)
+		err := cli.Disable(context.Background(), name)
 		if err != nil {
 			return err
 		}
-		defer r.Body.Close()
-		// Decode valid response
-		type resp struct {
-			Error string `json:"Error"`
-		}
-		d := json.NewDecoder(r.Body)
-		rp := resp{}
-		d.Decode(&rp)
-		if rp.Error != "" {
-			return errors.New(rp.Error)
-		}
 	}
 	return nil
 }
@@ -646,41 +952,93 @@ func doShow(args []string) error {
 		os.Exit(2)
 	}
 
-	v := url.Values{}
-	v.Add("name", args[0])
-	r, err := http.Get(kapacitorEndpoint + "/task?" + v.Encode())
+	t, err := cli.Task(context.Background(), args[0])
 	if err != nil {
 		return err
 	}
-	defer r.Body.Close()
-	d := json.NewDecoder(r.Body)
-	ti := task_store.TaskInfo{}
-	d.Decode(&ti)
 
-	if ti.Name == "" && ti.Error != "" {
-		return errors.New(ti.Error)
+	fmt.Println("Name:", t.ID)
+	fmt.Println("Error:", t.Error)
+	fmt.Println("Type:", t.Type)
+	fmt.Println("Enabled:", t.Status == client.Enabled)
+	fmt.Println("Executing:", t.Executing)
+	fmt.Println("Databases Retention Policies:", t.DBRPs)
+	if t.Owner != "" {
+		fmt.Println("Owner:", t.Owner)
+	}
+	if t.Team != "" {
+		fmt.Println("Team:", t.Team)
 	}
+	if t.TemplateID != "" {
+		fmt.Println("Template:", t.TemplateID)
+		fmt.Println("Vars:")
+		printVars(t.Vars)
+	}
+	fmt.Printf("TICKscript:\n%s\n\n", t.Script)
+	fmt.Printf("DOT:\n%s\n", t.Dot)
+	return nil
+}
+
+func printVars(vars map[string]client.Var) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	outFmt := "%-30s%-10s%v\n"
+	fmt.Fprintf(os.Stdout, outFmt, "Name", "Type", "Value")
+	for _, name := range names {
+		fmt.Fprintf(os.Stdout, outFmt, name, vars[name].Type, vars[name].Value)
+	}
+}
+
+// Show Template
 
-	fmt.Println("Name:", ti.Name)
-	fmt.Println("Error:", ti.Error)
-	fmt.Println("Type:", ti.Type)
-	fmt.Println("Enabled:", ti.Enabled)
-	fmt.Println("Executing:", ti.Executing)
-	fmt.Println("Databases Retention Policies:", ti.DBRPs)
-	fmt.Printf("TICKscript:\n%s\n\n", ti.TICKscript)
-	fmt.Printf("DOT:\n%s\n", ti.Dot)
+func showTemplateUsage() {
+	var u = `Usage: kapacitor show-template [template name]
+
+	Show details about a specific template.
+`
+	fmt.Fprintln(os.Stderr, u)
+}
+
+func doShowTemplate(args []string) error {
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Must specify one template name")
+		showTemplateUsage()
+		os.Exit(2)
+	}
+
+	t, err := cli.Template(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Name:", t.ID)
+	fmt.Println("Type:", t.Type)
+	if t.Owner != "" {
+		fmt.Println("Owner:", t.Owner)
+	}
+	if t.Team != "" {
+		fmt.Println("Team:", t.Team)
+	}
+	fmt.Println("Tasks:", t.Tasks)
+	fmt.Println("Vars:")
+	printVars(t.Vars)
+	fmt.Printf("TICKscript:\n%s\n", t.Script)
 	return nil
 }
 
 // List
 
 func listUsage() {
-	var u = `Usage: kapacitor list (tasks|recordings) [task name|recording ID]...
+	var u = `Usage: kapacitor list (tasks|templates|recordings) [task name|template name|recording ID]...
 
-List tasks or recordings and their current state.
+List tasks, templates or recordings and their current state.
 
-If no tasks are given then all tasks are listed. Same for recordings.
-If a set of task names or recordings IDs is provided only those entries will be listed.
+If no tasks are given then all tasks are listed. Same for templates and recordings.
+If a set of task names, template names or recordings IDs is provided only those entries will be listed.
 `
 	fmt.Fprintln(os.Stderr, u)
 }
@@ -688,80 +1046,86 @@ If a set of task names or recordings IDs is provided only those entries will be
 func doList(args []string) error {
 
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Must specify 'tasks' or 'recordings'")
+		fmt.Fprintln(os.Stderr, "Must specify 'tasks', 'templates' or 'recordings'")
 		listUsage()
 		os.Exit(2)
 	}
 
+	ctx := context.Background()
 	switch kind := args[0]; kind {
 	case "tasks":
-		tasks := strings.Join(args[1:], ",")
-		v := url.Values{}
-		v.Add("tasks", tasks)
-		r, err := http.Get(kapacitorEndpoint + "/tasks?" + v.Encode())
+		tasks, err := listTasks(ctx, args[1:])
 		if err != nil {
 			return err
 		}
-		defer r.Body.Close()
-		// Decode valid response
-		type resp struct {
-			Error string `json:"Error"`
-			Tasks []struct {
-				Name      string
-				Type      kapacitor.TaskType
-				DBRPs     []kapacitor.DBRP
-				Enabled   bool
-				Executing bool
-			} `json:"Tasks"`
-		}
-		d := json.NewDecoder(r.Body)
-		rp := resp{}
-		d.Decode(&rp)
-		if rp.Error != "" {
-			return errors.New(rp.Error)
-		}
 
 		outFmt := "%-30s%-10v%-10v%-10v%s\n"
 		fmt.Fprintf(os.Stdout, outFmt, "Name", "Type", "Enabled", "Executing", "Databases and Retention Policies")
-		for _, t := range rp.Tasks {
-			fmt.Fprintf(os.Stdout, outFmt, t.Name, t.Type, t.Enabled, t.Executing, t.DBRPs)
+		for _, t := range tasks {
+			fmt.Fprintf(os.Stdout, outFmt, t.ID, t.Type, t.Status == client.Enabled, t.Executing, t.DBRPs)
 		}
-	case "recordings":
-
-		rids := strings.Join(args[1:], ",")
-		v := url.Values{}
-		v.Add("rids", rids)
-		r, err := http.Get(kapacitorEndpoint + "/recordings?" + v.Encode())
+	case "templates":
+		templates, err := cli.ListTemplates(ctx, args[1:]...)
 		if err != nil {
 			return err
 		}
-		defer r.Body.Close()
-		// Decode valid response
-		type resp struct {
-			Error      string         `json:"Error"`
-			Recordings recordingInfos `json:"Recordings"`
+
+		outFmt := "%-30s%-10v\n"
+		fmt.Fprintf(os.Stdout, outFmt, "Name", "Type")
+		for _, t := range templates {
+			fmt.Fprintf(os.Stdout, outFmt, t.ID, t.Type)
 		}
-		d := json.NewDecoder(r.Body)
-		rp := resp{}
-		d.Decode(&rp)
-		if rp.Error != "" {
-			return errors.New(rp.Error)
+	case "recordings":
+		recordings, err := cli.ListRecordings(ctx, args[1:]...)
+		if err != nil {
+			return err
 		}
-		sort.Sort(rp.Recordings)
+		sort.Sort(recordingInfos(recordings))
 
 		outFmt := "%-40s%-8v%-10s%-23s\n"
 		fmt.Fprintf(os.Stdout, "%-40s%-8s%-10s%-23s\n", "ID", "Type", "Size", "Created")
-		for _, r := range rp.Recordings {
+		for _, r := range recordings {
 			fmt.Fprintf(os.Stdout, outFmt, r.ID, r.Type, humanize.Bytes(uint64(r.Size)), r.Created.Local().Format(time.RFC822))
 		}
 	default:
-		return fmt.Errorf("cannot list '%s' did you mean 'tasks' or 'recordings'?", kind)
+		return fmt.Errorf("cannot list '%s' did you mean 'tasks', 'templates' or 'recordings'?", kind)
 	}
 	return nil
 
 }
 
-type recordingInfos []replay.RecordingInfo
+// List the named tasks, or all tasks a page at a time if no names are given.
+func listTasks(ctx context.Context, names []string) ([]client.Task, error) {
+	if len(names) > 0 {
+		tasks := make([]client.Task, len(names))
+		for i, name := range names {
+			t, err := cli.Task(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			tasks[i] = t
+		}
+		return tasks, nil
+	}
+	var tasks []client.Task
+	o := &client.ListTasksOptions{
+		Limit:  100,
+		Fields: []string{"type", "status", "executing", "dbrps"},
+	}
+	for {
+		page, err := cli.ListTasks(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, page...)
+		if len(page) < o.Limit {
+			return tasks, nil
+		}
+		o.Offset += len(page)
+	}
+}
+
+type recordingInfos []client.Recording
 
 func (r recordingInfos) Len() int           { return len(r) }
 func (r recordingInfos) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
@@ -769,63 +1133,174 @@ func (r recordingInfos) Less(i, j int) bool { return r[i].Created.Before(r[j].Cr
 
 // Delete
 func deleteUsage() {
-	var u = `Usage: kapacitor delete (tasks|recordings) [task name|recording ID]...
+	var u = `Usage: kapacitor delete (tasks|templates|recordings) [task name|template name|recording ID]...
 
-	Delete a task or recording.
+	Delete a task, template or recording.
 
 	If a task is enabled it will be disabled and then deleted.
+	A template cannot be deleted while tasks are defined from it.
 `
 	fmt.Fprintln(os.Stderr, u)
 }
 
 func doDelete(args []string) error {
 	if len(args) < 2 {
-		fmt.Fprintln(os.Stderr, "Must pass at least one task name or recording ID")
+		fmt.Fprintln(os.Stderr, "Must pass at least one task name, template name or recording ID")
 		deleteUsage()
 		os.Exit(2)
 	}
 
-	var baseURL string
-	var paramName string
+	var deleteF func(context.Context, string) error
 	switch kind := args[0]; kind {
 	case "tasks":
-		baseURL = kapacitorEndpoint + "/task?"
-		paramName = "name"
+		deleteF = cli.DeleteTask
+	case "templates":
+		deleteF = cli.DeleteTemplate
 	case "recordings":
-		baseURL = kapacitorEndpoint + "/recording?"
-		paramName = "rid"
+		deleteF = cli.DeleteRecording
 	default:
-		return fmt.Errorf("cannot delete '%s' did you mean 'tasks' or 'recordings'?", kind)
+		return fmt.Errorf("cannot delete '%s' did you mean 'tasks', 'templates' or 'recordings'?", kind)
 	}
 
 	for _, arg := range args[1:] {
-		v := url.Values{}
-		v.Add(paramName, arg)
-		req, err := http.NewRequest("DELETE", baseURL+v.Encode(), nil)
+		err := deleteF(context.Background(), arg)
 		if err != nil {
 			return err
 		}
-		client := &http.Client{}
-		r, err := client.Do(req)
+	}
+
+	return nil
+}
+
+// User
+func userUsage() {
+	var u = `Usage: kapacitor user (create|password|privilege|teams|delete|list|token|revoke) [args]
+
+	Manage the users of the kapacitord server.
+	Only admins can manage users, except that users can manage their own tokens.
+
+	kapacitor user create <name> (read|write|admin)
+		Create a user with the privilege, the password is read from stdin.
+	kapacitor user password <name>
+		Change the password of a user, the new password is read from stdin.
+	kapacitor user privilege <name> (read|write|admin)
+		Change the privilege of a user.
+	kapacitor user teams <name> <team,...>
+		Set the teams of a user, pass '' to remove the user from all teams.
+	kapacitor user delete <name>
+		Delete a user and its tokens.
+	kapacitor user list
+		List the users, their privileges and teams.
+	kapacitor user token <name>
+		Create a token for the user, to be used with the -token option.
+	kapacitor user revoke <name>
+		Revoke all tokens of the user.
+
+	Privileges:
+		read:  read tasks, templates, recordings and outputs.
+		write: also define, enable, disable and delete tasks, templates and recordings, and write data.
+		admin: also manage users and the log level.
+
+	Tasks and recordings are owned by the user that created them and can be shared with one of the owner's teams.
+	Members of the team with the write privilege can modify, enable and disable them, other members can only see them.
+	Only the owner can delete them or change their team. Admins can access all tasks and recordings.
+
+Examples:
+
+	$ echo 'secret' | kapacitor -username admin -password admin-secret user create alice write
+
+	Creates the user alice that can modify tasks.
+
+	$ kapacitor -token $(kapacitor -username alice -password secret user token alice) list tasks
+
+	Lists the tasks using a token of alice.
+`
+	fmt.Fprintln(os.Stderr, u)
+}
+
+func doUser(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Must pass a user command")
+		userUsage()
+		os.Exit(2)
+	}
+	command := args[0]
+	args = args[1:]
+	nargs := 1
+	switch command {
+	case "list":
+		nargs = 0
+	case "create", "privilege", "teams":
+		nargs = 2
+	case "password", "delete", "token", "revoke":
+	default:
+		return fmt.Errorf("unknown user command '%s'", command)
+	}
+	if len(args) != nargs {
+		fmt.Fprintf(os.Stderr, "Wrong number of arguments for user %s\n", command)
+		userUsage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "create", "password":
+		o := client.SaveUserOptions{Name: args[0]}
+		if command == "create" {
+			o.Privilege = args[1]
+		}
+		var err error
+		o.Password, err = readPassword()
+		if err != nil {
+			return err
+		}
+		return cli.SaveUser(ctx, o)
+	case "privilege":
+		return cli.SaveUser(ctx, client.SaveUserOptions{Name: args[0], Privilege: args[1]})
+	case "teams":
+		teams := []string{}
+		if args[1] != "" {
+			teams = strings.Split(args[1], ",")
+		}
+		return cli.SaveUser(ctx, client.SaveUserOptions{Name: args[0], Teams: teams})
+	case "delete":
+		return cli.DeleteUser(ctx, args[0])
+	case "list":
+		users, err := cli.ListUsers(ctx)
 		if err != nil {
 			return err
 		}
-		defer r.Body.Close()
-		// Decode valid response
-		type resp struct {
-			Error string `json:"Error"`
+		outFmt := "%-30s%-10s%s\n"
+		fmt.Fprintf(os.Stdout, outFmt, "Name", "Privilege", "Teams")
+		for _, u := range users {
+			fmt.Fprintf(os.Stdout, outFmt, u.Name, u.Privilege, strings.Join(u.Teams, ","))
 		}
-		d := json.NewDecoder(r.Body)
-		rp := resp{}
-		d.Decode(&rp)
-		if rp.Error != "" {
-			return errors.New(rp.Error)
+	case "token":
+		token, err := cli.CreateToken(ctx, args[0])
+		if err != nil {
+			return err
 		}
+		fmt.Fprintln(os.Stdout, token)
+	case "revoke":
+		return cli.RevokeTokens(ctx, args[0])
 	}
-
 	return nil
 }
 
+// Read a password from the first line of stdin.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return password, nil
+}
+
 // Level
 func levelUsage() {
 	var u = `Usage: kapacitor level (debug|info|warn|error)
@@ -841,24 +1316,7 @@ func doLevel(args []string) error {
 		levelUsage()
 		os.Exit(2)
 	}
-	v := url.Values{}
-	v.Add("level", args[0])
-	r, err := http.Post(kapacitorEndpoint+"/loglevel?"+v.Encode(), "text/plain", nil)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
-	// Decode valid response
-	type resp struct {
-		Error string `json:"Error"`
-	}
-	d := json.NewDecoder(r.Body)
-	rp := resp{}
-	d.Decode(&rp)
-	if rp.Error != "" {
-		return errors.New(rp.Error)
-	}
-	return nil
+	return cli.LogLevel(context.Background(), args[0])
 }
 
 // Version
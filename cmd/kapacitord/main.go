@@ -88,21 +88,30 @@ func (m *Main) Run(args ...string) error {
 		}
 
 		signalCh := make(chan os.Signal, 1)
-		signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+		signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 		m.Logger.Println("I! Listening for signals")
 
-		// Block until one of the signals above is received
-		select {
-		case <-signalCh:
+		// Block until one of the signals above is received,
+		// SIGHUP reloads the HTTPS certificates.
+		for sig := range signalCh {
+			if sig == syscall.SIGHUP {
+				m.Logger.Println("I! SIGHUP received, reloading HTTPS certificates...")
+				if err := cmd.Server.Reload(); err != nil {
+					m.Logger.Println("E! failed to reload HTTPS certificates:", err)
+				}
+				continue
+			}
 			m.Logger.Println("I! Signal received, initializing clean shutdown...")
 			go func() {
 				cmd.Close()
 			}()
+			break
 		}
 
 		// Block again until another signal is received, a shutdown timeout elapses,
 		// or the Command is gracefully closed
 		m.Logger.Println("I! Waiting for clean shutdown...")
+		signal.Ignore(syscall.SIGHUP)
 		select {
 		case <-signalCh:
 			m.Logger.Println("I! second signal received, initializing hard shutdown")
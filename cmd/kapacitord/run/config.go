@@ -11,15 +11,19 @@ import (
 	"time"
 
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/auth"
 	"github.com/influxdata/kapacitor/services/deadman"
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/influxdb"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/logging"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
+	"github.com/influxdata/kapacitor/services/prometheus"
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
+	"github.com/influxdata/kapacitor/services/scraper"
 	"github.com/influxdata/kapacitor/services/sensu"
 	"github.com/influxdata/kapacitor/services/slack"
 	"github.com/influxdata/kapacitor/services/smtp"
@@ -37,27 +41,31 @@ import (
 // Config represents the configuration format for the kapacitord binary.
 type Config struct {
 	HTTP     httpd.Config      `toml:"http"`
+	Auth     auth.Config       `toml:"auth"`
 	Replay   replay.Config     `toml:"replay"`
 	Task     task_store.Config `toml:"task"`
 	InfluxDB influxdb.Config   `toml:"influxdb"`
 	Logging  logging.Config    `toml:"logging"`
 
-	Graphites []graphite.Config `toml:"graphite"`
-	Collectd  collectd.Config   `toml:"collectd"`
-	OpenTSDB  opentsdb.Config   `toml:"opentsdb"`
-	UDPs      []udp.Config      `toml:"udp"`
-	SMTP      smtp.Config       `toml:"smtp"`
-	OpsGenie  opsgenie.Config   `toml:"opsgenie"`
-	VictorOps victorops.Config  `toml:"victorops"`
-	PagerDuty pagerduty.Config  `toml:"pagerduty"`
-	Sensu     sensu.Config      `toml:"sensu"`
-	Slack     slack.Config      `toml:"slack"`
-	HipChat   hipchat.Config    `toml:"hipchat"`
-	Alerta    alerta.Config     `toml:"alerta"`
-	Reporting reporting.Config  `toml:"reporting"`
-	Stats     stats.Config      `toml:"stats"`
-	UDF       udf.Config        `toml:"udf"`
-	Deadman   deadman.Config    `toml:"deadman"`
+	Graphites  []graphite.Config `toml:"graphite"`
+	Collectd   collectd.Config   `toml:"collectd"`
+	OpenTSDB   opentsdb.Config   `toml:"opentsdb"`
+	UDPs       []udp.Config      `toml:"udp"`
+	Scrapers   []scraper.Config  `toml:"scraper"`
+	SMTP       smtp.Config       `toml:"smtp"`
+	OpsGenie   opsgenie.Config   `toml:"opsgenie"`
+	VictorOps  victorops.Config  `toml:"victorops"`
+	PagerDuty  pagerduty.Config  `toml:"pagerduty"`
+	Sensu      sensu.Config      `toml:"sensu"`
+	Slack      slack.Config      `toml:"slack"`
+	HipChat    hipchat.Config    `toml:"hipchat"`
+	Alerta     alerta.Config     `toml:"alerta"`
+	Kafka      kafka.Config      `toml:"kafka"`
+	Reporting  reporting.Config  `toml:"reporting"`
+	Stats      stats.Config      `toml:"stats"`
+	Prometheus prometheus.Config `toml:"prometheus"`
+	UDF        udf.Config        `toml:"udf"`
+	Deadman    deadman.Config    `toml:"deadman"`
 
 	Hostname string `toml:"hostname"`
 	DataDir  string `toml:"data_dir"`
@@ -70,6 +78,7 @@ func NewConfig() *Config {
 	}
 
 	c.HTTP = httpd.NewConfig()
+	c.Auth = auth.NewConfig()
 	c.Replay = replay.NewConfig()
 	c.Task = task_store.NewConfig()
 	c.InfluxDB = influxdb.NewConfig()
@@ -85,8 +94,10 @@ func NewConfig() *Config {
 	c.Slack = slack.NewConfig()
 	c.HipChat = hipchat.NewConfig()
 	c.Alerta = alerta.NewConfig()
+	c.Kafka = kafka.NewConfig()
 	c.Reporting = reporting.NewConfig()
 	c.Stats = stats.NewConfig()
+	c.Prometheus = prometheus.NewConfig()
 	c.UDF = udf.NewConfig()
 	c.Deadman = deadman.NewConfig()
 
@@ -110,6 +121,7 @@ func NewDemoConfig() (*Config, error) {
 
 	c.Replay.Dir = filepath.Join(homeDir, ".kapacitor", c.Replay.Dir)
 	c.Task.Dir = filepath.Join(homeDir, ".kapacitor", c.Task.Dir)
+	c.Auth.Dir = filepath.Join(homeDir, ".kapacitor", c.Auth.Dir)
 	c.DataDir = filepath.Join(homeDir, ".kapacitor", c.DataDir)
 
 	return c, nil
@@ -123,7 +135,15 @@ func (c *Config) Validate() error {
 	if c.DataDir == "" {
 		return fmt.Errorf("must configure valid data dir")
 	}
-	err := c.Replay.Validate()
+	err := c.HTTP.Validate()
+	if err != nil {
+		return err
+	}
+	err = c.Auth.Validate(c.HTTP.AuthEnabled)
+	if err != nil {
+		return err
+	}
+	err = c.Replay.Validate()
 	if err != nil {
 		return err
 	}
@@ -143,11 +163,23 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return err
 	}
+	err = c.Kafka.Validate()
+	if err != nil {
+		return err
+	}
 	for _, g := range c.Graphites {
 		if err := g.Validate(); err != nil {
 			return fmt.Errorf("invalid graphite config: %v", err)
 		}
 	}
+	for _, sc := range c.Scrapers {
+		if !sc.Enabled {
+			continue
+		}
+		if err := sc.Validate(); err != nil {
+			return fmt.Errorf("invalid scraper config: %v", err)
+		}
+	}
 	return nil
 }
 
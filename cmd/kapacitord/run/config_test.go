@@ -6,6 +6,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdata/kapacitor/cmd/kapacitord/run"
+	"github.com/influxdata/kapacitor/services/auth"
 )
 
 // Ensure the configuration can be parsed.
@@ -63,3 +64,25 @@ dir = "/tmp/task"
 		t.Fatalf("unexpected task dir: %s", c.Task.Dir)
 	}
 }
+
+// Ensure authentication cannot be enabled without an admin user to create the other users.
+func TestConfig_Validate_AuthAdmin(t *testing.T) {
+	c := NewConfig()
+	c.HTTP.AuthEnabled = true
+	c.Auth.Users = []auth.UserConfig{{Name: "bob", Password: "pass", Privilege: "write"}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error enabling authentication without an admin user")
+	}
+
+	c.Auth.Users = append(c.Auth.Users, auth.UserConfig{Name: "admin", Password: "secret", Privilege: "admin"})
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without authentication no users are needed.
+	c.HTTP.AuthEnabled = false
+	c.Auth.Users = nil
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -15,15 +15,19 @@ import (
 
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/auth"
 	"github.com/influxdata/kapacitor/services/deadman"
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/influxdb"
+	"github.com/influxdata/kapacitor/services/kafka"
 	"github.com/influxdata/kapacitor/services/logging"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
+	"github.com/influxdata/kapacitor/services/prometheus"
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
+	"github.com/influxdata/kapacitor/services/scraper"
 	"github.com/influxdata/kapacitor/services/sensu"
 	"github.com/influxdata/kapacitor/services/slack"
 	"github.com/influxdata/kapacitor/services/smtp"
@@ -86,6 +90,7 @@ type Server struct {
 
 	LogService      logging.Interface
 	HTTPDService    *httpd.Service
+	AuthService     *auth.Service
 	TaskStore       *task_store.Service
 	ReplayService   *replay.Service
 	InfluxDBService *influxdb.Service
@@ -135,6 +140,7 @@ func NewServer(c *Config, buildInfo *BuildInfo, logService logging.Interface) (*
 	s.appendDeadmanService(c.Deadman)
 	s.appendSMTPService(c.SMTP)
 	s.appendHTTPDService(c.HTTP)
+	s.appendAuthService(c.Auth, c.HTTP.AuthEnabled)
 	s.appendInfluxDBService(c.InfluxDB, c.Hostname)
 	s.appendTaskStoreService(c.Task)
 	s.appendReplayStoreService(c.Replay)
@@ -145,6 +151,7 @@ func NewServer(c *Config, buildInfo *BuildInfo, logService logging.Interface) (*
 	s.appendAlertaService(c.Alerta)
 	s.appendSlackService(c.Slack)
 	s.appendSensuService(c.Sensu)
+	s.appendKafkaService(c.Kafka)
 
 	// Append InfluxDB services
 	s.appendCollectdService(c.Collectd)
@@ -159,10 +166,14 @@ func NewServer(c *Config, buildInfo *BuildInfo, logService logging.Interface) (*
 			return nil, err
 		}
 	}
+	for _, sc := range c.Scrapers {
+		s.appendScraperService(sc)
+	}
 
 	// append StatsService and ReportingService last so all stats are ready
 	// to be reported
 	s.appendStatsService(c.Stats)
+	s.appendPrometheusService(c.Prometheus)
 	s.appendReportingService(c.Reporting)
 
 	return s, nil
@@ -204,6 +215,18 @@ func (s *Server) appendHTTPDService(c httpd.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+func (s *Server) appendAuthService(c auth.Config, enabled bool) {
+	if enabled {
+		l := s.LogService.NewLogger("[auth] ", log.LstdFlags)
+		srv := auth.NewService(c, l)
+		srv.HTTPDService = s.HTTPDService
+
+		s.AuthService = srv
+		s.HTTPDService.Handler.AuthService = srv
+		s.Services = append(s.Services, srv)
+	}
+}
+
 func (s *Server) appendTaskStoreService(c task_store.Config) {
 	l := s.LogService.NewLogger("[task_store] ", log.LstdFlags)
 	srv := task_store.NewService(c, l)
@@ -284,6 +307,16 @@ func (s *Server) appendSensuService(c sensu.Config) {
 	}
 }
 
+func (s *Server) appendKafkaService(c kafka.Config) {
+	if c.Enabled {
+		l := s.LogService.NewLogger("[kafka] ", log.LstdFlags)
+		srv := kafka.NewService(c, l)
+		s.TaskMaster.KafkaService = srv
+
+		s.Services = append(s.Services, srv)
+	}
+}
+
 func (s *Server) appendSlackService(c slack.Config) {
 	if c.Enabled {
 		l := s.LogService.NewLogger("[slack] ", log.LstdFlags)
@@ -369,6 +402,16 @@ func (s *Server) appendUDPService(c udp.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+func (s *Server) appendScraperService(c scraper.Config) {
+	if !c.Enabled {
+		return
+	}
+	l := s.LogService.NewLogger(fmt.Sprintf("[scraper:%s] ", c.Name), log.LstdFlags)
+	srv := scraper.NewService(c, l)
+	srv.PointsWriter = s.TaskMaster
+	s.Services = append(s.Services, srv)
+}
+
 func (s *Server) appendStatsService(c stats.Config) {
 	if c.Enabled {
 		l := s.LogService.NewLogger("[stats] ", log.LstdFlags)
@@ -379,6 +422,17 @@ func (s *Server) appendStatsService(c stats.Config) {
 	}
 }
 
+func (s *Server) appendPrometheusService(c prometheus.Config) {
+	if c.Enabled {
+		l := s.LogService.NewLogger("[prometheus] ", log.LstdFlags)
+		srv := prometheus.NewService(c, l)
+		srv.HTTPDService = s.HTTPDService
+		srv.TaskMaster = s.TaskMaster
+
+		s.Services = append(s.Services, srv)
+	}
+}
+
 func (s *Server) appendReportingService(c reporting.Config) {
 	if c.Enabled {
 		l := s.LogService.NewLogger("[reporting] ", log.LstdFlags)
@@ -437,6 +491,11 @@ func (s *Server) Open() error {
 	return nil
 }
 
+// Reload reloads the HTTPS certificates of the HTTP service.
+func (s *Server) Reload() error {
+	return s.HTTPDService.Reload()
+}
+
 // Close shuts down the meta and data stores and all services.
 func (s *Server) Close() error {
 	s.stopProfile()
@@ -3,16 +3,25 @@ package run_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -20,6 +29,7 @@ import (
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/cmd/kapacitord/run"
 	"github.com/influxdata/kapacitor/services/task_store"
+	"github.com/influxdata/kapacitor/tick"
 	"github.com/influxdata/kapacitor/wlog"
 	"github.com/influxdb/influxdb/client"
 )
@@ -70,6 +80,7 @@ func (s *Server) Close() {
 	s.Server.Close()
 	os.RemoveAll(s.Config.Replay.Dir)
 	os.RemoveAll(s.Config.Task.Dir)
+	os.RemoveAll(s.Config.Auth.Dir)
 	os.RemoveAll(s.Config.DataDir)
 }
 
@@ -170,6 +181,42 @@ func (s *Server) DefineTask(name, ttype, tick string, dbrps []kapacitor.DBRP) (r
 	return
 }
 
+func (s *Server) DefineTemplate(name, ttype, tick string) (results string, err error) {
+	v := url.Values{}
+	v.Add("name", name)
+	v.Add("type", ttype)
+	results, err = s.HTTPPost(s.URL()+"/template?"+v.Encode(), []byte(tick))
+	return
+}
+
+func (s *Server) DefineTaskFromTemplate(name, template, vars string, dbrps []kapacitor.DBRP) (results string, err error) {
+	dbrpsStr, err := json.Marshal(dbrps)
+	if err != nil {
+		return
+	}
+	v := url.Values{}
+	v.Add("name", name)
+	v.Add("template", template)
+	v.Add("dbrps", string(dbrpsStr))
+	results, err = s.HTTPPost(s.URL()+"/task?"+v.Encode(), []byte(vars))
+	return
+}
+
+func (s *Server) ValidateTask(ttype, script string) (errs []tick.LintError, err error) {
+	v := url.Values{}
+	v.Add("type", ttype)
+	results, err := s.HTTPPost(s.URL()+"/task/validate?"+v.Encode(), []byte(script))
+	if err != nil {
+		return
+	}
+	var resp struct {
+		Errors []tick.LintError
+	}
+	err = json.Unmarshal([]byte(results), &resp)
+	errs = resp.Errors
+	return
+}
+
 func (s *Server) EnableTask(name string) (string, error) {
 	v := url.Values{}
 	v.Add("name", name)
@@ -330,6 +377,7 @@ func NewConfig() *run.Config {
 	c.Reporting.Enabled = false
 	c.Replay.Dir = MustTempFile()
 	c.Task.Dir = MustTempFile()
+	c.Auth.Dir = MustTempFile()
 	c.DataDir = MustTempFile()
 	c.HTTP.BindAddress = "127.0.0.1:0"
 	c.InfluxDB.Enabled = false
@@ -347,6 +395,71 @@ func MustTempFile() string {
 	return f.Name()
 }
 
+// A certificate and its private key written as PEM files.
+type TestCert struct {
+	Cert     *x509.Certificate
+	Key      *ecdsa.PrivateKey
+	CertFile string
+	KeyFile  string
+}
+
+// MustGenerateCert creates a certificate for 127.0.0.1 signed by the CA, or a self signed CA if ca is nil.
+// The certificate and key are written to the files <name>.pem and <name>-key.pem in dir.
+func MustGenerateCert(dir, name string, ca *TestCert) *TestCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, signer := tmpl, key
+	if ca == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.Cert, ca.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	c := &TestCert{
+		Cert:     cert,
+		Key:      key,
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+"-key.pem"),
+	}
+	err = ioutil.WriteFile(c.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		panic(err)
+	}
+	err = ioutil.WriteFile(c.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
 func configureLogging() {
 	if testing.Verbose() {
 		wlog.SetLevel(wlog.DEBUG)
@@ -2,6 +2,8 @@ package run_test
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -17,7 +19,9 @@ import (
 
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/cmd/kapacitord/run"
+	"github.com/influxdata/kapacitor/services/auth"
 	"github.com/influxdata/kapacitor/services/udf"
+	"github.com/influxdata/kapacitor/tick"
 	"github.com/influxdb/influxdb/client"
 	"github.com/influxdb/influxdb/influxql"
 	"github.com/influxdb/influxdb/models"
@@ -50,6 +54,615 @@ func TestServer_Version(t *testing.T) {
 	}
 }
 
+func TestServer_AuthenticationDisabled(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+	// The users database is not created unless authentication is enabled.
+	if _, err := os.Stat(s.Config.Auth.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected auth dir %s to not exist, got %v", s.Config.Auth.Dir, err)
+	}
+	resp, err := http.Get(s.URL() + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status code: got %d exp %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestServer_Authentication(t *testing.T) {
+	c := NewConfig()
+	c.HTTP.AuthEnabled = true
+	c.Auth.Users = []auth.UserConfig{
+		{Name: "admin", Password: "secret", Privilege: "admin"},
+		{Name: "viewer", Password: "pass", Privilege: "read"},
+	}
+	s := OpenServer(c)
+	defer s.Close()
+
+	// Make a request as the user, using the password if not empty or else the token.
+	request := func(method, path, user, password, token, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL()+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "" {
+			req.SetBasicAuth(user, password)
+		} else if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, string(MustReadAll(resp.Body))
+	}
+
+	dbrps, _ := json.Marshal([]kapacitor.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}})
+	v := url.Values{}
+	v.Add("name", "testTaskName")
+	v.Add("type", "stream")
+	v.Add("dbrps", string(dbrps))
+	defineTask := "/task?" + v.Encode()
+	script := "stream.from().measurement('test')"
+
+	testCases := []struct {
+		method, path, user, password, token, body string
+		code                                      int
+	}{
+		// Ping does not require authentication.
+		{method: "GET", path: "/ping", code: http.StatusNoContent},
+		{method: "GET", path: "/tasks", code: http.StatusUnauthorized},
+		{method: "GET", path: "/tasks", user: "viewer", password: "wrong", code: http.StatusUnauthorized},
+		{method: "GET", path: "/tasks", user: "unknown", password: "pass", code: http.StatusUnauthorized},
+		{method: "GET", path: "/tasks", user: "viewer", password: "pass", code: http.StatusOK},
+		{method: "POST", path: defineTask, user: "viewer", password: "pass", body: script, code: http.StatusForbidden},
+		{method: "GET", path: "/users", user: "viewer", password: "pass", code: http.StatusForbidden},
+		{method: "POST", path: "/user?name=writer&privilege=write", user: "admin", password: "secret", body: "pw", code: http.StatusOK},
+		{method: "POST", path: defineTask, user: "writer", password: "pw", body: script, code: http.StatusOK},
+		{method: "POST", path: "/loglevel?level=info", user: "writer", password: "pw", code: http.StatusForbidden},
+		{method: "POST", path: "/user/token?name=admin", user: "viewer", password: "pass", code: http.StatusForbidden},
+		// The debug endpoints require authentication too.
+		{method: "GET", path: "/debug/vars", code: http.StatusUnauthorized},
+		{method: "GET", path: "/debug/vars", user: "viewer", password: "pass", code: http.StatusOK},
+		{method: "GET", path: "/debug/pprof/", code: http.StatusUnauthorized},
+		{method: "GET", path: "/debug/pprof/", user: "viewer", password: "pass", code: http.StatusForbidden},
+		{method: "GET", path: "/debug/pprof/", user: "admin", password: "secret", code: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		code, body := request(tc.method, tc.path, tc.user, tc.password, tc.token, tc.body)
+		if code != tc.code {
+			t.Errorf("%s %s as %q: unexpected status code: got %d exp %d, body: %s", tc.method, tc.path, tc.user, code, tc.code, body)
+		}
+	}
+
+	// Users are listed for admins.
+	code, body := request("GET", "/users", "admin", "secret", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code listing users: %d %s", code, body)
+	}
+	users := struct {
+		Users []struct {
+			Name      string
+			Privilege string
+		}
+	}{}
+	if err := json.Unmarshal([]byte(body), &users); err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := 3, len(users.Users); got != exp {
+		t.Fatalf("unexpected number of users: got %d exp %d", got, exp)
+	}
+	if u := users.Users[2]; u.Name != "writer" || u.Privilege != "write" {
+		t.Errorf("unexpected user: %v", u)
+	}
+
+	// Users can create tokens for themselves.
+	code, body = request("POST", "/user/token?name=viewer", "viewer", "pass", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code creating token: %d %s", code, body)
+	}
+	token := struct{ Token string }{}
+	if err := json.Unmarshal([]byte(body), &token); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := request("GET", "/tasks", "", "", token.Token, ""); code != http.StatusOK {
+		t.Errorf("unexpected status code with token: %d %s", code, body)
+	}
+	if code, body := request("POST", defineTask, "", "", token.Token, script); code != http.StatusForbidden {
+		t.Errorf("unexpected status code defining task with read token: %d %s", code, body)
+	}
+
+	// Revoked tokens are no longer valid.
+	if code, body := request("DELETE", "/user/token?name=viewer", "viewer", "pass", "", ""); code != http.StatusOK {
+		t.Fatalf("unexpected status code revoking tokens: %d %s", code, body)
+	}
+	if code, _ := request("GET", "/tasks", "", "", token.Token, ""); code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code with revoked token: %d", code)
+	}
+
+	// Deleted users can no longer authenticate.
+	if code, body := request("DELETE", "/user?name=writer", "admin", "secret", "", ""); code != http.StatusOK {
+		t.Fatalf("unexpected status code deleting user: %d %s", code, body)
+	}
+	if code, _ := request("GET", "/tasks", "writer", "pw", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code for deleted user: %d", code)
+	}
+}
+
+func TestServer_AccessControl(t *testing.T) {
+	c := NewConfig()
+	c.HTTP.AuthEnabled = true
+	c.Auth.Users = []auth.UserConfig{
+		{Name: "admin", Password: "secret", Privilege: "admin"},
+		{Name: "alice", Password: "pass", Privilege: "write", Teams: []string{"ops"}},
+		{Name: "bob", Password: "pass", Privilege: "write", Teams: []string{"ops"}},
+		{Name: "carol", Password: "pass", Privilege: "read", Teams: []string{"ops"}},
+		{Name: "dave", Password: "pass", Privilege: "write", Teams: []string{"dev"}},
+	}
+	s := OpenServer(c)
+	defer s.Close()
+
+	request := func(method, path, user, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL()+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		password := "pass"
+		if user == "admin" {
+			password = "secret"
+		}
+		req.SetBasicAuth(user, password)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, string(MustReadAll(resp.Body))
+	}
+	// List the names of the tasks or the IDs of the recordings the user can see.
+	list := func(path, user string) []string {
+		code, body := request("GET", path, user, "")
+		if code != http.StatusOK {
+			t.Fatalf("unexpected status code listing %s as %q: %d %s", path, user, code, body)
+		}
+		l := struct {
+			Tasks      []struct{ Name string }
+			Recordings []struct{ ID string }
+		}{}
+		if err := json.Unmarshal([]byte(body), &l); err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, task := range l.Tasks {
+			names = append(names, task.Name)
+		}
+		for _, r := range l.Recordings {
+			names = append(names, r.ID)
+		}
+		return names
+	}
+
+	dbrps, _ := json.Marshal([]kapacitor.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}})
+	define := func(name, team string) string {
+		v := url.Values{}
+		v.Add("name", name)
+		v.Add("type", "stream")
+		v.Add("dbrps", string(dbrps))
+		if team != "" {
+			v.Add("team", team)
+		}
+		return "/task?" + v.Encode()
+	}
+	script := "stream.from().measurement('test')"
+
+	testCases := []struct {
+		method, path, user, body string
+		code                     int
+	}{
+		// Users can only share tasks with their own teams.
+		{method: "POST", path: define("opsTask", "dev"), user: "alice", body: script, code: http.StatusForbidden},
+		{method: "POST", path: define("opsTask", "ops"), user: "alice", body: script, code: http.StatusOK},
+		{method: "POST", path: define("devTask", ""), user: "dave", body: script, code: http.StatusOK},
+		// Team members can see, modify, enable and disable the task.
+		{method: "GET", path: "/task?name=opsTask", user: "bob", code: http.StatusOK},
+		{method: "POST", path: define("opsTask", ""), user: "bob", body: script, code: http.StatusOK},
+		{method: "POST", path: "/enable?name=opsTask", user: "bob", code: http.StatusOK},
+		{method: "POST", path: "/disable?name=opsTask", user: "bob", code: http.StatusOK},
+		// Only the owner can delete the task or change its team.
+		{method: "POST", path: define("opsTask", "dev"), user: "bob", body: script, code: http.StatusForbidden},
+		{method: "DELETE", path: "/task?name=opsTask", user: "bob", code: http.StatusForbidden},
+		// Team members with only the read privilege are viewers.
+		{method: "GET", path: "/task?name=opsTask", user: "carol", code: http.StatusOK},
+		{method: "POST", path: "/task/validate?name=opsTask", user: "carol", code: http.StatusOK},
+		// Other users cannot see the task.
+		{method: "GET", path: "/task?name=opsTask", user: "dave", code: http.StatusNotFound},
+		{method: "POST", path: "/enable?name=opsTask", user: "dave", code: http.StatusNotFound},
+		{method: "DELETE", path: "/task?name=opsTask", user: "dave", code: http.StatusNotFound},
+		{method: "POST", path: "/record?type=stream&duration=1s&name=opsTask", user: "dave", code: http.StatusNotFound},
+		// Admins can access all tasks.
+		{method: "GET", path: "/task?name=devTask", user: "admin", code: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		code, body := request(tc.method, tc.path, tc.user, tc.body)
+		if code != tc.code {
+			t.Errorf("%s %s as %q: unexpected status code: got %d exp %d, body: %s", tc.method, tc.path, tc.user, code, tc.code, body)
+		}
+	}
+
+	// Tasks are listed only for the users that can see them.
+	listCases := []struct {
+		user  string
+		tasks []string
+	}{
+		{user: "admin", tasks: []string{"devTask", "opsTask"}},
+		{user: "alice", tasks: []string{"opsTask"}},
+		{user: "carol", tasks: []string{"opsTask"}},
+		{user: "dave", tasks: []string{"devTask"}},
+	}
+	for _, lc := range listCases {
+		if got := list("/tasks", lc.user); !reflect.DeepEqual(got, lc.tasks) {
+			t.Errorf("unexpected tasks for %q: got %v exp %v", lc.user, got, lc.tasks)
+		}
+	}
+
+	// Recordings are shared with the team of the task.
+	code, body := request("POST", "/record?type=stream&duration=1s&name=opsTask", "bob", "")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code recording: %d %s", code, body)
+	}
+	rec := struct{ RecordingID string }{}
+	if err := json.Unmarshal([]byte(body), &rec); err != nil {
+		t.Fatal(err)
+	}
+	id := rec.RecordingID
+	code, body = request("POST", "/write?db=mydb&rp=myrp&precision=s", "admin", "test value=1 0000000000\ntest value=1 0000000002\n")
+	if code != http.StatusNoContent {
+		t.Fatalf("unexpected status code writing points: %d %s", code, body)
+	}
+	if code, body := request("GET", "/record?id="+id, "bob", ""); code != http.StatusOK {
+		t.Fatalf("unexpected status code getting recording: %d %s", code, body)
+	}
+	if got, exp := list("/recordings", "carol"), []string{id}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected recordings for carol: got %v exp %v", got, exp)
+	}
+	if got, exp := list("/recordings", "dave"), []string{}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected recordings for dave: got %v exp %v", got, exp)
+	}
+	recordingCases := []struct {
+		method, path, user string
+		code               int
+	}{
+		{method: "GET", path: "/record?id=" + id, user: "dave", code: http.StatusNotFound},
+		{method: "POST", path: "/replay?name=devTask&id=" + id, user: "dave", code: http.StatusNotFound},
+		{method: "POST", path: "/replay?name=devTask&id=" + id, user: "bob", code: http.StatusNotFound},
+		{method: "POST", path: "/replay?name=opsTask&id=" + id, user: "alice", code: http.StatusOK},
+		{method: "DELETE", path: "/recording?rid=" + id, user: "dave", code: http.StatusNotFound},
+		{method: "DELETE", path: "/recording?rid=" + id, user: "carol", code: http.StatusForbidden},
+		{method: "DELETE", path: "/recording?rid=" + id, user: "bob", code: http.StatusOK},
+	}
+	for _, tc := range recordingCases {
+		code, body := request(tc.method, tc.path, tc.user, "")
+		if code != tc.code {
+			t.Errorf("%s %s as %q: unexpected status code: got %d exp %d, body: %s", tc.method, tc.path, tc.user, code, tc.code, body)
+		}
+	}
+	if got, exp := list("/recordings", "admin"), []string{}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected recordings after delete: got %v exp %v", got, exp)
+	}
+}
+
+func TestServer_TemplateAccessControl(t *testing.T) {
+	c := NewConfig()
+	c.HTTP.AuthEnabled = true
+	c.Auth.Users = []auth.UserConfig{
+		{Name: "admin", Password: "secret", Privilege: "admin"},
+		{Name: "alice", Password: "pass", Privilege: "write", Teams: []string{"ops"}},
+		{Name: "bob", Password: "pass", Privilege: "write", Teams: []string{"ops"}},
+		{Name: "dave", Password: "pass", Privilege: "write", Teams: []string{"dev"}},
+	}
+	s := OpenServer(c)
+	defer s.Close()
+
+	request := func(method, path, user, body string) (int, string) {
+		req, err := http.NewRequest(method, s.URL()+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(user, "pass")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, string(MustReadAll(resp.Body))
+	}
+
+	dbrps, _ := json.Marshal([]kapacitor.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}})
+	define := func(name, team string) string {
+		v := url.Values{}
+		v.Add("name", name)
+		v.Add("template", "opsTemplate")
+		v.Add("dbrps", string(dbrps))
+		if team != "" {
+			v.Add("team", team)
+		}
+		return "/task?" + v.Encode()
+	}
+	script := "var m = 'test'\nstream.from().measurement(m)"
+
+	testCases := []struct {
+		method, path, user, body string
+		code                     int
+	}{
+		{method: "POST", path: "/template?name=opsTemplate&type=stream&team=ops", user: "alice", body: script, code: http.StatusOK},
+		// Team members can see the template and define tasks from it.
+		{method: "GET", path: "/template?name=opsTemplate", user: "bob", code: http.StatusOK},
+		{method: "POST", path: define("bobTask", ""), user: "bob", code: http.StatusOK},
+		// Other users cannot see the template.
+		{method: "GET", path: "/template?name=opsTemplate", user: "dave", code: http.StatusNotFound},
+		{method: "POST", path: define("daveTask", ""), user: "dave", code: http.StatusBadRequest},
+		{method: "DELETE", path: "/template?name=opsTemplate", user: "dave", code: http.StatusNotFound},
+		// Only the owner can change or delete the template.
+		{method: "POST", path: "/template?name=opsTemplate", user: "bob", body: script, code: http.StatusForbidden},
+		{method: "DELETE", path: "/template?name=opsTemplate", user: "bob", code: http.StatusForbidden},
+		// The owner cannot change the template while it redefines tasks they cannot modify.
+		{method: "POST", path: "/template?name=opsTemplate", user: "alice", body: script, code: http.StatusForbidden},
+		{method: "POST", path: define("bobTask", "ops"), user: "bob", code: http.StatusOK},
+		{method: "POST", path: "/template?name=opsTemplate", user: "alice", body: script, code: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		code, body := request(tc.method, tc.path, tc.user, tc.body)
+		if code != tc.code {
+			t.Errorf("%s %s as %q: unexpected status code: got %d exp %d, body: %s", tc.method, tc.path, tc.user, code, tc.code, body)
+		}
+	}
+
+	// The template is listed only for the users that can see it.
+	for user, exp := range map[string]int{"bob": 1, "dave": 0} {
+		code, body := request("GET", "/templates", user, "")
+		if code != http.StatusOK {
+			t.Fatalf("unexpected status code listing templates as %q: %d %s", user, code, body)
+		}
+		l := struct{ Templates []struct{ Name string } }{}
+		if err := json.Unmarshal([]byte(body), &l); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(l.Templates); got != exp {
+			t.Errorf("unexpected number of templates for %q: got %d exp %d", user, got, exp)
+		}
+	}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := MustGenerateCert(dir, "ca", nil)
+	server := MustGenerateCert(dir, "server", ca)
+	client := MustGenerateCert(dir, "client", ca)
+	otherCA := MustGenerateCert(dir, "other-ca", nil)
+	otherClient := MustGenerateCert(dir, "other-client", otherCA)
+
+	c := NewConfig()
+	c.HTTP.HttpsEnabled = true
+	c.HTTP.HttpsCertificate = server.CertFile
+	c.HTTP.HttpsPrivateKey = server.KeyFile
+	c.HTTP.HttpsClientCA = ca.CertFile
+	s := OpenServer(c)
+	defer s.Close()
+
+	// Ping the server trusting the CA and presenting the client certificate if not nil.
+	ping := func(ca, client *TestCert) error {
+		tc := &tls.Config{RootCAs: x509.NewCertPool()}
+		tc.RootCAs.AddCert(ca.Cert)
+		if client != nil {
+			cert, err := tls.LoadX509KeyPair(client.CertFile, client.KeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.Certificates = []tls.Certificate{cert}
+		}
+		hc := &http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+		resp, err := hc.Get(s.URL() + "/ping")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	if err := ping(ca, client); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(ca, nil); err == nil {
+		t.Error("expected error without a client certificate")
+	}
+	if err := ping(ca, otherClient); err == nil {
+		t.Error("expected error with a client certificate of another CA")
+	}
+
+	// Replace the CA and server certificate, they are only used once reloaded.
+	newCA := MustGenerateCert(dir, "ca", nil)
+	MustGenerateCert(dir, "server", newCA)
+	newClient := MustGenerateCert(dir, "new-client", newCA)
+	if err := ping(newCA, newClient); err == nil {
+		t.Error("expected error before reloading the certificates")
+	}
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(newCA, newClient); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(ca, client); err == nil {
+		t.Error("expected error with the replaced certificates")
+	}
+
+	// A failed reload keeps the current certificates.
+	if err := os.Remove(server.KeyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload(); err == nil {
+		t.Error("expected error reloading a missing private key")
+	}
+	if err := ping(newCA, newClient); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_APITasks(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	// Make a request to the versioned API and decode the JSON response into v if not nil.
+	request := func(method, path, body string, v interface{}) (int, http.Header) {
+		req, err := http.NewRequest(method, s.URL()+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data := MustReadAll(resp.Body)
+		if v != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, v); err != nil {
+				t.Fatalf("%s %s: invalid response %q: %v", method, path, data, err)
+			}
+		}
+		return resp.StatusCode, resp.Header
+	}
+	type task struct {
+		ID        string           `json:"id"`
+		Type      string           `json:"type"`
+		DBRPs     []kapacitor.DBRP `json:"dbrps"`
+		Script    string           `json:"script"`
+		Status    string           `json:"status"`
+		Executing bool             `json:"executing"`
+	}
+	type apiError struct {
+		Error string `json:"error"`
+	}
+	script := "stream.from().measurement('test')"
+	dbrps := []kapacitor.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}}
+
+	// Create tasks
+	for _, id := range []string{"a", "b", "c"} {
+		body := `{"id":"` + id + `","type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}],"script":"` + script + `"}`
+		var got task
+		code, header := request("POST", "/v1/tasks", body, &got)
+		if code != http.StatusCreated {
+			t.Fatalf("unexpected status code creating task %s: %d", id, code)
+		}
+		if exp := "/v1/tasks/" + id; header.Get("Location") != exp {
+			t.Errorf("unexpected location: got %s exp %s", header.Get("Location"), exp)
+		}
+		exp := task{ID: id, Type: "stream", DBRPs: dbrps, Script: script, Status: "disabled"}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("unexpected task:\ngot %+v\nexp %+v", got, exp)
+		}
+	}
+
+	errorCases := []struct {
+		method, path, body string
+		code               int
+		err                string
+	}{
+		{method: "POST", path: "/v1/tasks", body: `{"id":"a","type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}],"script":"stream"}`, code: http.StatusConflict, err: "task a already exists"},
+		{method: "POST", path: "/v1/tasks/d", body: `{"type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}]}`, code: http.StatusBadRequest, err: "must provide a script or a template-id"},
+		{method: "POST", path: "/v1/tasks/d", body: `{"id":"e"}`, code: http.StatusBadRequest, err: `task id "e" does not match the path`},
+		{method: "POST", path: "/v1/tasks/d", body: `{"type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}],"script":"stream","template-id":""}`, code: http.StatusBadRequest, err: "template-id must not be empty, provide a script to define the task without a template"},
+		{method: "PATCH", path: "/v1/tasks/a", body: `{"template-id":""}`, code: http.StatusBadRequest, err: "template-id must not be empty, provide a script to define the task without a template"},
+		{method: "POST", path: "/v1/tasks", body: `{`, code: http.StatusBadRequest, err: "invalid task: unexpected EOF"},
+		{method: "GET", path: "/v1/tasks/d", code: http.StatusNotFound, err: "unknown task d"},
+		{method: "PATCH", path: "/v1/tasks/a", body: `{"status":"running"}`, code: http.StatusBadRequest, err: `invalid status "running", must be "enabled" or "disabled"`},
+		{method: "GET", path: "/v1/tasks?fields=name", code: http.StatusBadRequest, err: `unknown field "name"`},
+		{method: "GET", path: "/v1/tasks?limit=0", code: http.StatusBadRequest, err: "offset must not be negative and limit must be positive"},
+		{method: "GET", path: "/v1/unknown", code: http.StatusNotFound, err: "Not Found"},
+	}
+	for _, tc := range errorCases {
+		var got apiError
+		code, _ := request(tc.method, tc.path, tc.body, &got)
+		if code != tc.code || got.Error != tc.err {
+			t.Errorf("%s %s: unexpected error: got %d %q exp %d %q", tc.method, tc.path, code, got.Error, tc.code, tc.err)
+		}
+	}
+
+	// An invalid script is an error of the request.
+	if code, _ := request("POST", "/v1/tasks/d", `{"type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}],"script":"stream.nomethod()"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("unexpected status code creating task with an invalid script: got %d exp %d", code, http.StatusBadRequest)
+	}
+
+	// A new task that fails to start is not kept, the kafka service is not enabled.
+	kafkaScript := "stream.from().measurement('test').kafkaOut('test')"
+	if code, _ := request("POST", "/v1/tasks/d", `{"type":"stream","dbrps":[{"db":"mydb","rp":"myrp"}],"script":"`+kafkaScript+`","status":"enabled"}`, nil); code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code creating task that fails to start: got %d exp %d", code, http.StatusInternalServerError)
+	}
+	if code, _ := request("GET", "/v1/tasks/d", "", nil); code != http.StatusNotFound {
+		t.Errorf("unexpected status code getting task that failed to start: got %d exp %d", code, http.StatusNotFound)
+	}
+
+	// Enable and update a task
+	var got task
+	if code, _ := request("PATCH", "/v1/tasks/b", `{"status":"enabled"}`, &got); code != http.StatusOK {
+		t.Fatalf("unexpected status code enabling task: %d", code)
+	}
+	if got.Status != "enabled" || !got.Executing {
+		t.Errorf("expected task to be enabled and executing: %+v", got)
+	}
+	newScript := "stream.from().measurement('other')"
+	if code, _ := request("PATCH", "/v1/tasks/b", `{"script":"`+newScript+`"}`, &got); code != http.StatusOK {
+		t.Fatalf("unexpected status code updating task: %d", code)
+	}
+	if got.Script != newScript || got.Status != "enabled" || !got.Executing {
+		t.Errorf("expected updated task to still be executing: %+v", got)
+	}
+
+	// List tasks with pagination and field selection
+	var list struct {
+		Tasks  []map[string]interface{} `json:"tasks"`
+		Offset int                      `json:"offset"`
+		Limit  int                      `json:"limit"`
+		Total  int                      `json:"total"`
+	}
+	if code, _ := request("GET", "/v1/tasks?offset=1&limit=1&fields=status", "", &list); code != http.StatusOK {
+		t.Fatalf("unexpected status code listing tasks: %d", code)
+	}
+	expTasks := []map[string]interface{}{{"id": "b", "status": "enabled"}}
+	if !reflect.DeepEqual(list.Tasks, expTasks) || list.Offset != 1 || list.Limit != 1 || list.Total != 3 {
+		t.Errorf("unexpected list: %+v", list)
+	}
+	if code, _ := request("GET", "/v1/tasks?offset=5", "", &list); code != http.StatusOK || len(list.Tasks) != 0 || list.Total != 3 {
+		t.Errorf("unexpected list past the end: %d %+v", code, list)
+	}
+
+	// The previous API sees the same tasks.
+	ti, err := s.GetTask("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.TICKscript != newScript || !ti.Enabled {
+		t.Errorf("unexpected task info: %+v", ti)
+	}
+
+	// Delete a task
+	if code, _ := request("DELETE", "/v1/tasks/b", "", nil); code != http.StatusNoContent {
+		t.Fatalf("unexpected status code deleting task: %d", code)
+	}
+	if code, _ := request("GET", "/v1/tasks/b", "", nil); code != http.StatusNotFound {
+		t.Errorf("unexpected status code getting deleted task: %d", code)
+	}
+}
+
 func TestServer_DefineTask(t *testing.T) {
 	s := OpenDefaultServer()
 	defer s.Close()
@@ -104,6 +717,154 @@ func TestServer_DefineTask(t *testing.T) {
 	}
 }
 
+func TestServer_ValidateTask(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	script := `var x = 'unused'
+stream.from().measurement('test')
+	.httpOut('out')
+`
+	errs, err := s.ValidateTask("stream", script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []tick.LintError{{Line: 1, Char: 5, Msg: "var x is declared but never used"}}
+	if !reflect.DeepEqual(errs, exp) {
+		t.Fatalf("unexpected lint errors got %v exp %v", errs, exp)
+	}
+
+	// Invalid scripts are reported as errors.
+	_, err = s.ValidateTask("stream", "stream.from().nomethod()")
+	if err == nil {
+		t.Fatal("expected error validating invalid script")
+	}
+}
+
+func TestServer_DefineTaskFromTemplate(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	tmplName := "testTemplateName"
+	tmpl := `var m = 'test'
+var every = 10s
+stream.from().measurement(m)
+	.window().period(every).every(every)
+`
+	r, err := s.DefineTemplate(tmplName, "stream", tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "" {
+		t.Fatal("unexpected result", r)
+	}
+
+	name := "testTaskName"
+	dbrps := []kapacitor.DBRP{
+		{
+			Database:        "mydb",
+			RetentionPolicy: "myrp",
+		},
+	}
+	vars := `{"m": {"type": "string", "value": "other"}, "every": {"type": "duration", "value": "1m"}}`
+	r, err = s.DefineTaskFromTemplate(name, tmplName, vars, dbrps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != "" {
+		t.Fatal("unexpected result", r)
+	}
+
+	ti, err := s.GetTask(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.Error != "" {
+		t.Fatal(ti.Error)
+	}
+	if ti.Type != kapacitor.StreamTask {
+		t.Fatalf("unexpected type got %s exp %s", ti.Type, kapacitor.StreamTask)
+	}
+	if ti.Template != tmplName {
+		t.Fatalf("unexpected template got %s exp %s", ti.Template, tmplName)
+	}
+	if ti.TICKscript != tmpl {
+		t.Fatalf("unexpected TICKscript got %s exp %s", ti.TICKscript, tmpl)
+	}
+	if exp, got := 2, len(ti.Vars); exp != got {
+		t.Fatalf("unexpected number of vars got %d exp %d", got, exp)
+	}
+
+	// Vars must match the types of the template declarations.
+	_, err = s.DefineTaskFromTemplate(name, tmplName, `{"m": {"type": "int", "value": 1}}`, dbrps)
+	if err == nil {
+		t.Fatal("expected error defining task with mismatched var type")
+	}
+
+	// Template updates that are invalid for existing tasks are rejected.
+	_, err = s.DefineTemplate(tmplName, "stream", "var m = 10\nstream.from().where(lambda: \"value\" > m)")
+	if err == nil {
+		t.Fatal("expected error updating template with incompatible vars")
+	}
+
+	// Valid template updates are applied to existing tasks.
+	tmpl = `var m = 'test'
+var every = 10s
+stream.from().measurement(m)
+`
+	_, err = s.DefineTemplate(tmplName, "stream", tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti, err = s.GetTask(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ti.TICKscript != tmpl {
+		t.Fatalf("unexpected TICKscript got %s exp %s", ti.TICKscript, tmpl)
+	}
+	dot := "digraph testTaskName {\nstream0 -> stream1;\n}"
+	if ti.Dot != dot {
+		t.Fatalf("unexpected dot got %s exp %s", ti.Dot, dot)
+	}
+}
+
+func TestServer_UpdateTemplateReloadsTask(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	tmplName := "testTemplateName"
+	tmpl := "var m = 'test'\nstream.from().measurement(m)"
+	if _, err := s.DefineTemplate(tmplName, "stream", tmpl); err != nil {
+		t.Fatal(err)
+	}
+	name := "testTaskName"
+	dbrps := []kapacitor.DBRP{{Database: "mydb", RetentionPolicy: "myrp"}}
+	if _, err := s.DefineTaskFromTemplate(name, tmplName, "", dbrps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableTask(name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updating the template restarts the task.
+	// The stopped task finishes in the background and must not stop its replacement.
+	for i := 0; i < 3; i++ {
+		if _, err := s.DefineTemplate(tmplName, "stream", tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	ti, err := s.GetTask(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ti.Enabled || !ti.Executing {
+		t.Fatalf("expected task to be enabled and executing, got enabled %v executing %v", ti.Enabled, ti.Executing)
+	}
+}
+
 func TestServer_EnableTask(t *testing.T) {
 	s := OpenDefaultServer()
 	defer s.Close()
@@ -346,6 +1107,145 @@ test value=1 0000000011
 	}
 }
 
+func TestServer_Metrics(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	name := "testMetrics"
+	dbrps := []kapacitor.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `
+stream
+	.from().measurement('test')
+	.httpOut('latest')
+`
+	if _, err := s.DefineTask(name, "stream", tick, dbrps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableTask(name); err != nil {
+		t.Fatal(err)
+	}
+
+	points := `test value=1 0000000000
+test value=1 0000000001
+test value=1 0000000002
+`
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", points, v)
+
+	exp := []string{
+		"# TYPE kapacitor_edges_collected untyped",
+		`kapacitor_edges_collected{child="http_out2",parent="stream1",task="testMetrics",type="stream"} 3`,
+		`kapacitor_nodes_subscribers{node="http_out2",task="testMetrics"} 0`,
+		"# TYPE kapacitor_num_tasks untyped",
+	}
+	var metrics string
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(s.URL() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		metrics = string(MustReadAll(resp.Body))
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		if strings.Contains(metrics, exp[1]) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	lines := make(map[string]bool)
+	for _, l := range strings.Split(metrics, "\n") {
+		lines[l] = true
+	}
+	for _, e := range exp {
+		if !lines[e] {
+			t.Errorf("missing metric %q in:\n%s", e, metrics)
+		}
+	}
+}
+
+func TestServer_MetricsRestartedTask(t *testing.T) {
+	s := OpenDefaultServer()
+	defer s.Close()
+
+	name := "testMetricsRestart"
+	dbrps := []kapacitor.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `
+stream
+	.from().measurement('test')
+	.httpOut('latest')
+`
+	if _, err := s.DefineTask(name, "stream", tick, dbrps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableTask(name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait until the metrics contain the line.
+	waitMetric := func(line string) {
+		var metrics string
+		for i := 0; i < 100; i++ {
+			resp, err := http.Get(s.URL() + "/metrics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			metrics = string(MustReadAll(resp.Body))
+			resp.Body.Close()
+			for _, l := range strings.Split(metrics, "\n") {
+				if l == line {
+					return
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("missing metric %q in:\n%s", line, metrics)
+	}
+	metric := `kapacitor_edges_collected{child="http_out2",parent="stream1",task="testMetricsRestart",type="stream"} %d`
+
+	v := url.Values{}
+	v.Add("precision", "s")
+	s.MustWrite("mydb", "myrp", "test value=1 0000000000\ntest value=1 0000000001\ntest value=1 0000000002\n", v)
+	waitMetric(fmt.Sprintf(metric, 3))
+
+	// The restarted task publishes new stats, the metrics must follow them.
+	for i := 1; i <= 3; i++ {
+		if _, err := s.DisableTask(name); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.EnableTask(name); err != nil {
+			t.Fatal(err)
+		}
+		points := ""
+		for j := 0; j < i; j++ {
+			points += fmt.Sprintf("test value=1 %010d\n", 10*i+j)
+		}
+		s.MustWrite("mydb", "myrp", points, v)
+		waitMetric(fmt.Sprintf(metric, i))
+	}
+
+	// The stats of a disabled task are not written.
+	if _, err := s.DisableTask(name); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(s.URL() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := string(MustReadAll(resp.Body))
+	resp.Body.Close()
+	if strings.Contains(metrics, `task="testMetricsRestart"`) {
+		t.Errorf("unexpected metrics of disabled task in:\n%s", metrics)
+	}
+}
+
 func TestServer_BatchTask(t *testing.T) {
 	c := NewConfig()
 	c.InfluxDB.Enabled = true
@@ -0,0 +1,118 @@
+package kapacitor
+
+import (
+	"container/heap"
+	"errors"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+const (
+	statDuplicates = "duplicates"
+	statLate       = "late"
+)
+
+type DedupNode struct {
+	node
+	d *pipeline.DedupNode
+
+	groups  map[models.GroupID]*dedupGroup
+	statMap *expvar.Map
+}
+
+// Create a new DedupNode which drops duplicate points.
+func newDedupNode(et *ExecutingTask, n *pipeline.DedupNode, l *log.Logger) (*DedupNode, error) {
+	if n.Size <= 0 {
+		return nil, errors.New("dedup size must be positive")
+	}
+	if n.Horizon < 0 {
+		return nil, errors.New("dedup horizon must not be negative")
+	}
+	sm := newNodeStatistics(et, n)
+	sm.Add(statDuplicates, 0)
+	sm.Add(statLate, 0)
+	dn := &DedupNode{
+		node:    node{Node: n, et: et, logger: l},
+		d:       n,
+		groups:  make(map[models.GroupID]*dedupGroup),
+		statMap: sm,
+	}
+	dn.node.runF = dn.runDedup
+	return dn, nil
+}
+
+func (d *DedupNode) runDedup([]byte) error {
+	for p, ok := d.ins[0].NextPoint(); ok; p, ok = d.ins[0].NextPoint() {
+		g := d.groups[p.Group]
+		if g == nil {
+			g = &dedupGroup{seen: make(map[dedupKey]bool)}
+			d.groups[p.Group] = g
+		}
+		if p.Time.Before(g.newest.Add(-d.d.Horizon)) {
+			d.statMap.Add(statLate, 1)
+			continue
+		}
+		key := dedupKey{
+			series: p.Name + "," + string(models.TagsToGroupID(models.SortedKeys(p.Tags), p.Tags)),
+			time:   p.Time.UnixNano(),
+		}
+		if g.seen[key] {
+			d.statMap.Add(statDuplicates, 1)
+			continue
+		}
+		g.add(key, p.Time, d.d.Horizon, int(d.d.Size))
+		for _, child := range d.outs {
+			err := child.CollectPoint(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Identifies a point by its series and time.
+type dedupKey struct {
+	series string
+	time   int64
+}
+
+// The points seen for a group, ordered by time so the oldest can be forgotten.
+type dedupGroup struct {
+	seen   map[dedupKey]bool
+	keys   dedupKeys
+	newest time.Time
+}
+
+// Remember a key and forget any keys that are older than the horizon
+// or that exceed the size of the group.
+func (g *dedupGroup) add(key dedupKey, t time.Time, horizon time.Duration, size int) {
+	g.seen[key] = true
+	heap.Push(&g.keys, key)
+	if t.After(g.newest) {
+		g.newest = t
+	}
+	oldest := g.newest.Add(-horizon).UnixNano()
+	for len(g.keys) > 0 && (len(g.keys) > size || g.keys[0].time < oldest) {
+		delete(g.seen, heap.Pop(&g.keys).(dedupKey))
+	}
+}
+
+// A min heap of keys by time.
+type dedupKeys []dedupKey
+
+func (k dedupKeys) Len() int            { return len(k) }
+func (k dedupKeys) Less(i, j int) bool  { return k[i].time < k[j].time }
+func (k dedupKeys) Swap(i, j int)       { k[i], k[j] = k[j], k[i] }
+func (k *dedupKeys) Push(x interface{}) { *k = append(*k, x.(dedupKey)) }
+func (k *dedupKeys) Pop() interface{} {
+	old := *k
+	n := len(old)
+	x := old[n-1]
+	*k = old[:n-1]
+	return x
+}
@@ -0,0 +1,44 @@
+package kapacitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	g := &dedupGroup{seen: make(map[dedupKey]bool)}
+	key := func(series string, s int64) dedupKey {
+		return dedupKey{series: series, time: time.Unix(s, 0).UnixNano()}
+	}
+
+	g.add(key("a", 1), time.Unix(1, 0), 5*time.Second, 3)
+	g.add(key("b", 1), time.Unix(1, 0), 5*time.Second, 3)
+	g.add(key("a", 2), time.Unix(2, 0), 5*time.Second, 3)
+	assert.True(g.seen[key("a", 1)])
+	assert.True(g.seen[key("b", 1)])
+	assert.True(g.seen[key("a", 2)])
+	assert.Equal(time.Unix(2, 0), g.newest)
+
+	// The size is exceeded so one of the oldest keys is forgotten.
+	g.add(key("a", 3), time.Unix(3, 0), 5*time.Second, 3)
+	assert.Equal(3, len(g.seen))
+	assert.Equal(3, len(g.keys))
+	assert.True(g.seen[key("a", 2)])
+	assert.True(g.seen[key("a", 3)])
+
+	// Keys older than the horizon are forgotten.
+	g.add(key("a", 7), time.Unix(7, 0), 5*time.Second, 3)
+	assert.False(g.seen[key("a", 1)])
+	assert.False(g.seen[key("b", 1)])
+	assert.True(g.seen[key("a", 2)])
+	assert.True(g.seen[key("a", 3)])
+	g.add(key("a", 8), time.Unix(8, 0), 5*time.Second, 3)
+	assert.False(g.seen[key("a", 2)])
+	assert.True(g.seen[key("a", 3)])
+	assert.True(g.seen[key("a", 8)])
+	assert.Equal(time.Unix(8, 0), g.newest)
+}
@@ -3,6 +3,7 @@ package kapacitor
 import (
 	"expvar"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -79,6 +80,9 @@ func Uptime() time.Duration {
 
 var expvarMu sync.Mutex
 
+// The order in which the statistics were created by their key, guarded by expvarMu.
+var statsOrder = make(map[string]int)
+
 // NewStatistics creates an expvar-based map. Within there "name" is the Measurement name, "tags" are the tags,
 // and values are placed at the key "values".
 // The "values" map is returned so that statistics can be set.
@@ -91,6 +95,7 @@ func NewStatistics(name string, tags map[string]string) *expvar.Map {
 	m := &expvar.Map{}
 	m.Init()
 	expvar.Publish(key, m)
+	statsOrder[key] = len(statsOrder) + 1
 
 	// Set the name
 	nameVar := &expvar.String{}
@@ -115,6 +120,18 @@ func NewStatistics(name string, tags map[string]string) *expvar.Map {
 	return statMap
 }
 
+type statsByOrder struct {
+	data  []StatsData
+	order []int
+}
+
+func (s statsByOrder) Len() int           { return len(s.data) }
+func (s statsByOrder) Less(i, j int) bool { return s.order[i] < s.order[j] }
+func (s statsByOrder) Swap(i, j int) {
+	s.data[i], s.data[j] = s.data[j], s.data[i]
+	s.order[i], s.order[j] = s.order[j], s.order[i]
+}
+
 type StatsData struct {
 	Name   string                 `json:"name"`
 	Tags   map[string]string      `json:"tags"`
@@ -122,8 +139,12 @@ type StatsData struct {
 }
 
 // Return all stats data from the expvars.
+// The stats created with NewStatistics are ordered by creation,
+// so the stats of a restarted task follow those of its previous run.
 func GetStatsData() ([]StatsData, error) {
 	allData := make([]StatsData, 0)
+	// The expvar key of each entry of allData.
+	keys := []string{""}
 	// Add Global expvars
 	globalData := StatsData{
 		Name:   "kapacitor",
@@ -202,9 +223,20 @@ func GetStatsData() ([]StatsData, error) {
 			}
 
 			allData = append(allData, data)
+			keys = append(keys, kv.Key)
 		}
 	})
 
+	// Lock expvarMu only after expvar.Do returns,
+	// NewStatistics holds it while publishing, which waits for expvar.Do.
+	order := make([]int, len(keys))
+	expvarMu.Lock()
+	for i, key := range keys {
+		order[i] = statsOrder[key]
+	}
+	expvarMu.Unlock()
+	sort.Stable(statsByOrder{data: allData, order: order})
+
 	// Add uptime to globalData
 	globalData.Values[UptimeVarName] = Uptime().Seconds()
 
@@ -0,0 +1,256 @@
+package kapacitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+// The smoothing parameters are fitted with a grid search in steps of 1/holtWintersFitSteps.
+const holtWintersFitSteps = 10
+
+type HoltWintersNode struct {
+	node
+	h *pipeline.HoltWintersNode
+}
+
+// Create a new HoltWintersNode which forecasts the values of a field.
+func newHoltWintersNode(et *ExecutingTask, n *pipeline.HoltWintersNode, l *log.Logger) (*HoltWintersNode, error) {
+	for _, p := range []float64{n.Alpha, n.Beta, n.Gamma} {
+		if p < 0 || p > 1 {
+			return nil, errors.New("smoothing parameters must be between 0 and 1")
+		}
+	}
+	hn := &HoltWintersNode{
+		node: node{Node: n, et: et, logger: l},
+		h:    n,
+	}
+	hn.node.runF = hn.runHoltWinters
+	return hn, nil
+}
+
+func (h *HoltWintersNode) runHoltWinters([]byte) error {
+	for b, ok := h.ins[0].NextBatch(); ok; b, ok = h.ins[0].NextBatch() {
+		values := make([]float64, 0, len(b.Points))
+		times := make([]time.Time, 0, len(b.Points))
+		for _, p := range b.Points {
+			v, ok := p.Fields[h.h.Field]
+			if !ok {
+				continue
+			}
+			f, ok := numToFloat(v)
+			if !ok {
+				h.logger.Printf("E! cannot apply holtWinters to type %T", v)
+				continue
+			}
+			values = append(values, f)
+			times = append(times, p.Time)
+		}
+		forecast, err := h.forecast(values)
+		if err != nil {
+			h.logger.Println("E! skipping batch:", err)
+			continue
+		}
+		interval := times[len(times)-1].Sub(times[len(times)-2])
+		last := times[len(times)-1]
+
+		if h.h.BreachFlag {
+			i, ok := breachIndex(values[len(values)-1], forecast, h.h.Threshold)
+			if !ok {
+				continue
+			}
+			breach := time.Duration(i+1) * interval
+			if i < 0 {
+				breach = 0
+			}
+			p := models.Point{
+				Name:       b.Name,
+				Group:      b.Group,
+				Dimensions: models.SortedKeys(b.Tags),
+				Tags:       b.Tags,
+				Fields:     models.Fields{h.h.As: breach.Seconds()},
+				Time:       b.TMax,
+			}
+			for _, child := range h.outs {
+				err := child.CollectPoint(p)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		out := models.Batch{
+			Name:   b.Name,
+			Group:  b.Group,
+			Tags:   b.Tags,
+			TMax:   last.Add(time.Duration(len(forecast)) * interval),
+			Points: make([]models.BatchPoint, len(forecast)),
+		}
+		for i, v := range forecast {
+			out.Points[i] = models.BatchPoint{
+				Time:   last.Add(time.Duration(i+1) * interval),
+				Fields: models.Fields{h.h.As: v},
+				Tags:   b.Tags,
+			}
+		}
+		for _, child := range h.outs {
+			err := child.CollectBatch(out)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fit the model to the values and forecast the next Horizon values.
+func (h *HoltWintersNode) forecast(values []float64) ([]float64, error) {
+	m := int(h.h.Seasonality)
+	min := 2
+	if m > 0 {
+		min = 2 * m
+	}
+	if len(values) < min {
+		return nil, fmt.Errorf("holtWinters needs at least %d points, got %d", min, len(values))
+	}
+
+	params := func(p float64) []float64 {
+		if p != 0 {
+			return []float64{p}
+		}
+		ps := make([]float64, holtWintersFitSteps-1)
+		for i := range ps {
+			ps[i] = float64(i+1) / holtWintersFitSteps
+		}
+		return ps
+	}
+	gammas := []float64{0}
+	if m > 0 {
+		gammas = params(h.h.Gamma)
+	}
+
+	var best *holtWinters
+	bestSSE := math.Inf(1)
+	for _, alpha := range params(h.h.Alpha) {
+		for _, beta := range params(h.h.Beta) {
+			for _, gamma := range gammas {
+				hw := newHoltWinters(m, alpha, beta, gamma)
+				if sse := hw.fit(values); sse < bestSSE {
+					best, bestSSE = hw, sse
+				}
+			}
+		}
+	}
+	// No model fits if the values contain NaN or infinite values.
+	if best == nil {
+		return nil, errors.New("holtWinters cannot fit values that are NaN or infinite")
+	}
+	return best.forecast(int(h.h.Horizon)), nil
+}
+
+// Return the index of the first forecasted value that reaches the threshold,
+// coming from the side of the last value.
+// An index of -1 means the last value is at the threshold.
+func breachIndex(last float64, forecast []float64, threshold float64) (int, bool) {
+	if last == threshold {
+		return -1, true
+	}
+	above := last > threshold
+	for i, v := range forecast {
+		if (above && v <= threshold) || (!above && v >= threshold) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// The state of an additive Holt-Winters model.
+type holtWinters struct {
+	alpha, beta, gamma float64
+	m                  int
+
+	level    float64
+	trend    float64
+	seasonal []float64
+	// The position within the season of the next value.
+	next int
+}
+
+func newHoltWinters(m int, alpha, beta, gamma float64) *holtWinters {
+	return &holtWinters{
+		alpha: alpha,
+		beta:  beta,
+		gamma: gamma,
+		m:     m,
+	}
+}
+
+// Initialize the model from the first season of the values, or the first value
+// if there is no seasonality, and return the number of values used.
+// The trend is initialized from the difference to the next season or value.
+func (hw *holtWinters) init(values []float64) int {
+	if hw.m == 0 {
+		hw.level = values[0]
+		hw.trend = values[1] - values[0]
+		return 1
+	}
+	m := float64(hw.m)
+	var first, second float64
+	for i := 0; i < hw.m; i++ {
+		first += values[i]
+		second += values[hw.m+i]
+	}
+	first /= m
+	second /= m
+	hw.trend = (second - first) / m
+	// The mean is the level in the middle of the season,
+	// the level is that of the last value of the season.
+	hw.level = first + hw.trend*(m-1)/2
+	hw.seasonal = make([]float64, hw.m)
+	for i := range hw.seasonal {
+		hw.seasonal[i] = values[i] - (first + hw.trend*(float64(i)-(m-1)/2))
+	}
+	return hw.m
+}
+
+// Fit the model to the values and return the sum of the squared errors
+// of the one step forecasts.
+func (hw *holtWinters) fit(values []float64) float64 {
+	sse := 0.0
+	for _, y := range values[hw.init(values):] {
+		s := 0.0
+		if hw.m > 0 {
+			s = hw.seasonal[hw.next]
+		}
+		f := hw.level + hw.trend + s
+		sse += (y - f) * (y - f)
+
+		level := hw.alpha*(y-s) + (1-hw.alpha)*(hw.level+hw.trend)
+		hw.trend = hw.beta*(level-hw.level) + (1-hw.beta)*hw.trend
+		hw.level = level
+		if hw.m > 0 {
+			hw.seasonal[hw.next] = hw.gamma*(y-level) + (1-hw.gamma)*s
+			hw.next = (hw.next + 1) % hw.m
+		}
+	}
+	return sse
+}
+
+// Forecast the next h values.
+func (hw *holtWinters) forecast(h int) []float64 {
+	forecast := make([]float64, h)
+	for i := range forecast {
+		s := 0.0
+		if hw.m > 0 {
+			s = hw.seasonal[(hw.next+i)%hw.m]
+		}
+		forecast[i] = hw.level + float64(i+1)*hw.trend + s
+	}
+	return forecast
+}
@@ -0,0 +1,70 @@
+package kapacitor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+func TestHoltWinters_Forecast(t *testing.T) {
+	testCases := []struct {
+		values      []float64
+		seasonality int
+		exp         []float64
+	}{
+		{
+			values: []float64{1, 2, 3, 4, 5, 6},
+			exp:    []float64{7, 8, 9},
+		},
+		{
+			values:      []float64{1, 5, 2, 6, 3, 7, 4, 8},
+			seasonality: 2,
+			exp:         []float64{5, 9, 6, 10},
+		},
+	}
+	for _, tc := range testCases {
+		hw := newHoltWinters(tc.seasonality, 0.5, 0.5, 0.5)
+		hw.fit(tc.values)
+		got := hw.forecast(len(tc.exp))
+		for i := range tc.exp {
+			if math.Abs(got[i]-tc.exp[i]) > 1e-9 {
+				t.Errorf("unexpected forecast of %v: got %v exp %v", tc.values, got, tc.exp)
+				break
+			}
+		}
+	}
+}
+
+func TestHoltWinters_ForecastNaN(t *testing.T) {
+	h := &HoltWintersNode{h: &pipeline.HoltWintersNode{Horizon: 3}}
+	for _, values := range [][]float64{
+		{1, 2, math.NaN(), 4},
+		{1, 2, math.Inf(1), 4},
+	} {
+		if _, err := h.forecast(values); err == nil {
+			t.Errorf("expected error forecasting %v", values)
+		}
+	}
+}
+
+func TestHoltWinters_BreachIndex(t *testing.T) {
+	testCases := []struct {
+		last      float64
+		forecast  []float64
+		threshold float64
+		exp       int
+		ok        bool
+	}{
+		{last: 1, forecast: []float64{2, 3, 4}, threshold: 3, exp: 1, ok: true},
+		{last: 5, forecast: []float64{4, 3, 2}, threshold: 2.5, exp: 2, ok: true},
+		{last: 3, forecast: []float64{4}, threshold: 3, exp: -1, ok: true},
+		{last: 1, forecast: []float64{2, 3, 4}, threshold: 5},
+	}
+	for _, tc := range testCases {
+		i, ok := breachIndex(tc.last, tc.forecast, tc.threshold)
+		if ok != tc.ok || (ok && i != tc.exp) {
+			t.Errorf("unexpected breach index for %v %v: got %d %v exp %d %v", tc.forecast, tc.threshold, i, ok, tc.exp, tc.ok)
+		}
+	}
+}
@@ -2,10 +2,15 @@ package kapacitor
 
 import (
 	"encoding/json"
+	"expvar"
+	"fmt"
 	"log"
 	"net/http"
 	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
@@ -14,22 +19,42 @@ import (
 	imodels "github.com/influxdb/influxdb/models"
 )
 
+const (
+	// The number of results buffered for each streaming client.
+	// Clients that fall further behind are disconnected.
+	httpOutStreamBuffer = 100
+
+	statSubscribers   = "subscribers"
+	statSlowConsumers = "slow_consumers"
+)
+
 type HTTPOutNode struct {
 	node
 	c              *pipeline.HTTPOutNode
 	result         influxql.Result
 	groupSeriesIdx map[models.GroupID]int
+	groups         []models.GroupID
+	history        map[models.GroupID]*rowHistory
 	endpoint       string
 	routes         []httpd.Route
+	subscribers    *subscribers
 	mu             sync.RWMutex
 }
 
 // Create a new  HTTPOutNode which caches the most recent item and exposes it over the HTTP API.
+// Each new result is also pushed to the clients streaming the endpoint.
 func newHTTPOutNode(et *ExecutingTask, n *pipeline.HTTPOutNode, l *log.Logger) (*HTTPOutNode, error) {
+	sm := newNodeStatistics(et, n)
+	sm.Add(statSubscribers, 0)
+	sm.Add(statSlowConsumers, 0)
 	hn := &HTTPOutNode{
 		node:           node{Node: n, et: et, logger: l},
 		c:              n,
 		groupSeriesIdx: make(map[models.GroupID]int),
+		subscribers:    newSubscribers(httpOutStreamBuffer, sm),
+	}
+	if n.RetainCount > 0 || n.RetainDuration > 0 {
+		hn.history = make(map[models.GroupID]*rowHistory)
 	}
 	et.registerOutput(hn.c.Endpoint, hn)
 	hn.node.runF = hn.runOut
@@ -44,10 +69,24 @@ func (h *HTTPOutNode) Endpoint() string {
 func (h *HTTPOutNode) runOut([]byte) error {
 
 	hndl := func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+			h.serveStream(w, req)
+			return
+		}
 		h.mu.RLock()
 		defer h.mu.RUnlock()
 
-		if b, err := json.Marshal(h.result); err != nil {
+		result := h.result
+		if h.history != nil || isHTTPOutQuery(req) {
+			q, err := parseHTTPOutQuery(req)
+			if err != nil {
+				httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+				return
+			}
+			result = h.queryResult(q)
+		}
+
+		if b, err := json.Marshal(result); err != nil {
 			httpd.HttpError(
 				w,
 				err.Error(),
@@ -68,6 +107,15 @@ func (h *HTTPOutNode) runOut([]byte) error {
 		Gzipped:     true,
 		Log:         true,
 		HandlerFunc: hndl,
+		Privilege:   httpd.ReadPrivilege,
+	}, {
+		Name:        h.Name() + "-stream",
+		Method:      "GET",
+		Pattern:     path.Join(p, "stream"),
+		Gzipped:     true,
+		Log:         true,
+		HandlerFunc: h.serveStream,
+		Privilege:   httpd.ReadPrivilege,
 	}}
 
 	h.endpoint = h.et.tm.HTTPDService.URL() + p
@@ -86,33 +134,339 @@ func (h *HTTPOutNode) runOut([]byte) error {
 	case pipeline.StreamEdge:
 		for p, ok := h.ins[0].NextPoint(); ok; p, ok = h.ins[0].NextPoint() {
 			row := models.PointToRow(p)
-			h.updateResultWithRow(p.Group, row)
+			h.updateResultWithRow(p.Group, p.Time, row)
 		}
 	case pipeline.BatchEdge:
 		for b, ok := h.ins[0].NextBatch(); ok; b, ok = h.ins[0].NextBatch() {
 			row := models.BatchToRow(b)
-			h.updateResultWithRow(b.Group, row)
+			h.updateResultWithRow(b.Group, b.TMax, row)
 		}
 	}
+	// No more results, end the streams.
+	h.subscribers.close()
 	return nil
 }
 
 // Update the result structure with a row.
-func (h *HTTPOutNode) updateResultWithRow(group models.GroupID, row *imodels.Row) {
+func (h *HTTPOutNode) updateResultWithRow(group models.GroupID, t time.Time, row *imodels.Row) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	idx, ok := h.groupSeriesIdx[group]
 	if !ok {
 		idx = len(h.result.Series)
 		h.groupSeriesIdx[group] = idx
+		h.groups = append(h.groups, group)
 		h.result.Series = append(h.result.Series, row)
 	} else {
 		h.result.Series[idx] = row
 	}
+	if h.history != nil {
+		rh := h.history[group]
+		if rh == nil {
+			rh = newRowHistory(int(h.c.RetainCount))
+			h.history[group] = rh
+		}
+		rh.add(t, row)
+		if h.c.RetainDuration > 0 {
+			rh.prune(t.Add(-h.c.RetainDuration))
+		}
+	}
+	if h.subscribers.len() > 0 {
+		b, err := json.Marshal(h.result)
+		if err != nil {
+			h.logger.Println("E! failed to encode result", err)
+			return
+		}
+		h.subscribers.publish(b)
+	}
+}
+
+// Stream the results as server-sent events, starting with the current result.
+func (h *HTTPOutNode) serveStream(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpd.HttpError(w, "streaming is not supported", true, http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe while holding the lock so no result is missed.
+	h.mu.RLock()
+	b, err := json.Marshal(h.result)
+	var results chan []byte
+	if err == nil {
+		results = h.subscribers.subscribe()
+	}
+	h.mu.RUnlock()
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	defer h.subscribers.unsubscribe(results)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+		select {
+		case b, ok = <-results:
+			if !ok {
+				// The node has stopped or the client was too slow.
+				return
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// The filters of a request for the cached data.
+type httpOutQuery struct {
+	tags  map[string]string
+	since time.Time
+	limit int
+}
+
+// Whether the request filters the results, other parameters such as
+// the cache busting parameters of browsers are ignored.
+func isHTTPOutQuery(req *http.Request) bool {
+	params := req.URL.Query()
+	for _, name := range []string{"group", "since", "limit"} {
+		if _, ok := params[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHTTPOutQuery(req *http.Request) (httpOutQuery, error) {
+	q := httpOutQuery{}
+	params := req.URL.Query()
+	if group := params.Get("group"); group != "" {
+		q.tags = make(map[string]string)
+		for _, pair := range strings.Split(group, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return q, fmt.Errorf("invalid group %q, must be comma separated tag=value pairs", group)
+			}
+			q.tags[kv[0]] = kv[1]
+		}
+	}
+	if since := params.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return q, fmt.Errorf("invalid since %q, must be an RFC3339 time", since)
+		}
+		q.since = t
+	}
+	if limit := params.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 {
+			return q, fmt.Errorf("invalid limit %q, must be a positive integer", limit)
+		}
+		q.limit = l
+	}
+	return q, nil
+}
+
+// Build a result from the retained rows of the groups matching the query.
+// The caller must hold the read lock.
+func (h *HTTPOutNode) queryResult(q httpOutQuery) influxql.Result {
+	result := influxql.Result{}
+	for i, group := range h.groups {
+		latest := h.result.Series[i]
+		if !matchTags(latest.Tags, q.tags) {
+			continue
+		}
+		var values [][]interface{}
+		if rh := h.history[group]; rh != nil {
+			rh.each(func(row *imodels.Row) {
+				values = append(values, row.Values...)
+			})
+		} else {
+			values = latest.Values
+		}
+		if !q.since.IsZero() {
+			filtered := make([][]interface{}, 0, len(values))
+			for _, v := range values {
+				if t, ok := v[0].(time.Time); ok && t.Before(q.since) {
+					continue
+				}
+				filtered = append(filtered, v)
+			}
+			values = filtered
+		}
+		if q.limit > 0 && len(values) > q.limit {
+			values = values[len(values)-q.limit:]
+		}
+		if len(values) == 0 {
+			continue
+		}
+		result.Series = append(result.Series, &imodels.Row{
+			Name:    latest.Name,
+			Tags:    latest.Tags,
+			Columns: latest.Columns,
+			Values:  values,
+		})
+	}
+	return result
+}
+
+// Whether the tags contain all of the expected tags.
+func matchTags(tags, exp map[string]string) bool {
+	for k, v := range exp {
+		if t, ok := tags[k]; !ok || t != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (h *HTTPOutNode) stopOut() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.et.tm.HTTPDService.DelRoutes(h.routes)
+	h.subscribers.close()
+}
+
+// The set of streaming clients of an endpoint.
+// Each client has a bounded buffer of results, a client whose buffer is full
+// is removed and its channel closed so the client is disconnected.
+type subscribers struct {
+	mu      sync.Mutex
+	buffer  int
+	chans   map[chan []byte]bool
+	closed  bool
+	statMap *expvar.Map
+}
+
+func newSubscribers(buffer int, statMap *expvar.Map) *subscribers {
+	return &subscribers{
+		buffer:  buffer,
+		chans:   make(map[chan []byte]bool),
+		statMap: statMap,
+	}
+}
+
+// Add a client, the returned channel is closed immediately if the set is closed.
+func (s *subscribers) subscribe() chan []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := make(chan []byte, s.buffer)
+	if s.closed {
+		close(c)
+		return c
+	}
+	s.chans[c] = true
+	s.statMap.Add(statSubscribers, 1)
+	return c
+}
+
+// Remove a client if it has not already been removed.
+func (s *subscribers) unsubscribe(c chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chans[c] {
+		s.remove(c)
+	}
+}
+
+// Send the data to all clients, removing those that are too slow.
+func (s *subscribers) publish(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.chans {
+		select {
+		case c <- b:
+		default:
+			s.remove(c)
+			s.statMap.Add(statSlowConsumers, 1)
+		}
+	}
+}
+
+func (s *subscribers) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.chans)
+}
+
+// Remove all clients and reject new ones.
+func (s *subscribers) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for c := range s.chans {
+		s.remove(c)
+	}
+}
+
+// The caller must hold the lock.
+func (s *subscribers) remove(c chan []byte) {
+	delete(s.chans, c)
+	close(c)
+	s.statMap.Add(statSubscribers, -1)
+}
+
+type timedRow struct {
+	time time.Time
+	row  *imodels.Row
+}
+
+// A ring buffer of the rows of a group, ordered by arrival.
+// If max is positive the oldest row is replaced once max rows are retained,
+// otherwise the buffer grows as needed and old rows are removed with prune.
+type rowHistory struct {
+	rows  []timedRow
+	first int
+	n     int
+	max   int
+}
+
+func newRowHistory(max int) *rowHistory {
+	return &rowHistory{max: max}
+}
+
+func (rh *rowHistory) add(t time.Time, row *imodels.Row) {
+	if rh.max > 0 && rh.n == rh.max {
+		rh.rows[rh.first] = timedRow{time: t, row: row}
+		rh.first = (rh.first + 1) % len(rh.rows)
+		return
+	}
+	if rh.n == len(rh.rows) {
+		size := 2 * len(rh.rows)
+		if size == 0 {
+			size = 1
+		}
+		if rh.max > 0 && size > rh.max {
+			size = rh.max
+		}
+		rows := make([]timedRow, size)
+		for i := 0; i < rh.n; i++ {
+			rows[i] = rh.rows[(rh.first+i)%len(rh.rows)]
+		}
+		rh.rows = rows
+		rh.first = 0
+	}
+	rh.rows[(rh.first+rh.n)%len(rh.rows)] = timedRow{time: t, row: row}
+	rh.n++
+}
+
+// Remove the rows older than t.
+func (rh *rowHistory) prune(t time.Time) {
+	for rh.n > 0 && rh.rows[rh.first].time.Before(t) {
+		rh.rows[rh.first] = timedRow{}
+		rh.first = (rh.first + 1) % len(rh.rows)
+		rh.n--
+	}
+}
+
+// Call f with each row from the oldest to the newest.
+func (rh *rowHistory) each(f func(row *imodels.Row)) {
+	for i := 0; i < rh.n; i++ {
+		f(rh.rows[(rh.first+i)%len(rh.rows)].row)
+	}
 }
@@ -0,0 +1,128 @@
+package kapacitor
+
+import (
+	"expvar"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	imodels "github.com/influxdb/influxdb/models"
+)
+
+func TestSubscribers(t *testing.T) {
+	sm := &expvar.Map{}
+	sm.Init()
+	s := newSubscribers(2, sm)
+
+	fast := s.subscribe()
+	slow := s.subscribe()
+	if exp, got := "2", sm.Get(statSubscribers).String(); got != exp {
+		t.Fatalf("unexpected subscribers got %s exp %s", got, exp)
+	}
+
+	s.publish([]byte("1"))
+	<-fast
+	s.publish([]byte("2"))
+	<-fast
+	// The slow subscriber has a full buffer and is removed.
+	s.publish([]byte("3"))
+	if exp, got := "1", sm.Get(statSlowConsumers).String(); got != exp {
+		t.Errorf("unexpected slow consumers got %s exp %s", got, exp)
+	}
+	if exp, got := "1", sm.Get(statSubscribers).String(); got != exp {
+		t.Errorf("unexpected subscribers got %s exp %s", got, exp)
+	}
+	// The buffered results are still received before the channel is closed.
+	for _, exp := range []string{"1", "2"} {
+		if b := <-slow; string(b) != exp {
+			t.Errorf("unexpected result got %s exp %s", b, exp)
+		}
+	}
+	if _, ok := <-slow; ok {
+		t.Error("expected slow subscriber to be closed")
+	}
+	if b := <-fast; string(b) != "3" {
+		t.Errorf("unexpected result got %s exp 3", b)
+	}
+
+	// Unsubscribing twice is safe.
+	s.unsubscribe(slow)
+	s.unsubscribe(fast)
+	s.unsubscribe(fast)
+	if exp, got := "0", sm.Get(statSubscribers).String(); got != exp {
+		t.Errorf("unexpected subscribers got %s exp %s", got, exp)
+	}
+
+	s.close()
+	if _, ok := <-s.subscribe(); ok {
+		t.Error("expected subscription to a closed set to be closed")
+	}
+}
+
+func TestRowHistory(t *testing.T) {
+	start := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	add := func(rh *rowHistory, names ...string) {
+		for _, name := range names {
+			rh.add(start, &imodels.Row{Name: name})
+			start = start.Add(time.Second)
+		}
+	}
+	names := func(rh *rowHistory) []string {
+		var n []string
+		rh.each(func(row *imodels.Row) {
+			n = append(n, row.Name)
+		})
+		return n
+	}
+
+	// A full buffer replaces the oldest row.
+	rh := newRowHistory(3)
+	add(rh, "a", "b")
+	if exp, got := []string{"a", "b"}, names(rh); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected rows got %v exp %v", got, exp)
+	}
+	add(rh, "c", "d", "e")
+	if exp, got := []string{"c", "d", "e"}, names(rh); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected rows got %v exp %v", got, exp)
+	}
+
+	// An unbounded buffer grows until old rows are pruned.
+	rh = newRowHistory(0)
+	add(rh, "a", "b", "c", "d", "e")
+	rh.prune(start.Add(-2 * time.Second))
+	add(rh, "f", "g")
+	if exp, got := []string{"d", "e", "f", "g"}, names(rh); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected rows got %v exp %v", got, exp)
+	}
+	rh.prune(start)
+	if got := names(rh); len(got) != 0 {
+		t.Errorf("unexpected rows got %v exp none", got)
+	}
+	add(rh, "h")
+	if exp, got := []string{"h"}, names(rh); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected rows got %v exp %v", got, exp)
+	}
+}
+
+func TestIsHTTPOutQuery(t *testing.T) {
+	testCases := []struct {
+		url string
+		exp bool
+	}{
+		{url: "/out", exp: false},
+		{url: "/out?_=123", exp: false},
+		{url: "/out?group=host=A", exp: true},
+		{url: "/out?_=123&since=1971-01-01T00:00:00Z", exp: true},
+		{url: "/out?limit=", exp: true},
+	}
+	for _, tc := range testCases {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isHTTPOutQuery(req); got != tc.exp {
+			t.Errorf("%s: unexpected query got %v exp %v", tc.url, got, tc.exp)
+		}
+	}
+}
@@ -61,6 +61,53 @@ batch
 	testBatcherWithOutput(t, "TestBatch_Derivative", script, 21*time.Second, er)
 }
 
+func TestBatch_Shift(t *testing.T) {
+
+	var script = `
+batch
+	.query('''
+		SELECT sum("value") as "value"
+		FROM "telegraf"."default".packets
+''')
+		.period(10s)
+		.every(10s)
+		.groupBy(time(2s))
+	.shift(1h)
+	.derivative('value')
+	.httpOut('TestBatch_Shift')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 1, 0, 0, 0, time.UTC),
+						0.5,
+					},
+					{
+						time.Date(1971, 1, 1, 1, 0, 2, 0, time.UTC),
+						0.5,
+					},
+					{
+						time.Date(1971, 1, 1, 1, 0, 4, 0, time.UTC),
+						0.5,
+					},
+					{
+						time.Date(1971, 1, 1, 1, 0, 6, 0, time.UTC),
+						0.5,
+					},
+				},
+			},
+		},
+	}
+
+	testBatcherWithOutput(t, "TestBatch_Shift", script, 21*time.Second, er)
+}
+
 func TestBatch_DerivativeUnit(t *testing.T) {
 
 	var script = `
@@ -197,6 +244,163 @@ batch
 	testBatcherWithOutput(t, "TestBatch_DerivativeNN", script, 21*time.Second, er)
 }
 
+func TestBatch_Aggregate(t *testing.T) {
+
+	var script = `
+batch
+	.query('''
+		SELECT "value"
+		FROM "telegraf"."default".packets
+''')
+		.period(10s)
+		.every(10s)
+	.aggregate()
+		.sum('value')
+		.median('value')
+		.min('value')
+		.last('value')
+		.first('other')
+	.httpOut('TestBatch_Aggregate')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "last", "median", "min", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 8, 0, time.UTC),
+					1004.0,
+					1002.0,
+					1000.0,
+					5010.0,
+				}},
+			},
+		},
+	}
+
+	testBatcherWithOutput(t, "TestBatch_Aggregate", script, 21*time.Second, er)
+}
+
+func TestBatch_ChangeDetect(t *testing.T) {
+
+	var script = `
+batch
+	.query('''
+		SELECT "value"
+		FROM "telegraf"."default".packets
+''')
+		.period(10s)
+		.every(10s)
+	.changeDetect('value')
+		.minDelta(2.0)
+	.httpOut('TestBatch_ChangeDetect')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC),
+						1000.0,
+					},
+					{
+						time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+						1002.0,
+					},
+					{
+						time.Date(1971, 1, 1, 0, 0, 8, 0, time.UTC),
+						1004.0,
+					},
+				},
+			},
+		},
+	}
+
+	testBatcherWithOutput(t, "TestBatch_ChangeDetect", script, 21*time.Second, er)
+}
+
+func TestBatch_HoltWinters(t *testing.T) {
+
+	var script = `
+batch
+	.query('''
+		SELECT "value"
+		FROM "telegraf"."default".packets
+''')
+		.period(10s)
+		.every(10s)
+	.holtWinters('value', 3, 0)
+		.as('forecast')
+	.httpOut('TestBatch_HoltWinters')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "forecast"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+						1005.0,
+					},
+					{
+						time.Date(1971, 1, 1, 0, 0, 12, 0, time.UTC),
+						1006.0,
+					},
+					{
+						time.Date(1971, 1, 1, 0, 0, 14, 0, time.UTC),
+						1007.0,
+					},
+				},
+			},
+		},
+	}
+
+	testBatcherWithOutput(t, "TestBatch_HoltWinters", script, 21*time.Second, er)
+}
+
+func TestBatch_HoltWintersBreach(t *testing.T) {
+
+	var script = `
+batch
+	.query('''
+		SELECT "value"
+		FROM "telegraf"."default".packets
+''')
+		.period(10s)
+		.every(10s)
+	.holtWinters('value', 10, 0)
+		.forecastBreach(1006.5)
+	.httpOut('TestBatch_HoltWintersBreach')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "time_to_breach"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 0, 0, 8, 0, time.UTC),
+						6.0,
+					},
+				},
+			},
+		},
+	}
+
+	testBatcherWithOutput(t, "TestBatch_HoltWintersBreach", script, 21*time.Second, er)
+}
+
 func TestBatch_SimpleMR(t *testing.T) {
 
 	var script = `
@@ -317,7 +521,7 @@ func testBatcher(t *testing.T, name, script string) (clock.Setter, *kapacitor.Ex
 	tm.Open()
 
 	// Create task
-	task, err := tm.NewTask(name, script, kapacitor.BatchTask, dbrps, 0)
+	task, err := tm.NewTask(name, script, kapacitor.BatchTask, dbrps, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
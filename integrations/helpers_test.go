@@ -2,6 +2,7 @@ package integrations
 
 import (
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/influxdata/kapacitor"
@@ -120,6 +122,48 @@ func (u UDFService) FunctionInfo(name string) (kapacitor.UDFProcessInfo, bool) {
 	return u.FunctionInfoFunc(name)
 }
 
+// A Kafka service that keeps the messages written to each topic.
+type MockKafkaService struct {
+	mu       sync.Mutex
+	messages map[string][]KafkaMessage
+}
+
+type KafkaMessage struct {
+	Key   string
+	Value string
+	Time  time.Time
+}
+
+func NewMockKafkaService() *MockKafkaService {
+	return &MockKafkaService{
+		messages: make(map[string][]KafkaMessage),
+	}
+}
+
+func (k *MockKafkaService) NewWriter(topic string, statMap *expvar.Map) (kapacitor.KafkaWriter, error) {
+	return kafkaWriter{k: k, topic: topic}, nil
+}
+
+func (k *MockKafkaService) Messages(topic string) []KafkaMessage {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.messages[topic]
+}
+
+type kafkaWriter struct {
+	k     *MockKafkaService
+	topic string
+}
+
+func (w kafkaWriter) Write(key, value []byte, t time.Time) error {
+	w.k.mu.Lock()
+	defer w.k.mu.Unlock()
+	w.k.messages[w.topic] = append(w.k.messages[w.topic], KafkaMessage{Key: string(key), Value: string(value), Time: t})
+	return nil
+}
+
+func (w kafkaWriter) Close() error { return nil }
+
 type taskStore struct{}
 
 func (ts taskStore) SaveSnapshot(name string, snapshot *kapacitor.TaskSnapshot) error { return nil }
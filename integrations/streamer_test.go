@@ -1,8 +1,10 @@
 package integrations
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -10,6 +12,8 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"text/template"
 	"time"
@@ -83,6 +87,293 @@ stream
 	testStreamerWithOutput(t, "TestStream_Derivative", script, 15*time.Second, er, nil, false)
 }
 
+func TestStream_HttpOutStream(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('packets')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.count('value'))
+	.httpOut('TestStream_HttpOutStream')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "count"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					10.0,
+				}},
+			},
+		},
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_HttpOutStream", script, nil)
+	defer tm.Close()
+
+	// The route is added once the node starts running.
+	endpoint := httpService.URL() + "/TestStream_HttpOutStream/TestStream_HttpOutStream"
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		var err error
+		resp, err = http.Get(endpoint + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		resp.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	events := make(chan []kapacitor.Result, 1)
+	go func() {
+		events <- readEvents(resp.Body)
+	}()
+
+	if err := fastForwardTask(clock, et, replayErr, tm, 15*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	// The stream ends once the task has finished.
+	results := <-events
+	if len(results) != 2 {
+		t.Fatalf("unexpected number of events got %d exp 2", len(results))
+	}
+	if eq, msg := compareResults(kapacitor.Result{}, results[0]); !eq {
+		t.Error(msg)
+	}
+	if eq, msg := compareResults(er, results[1]); !eq {
+		t.Error(msg)
+	}
+
+	// A stream of a finished task only has the current result.
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	results = readEvents(resp.Body)
+	if len(results) != 1 {
+		t.Fatalf("unexpected number of events got %d exp 1", len(results))
+	}
+	if eq, msg := compareResults(er, results[0]); !eq {
+		t.Error(msg)
+	}
+}
+
+// Read the results of the server-sent events until the stream ends.
+func readEvents(r io.Reader) []kapacitor.Result {
+	var results []kapacitor.Result
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+			results = append(results, kapacitor.ResultFromJSON(strings.NewReader(data)))
+		}
+	}
+	return results
+}
+
+func TestStream_HttpOutRetain(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('errors')
+	.groupBy('service')
+	.httpOut('TestStream_HttpOutRetain')
+		.retain(3)
+`
+	row := func(service string, values ...float64) *imodels.Row {
+		r := &imodels.Row{
+			Name:    "errors",
+			Tags:    map[string]string{"service": service},
+			Columns: []string{"time", "value"},
+		}
+		// The values are the last points of the data, one per second up to 4s.
+		for i, v := range values {
+			r.Values = append(r.Values, []interface{}{
+				time.Date(1971, 1, 1, 0, 0, 5-len(values)+i, 0, time.UTC),
+				v,
+			})
+		}
+		return r
+	}
+
+	testCases := []struct {
+		query string
+		er    kapacitor.Result
+	}{
+		{
+			query: "",
+			er: kapacitor.Result{Series: imodels.Rows{
+				row("cartA", 30, 40, 50),
+				row("login", 3, 4, 5),
+			}},
+		},
+		{
+			query: "?group=service=login&limit=2",
+			er: kapacitor.Result{Series: imodels.Rows{
+				row("login", 4, 5),
+			}},
+		},
+		{
+			query: "?since=1971-01-01T00:00:04Z",
+			er: kapacitor.Result{Series: imodels.Rows{
+				row("cartA", 50),
+				row("login", 5),
+			}},
+		},
+		{
+			query: "?group=service=front",
+			er:    kapacitor.Result{},
+		},
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_HttpOutRetain", script, nil)
+	defer tm.Close()
+	if err := fastForwardTask(clock, et, replayErr, tm, 6*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	output, err := et.GetOutput("TestStream_HttpOutRetain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		resp, err := http.Get(output.Endpoint() + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := kapacitor.ResultFromJSON(resp.Body)
+		resp.Body.Close()
+		if eq, msg := compareResults(tc.er, result); !eq {
+			t.Errorf("%q: %s", tc.query, msg)
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?since=yesterday", "?group=service"} {
+		resp, err := http.Get(output.Endpoint() + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: unexpected status code got %d exp %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestStream_Shift(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('packets')
+	.shift(-5s)
+	.derivative('value')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.mean('value'))
+	.shift(1h)
+	.httpOut('TestStream_Shift')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "mean"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 1, 0, 5, 0, time.UTC),
+					1.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Shift", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_ChangeDetect(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('cpu')
+	.changeDetect('value', 'state')
+		.minDelta(0.5)
+		.maxSilence(5s)
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.sum('value'))
+	.httpOut('TestStream_ChangeDetect')
+`
+	// Only the points at 0s, 2s, 3s and 8s are emitted.
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					5.9,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_ChangeDetect", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_Anomaly(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('cpu')
+	.anomaly('value')
+		.mad()
+		.history(6)
+		.as('score')
+	.window()
+		.period(10s)
+		.every(10s)
+	.where(lambda: "is_anomaly")
+	.mapReduce(influxql.max('score'))
+	.httpOut('TestStream_Anomaly')
+`
+	// The history of 10, 12, 10, 12, 10, 12 has a median of 11 and a MAD of 1.
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "max"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					0.6745 * 19,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Anomaly", script, 15*time.Second, er, nil, false)
+}
+
 func TestStream_DerivativeUnit(t *testing.T) {
 
 	var script = `
@@ -140,53 +431,307 @@ stream
 		},
 	}
 
-	testStreamerWithOutput(t, "TestStream_DerivativeNN", script, 15*time.Second, er, nil, false)
+	testStreamerWithOutput(t, "TestStream_DerivativeNN", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_DerivativeN(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('packets')
+	.derivative('value')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.mean('value'))
+	.httpOut('TestStream_DerivativeNN')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "packets",
+				Tags:    nil,
+				Columns: []string{"time", "mean"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					-99.7,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_DerivativeNN", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_Window(t *testing.T) {
+
+	var script = `
+var period = 10s
+var every = 10s
+stream
+	.from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	.window()
+		.period(period)
+		.every(every)
+	.httpOut('TestStream_Window')
+`
+
+	nums := []float64{
+		97.1,
+		92.6,
+		95.6,
+		93.1,
+		92.6,
+		95.8,
+		92.7,
+		96.0,
+		93.4,
+		95.3,
+	}
+
+	values := make([][]interface{}, len(nums))
+	for i, num := range nums {
+		values[i] = []interface{}{
+			time.Date(1971, 1, 1, 0, 0, i, 0, time.UTC),
+			"serverA",
+			"idle",
+			num,
+		}
+	}
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "host", "type", "value"},
+				Values:  values,
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Window", script, 13*time.Second, er, nil, false)
+}
+
+func TestStream_WindowCount(t *testing.T) {
+
+	var script = `
+stream
+	.from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	.window()
+		.periodCount(4)
+		.everyCount(3)
+	.httpOut('TestStream_WindowCount')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "host", "type", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 8, 0, time.UTC), "serverA", "idle", 93.4},
+					{time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC), "serverA", "idle", 95.3},
+					{time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC), "serverA", "idle", 96.4},
+					{time.Date(1971, 1, 1, 0, 0, 11, 0, time.UTC), "serverA", "idle", 95.1},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_WindowCount", script, 13*time.Second, er, nil, false)
+}
+
+func TestStream_WindowSession(t *testing.T) {
+
+	var script = `
+stream
+	.from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.groupBy('host')
+	.window()
+		.session(5s)
+	.httpOut('TestStream_WindowSession')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverA"},
+				Columns: []string{"time", "type", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC), "idle", 93.1},
+					{time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC), "idle", 92.6},
+				},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverB"},
+				Columns: []string{"time", "type", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC), "idle", 93.1},
+					{time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC), "idle", 92.6},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_WindowSession", script, 21*time.Second, er, nil, true)
+}
+
+func TestStream_WindowLateness(t *testing.T) {
+
+	var script = `
+stream
+	.from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+	.window()
+		.period(10s)
+		.every(10s)
+		.lateness(3s)
+	.httpOut('TestStream_WindowLateness')
+`
+
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "host", "type", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC), "serverA", "idle", 11.0},
+					{time.Date(1971, 1, 1, 0, 0, 11, 0, time.UTC), "serverA", "idle", 12.0},
+					{time.Date(1971, 1, 1, 0, 0, 12, 0, time.UTC), "serverA", "idle", 13.0},
+					{time.Date(1971, 1, 1, 0, 0, 13, 0, time.UTC), "serverA", "idle", 14.0},
+					{time.Date(1971, 1, 1, 0, 0, 15, 0, time.UTC), "serverA", "idle", 16.0},
+					{time.Date(1971, 1, 1, 0, 0, 17, 0, time.UTC), "serverA", "idle", 18.0},
+					{time.Date(1971, 1, 1, 0, 0, 18, 0, time.UTC), "serverA", "idle", 19.0},
+					{time.Date(1971, 1, 1, 0, 0, 19, 0, time.UTC), "serverA", "idle", 20.0},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_WindowLateness", script, 25*time.Second, er, nil, false)
 }
 
-func TestStream_DerivativeN(t *testing.T) {
+func TestStream_WindowLate(t *testing.T) {
 
 	var script = `
-stream
-	.from().measurement('packets')
-	.derivative('value')
+var w = stream
+	.from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
 	.window()
 		.period(10s)
 		.every(10s)
-	.mapReduce(influxql.mean('value'))
-	.httpOut('TestStream_DerivativeNN')
+		.lateness(3s)
+w.late()
+	.httpOut('TestStream_WindowLate')
 `
+
 	er := kapacitor.Result{
 		Series: imodels.Rows{
 			{
-				Name:    "packets",
-				Tags:    nil,
-				Columns: []string{"time", "mean"},
-				Values: [][]interface{}{[]interface{}{
-					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
-					-99.7,
-				}},
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverA", "type": "idle"},
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC), 10.0},
+				},
 			},
 		},
 	}
 
-	testStreamerWithOutput(t, "TestStream_DerivativeNN", script, 15*time.Second, er, nil, false)
+	testStreamerWithOutput(t, "TestStream_WindowLate", script, 25*time.Second, er, nil, false)
+
+	stats, err := kapacitor.GetStatsData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range stats {
+		if s.Name != "nodes" || s.Tags["task"] != "TestStream_WindowLate" || !strings.HasPrefix(s.Tags["node"], "window") {
+			continue
+		}
+		found = true
+		if exp, got := int64(1), s.Values["late"]; got != exp {
+			t.Errorf("unexpected late stat: got %v exp %v", got, exp)
+		}
+	}
+	if !found {
+		t.Error("missing stats for window node")
+	}
 }
 
-func TestStream_Window(t *testing.T) {
+func TestStream_WindowLateWithoutLateness(t *testing.T) {
+	var script = `
+var w = stream
+	.from()
+		.measurement('cpu')
+	.window()
+		.period(10s)
+		.every(10s)
+w.httpOut('TestStream_WindowLateWithoutLateness')
+w.late()
+	.httpOut('late')
+`
+
+	tm := kapacitor.NewTaskMaster(logService)
+	tm.HTTPDService = httpService
+	tm.TaskStore = taskStore{}
+	tm.DeadmanService = deadman{}
+	tm.Open()
+	defer tm.Close()
+
+	task, err := tm.NewTask("TestStream_WindowLateWithoutLateness", script, kapacitor.StreamTask, dbrps, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tm.StartTask(task)
+	if err == nil {
+		t.Fatal("expected error starting task")
+	}
+	if exp, got := "late can only be used with lateness", err.Error(); !strings.Contains(got, exp) {
+		t.Errorf("unexpected error: got %q exp %q", got, exp)
+	}
+}
+
+func TestStream_Modify(t *testing.T) {
 
 	var script = `
-var period = 10s
-var every = 10s
 stream
 	.from()
 		.database('dbname')
 		.retentionPolicy('rpname')
 		.measurement('cpu')
 		.where(lambda: "host" == 'serverA')
+		.groupBy('host')
+	.tags()
+		.rename('host', 'server')
+		.tagToField('type')
+	.fields()
+		.rename('value', 'usage')
+		.set('version', 2)
 	.window()
-		.period(period)
-		.every(every)
-	.httpOut('TestStream_Window')
+		.period(10s)
+		.every(10s)
+	.httpOut('TestStream_Modify')
 `
 
 	nums := []float64{
@@ -206,9 +751,9 @@ stream
 	for i, num := range nums {
 		values[i] = []interface{}{
 			time.Date(1971, 1, 1, 0, 0, i, 0, time.UTC),
-			"serverA",
 			"idle",
 			num,
+			2.0,
 		}
 	}
 
@@ -216,14 +761,14 @@ stream
 		Series: imodels.Rows{
 			{
 				Name:    "cpu",
-				Tags:    nil,
-				Columns: []string{"time", "host", "type", "value"},
+				Tags:    map[string]string{"server": "serverA"},
+				Columns: []string{"time", "type", "usage", "version"},
 				Values:  values,
 			},
 		},
 	}
 
-	testStreamerWithOutput(t, "TestStream_Window", script, 13*time.Second, er, nil, false)
+	testStreamerWithOutput(t, "TestStream_Modify", script, 13*time.Second, er, nil, false)
 }
 
 func TestStream_SimpleMR(t *testing.T) {
@@ -345,6 +890,65 @@ stream
 	testStreamerWithOutput(t, "TestStream_SimpleMR", script, 15*time.Second, er, nil, false)
 }
 
+func TestStream_WhereIn(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('cpu')
+	.where(lambda: "host" IN ['serverA', 'serverC'])
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.count('value'))
+	.httpOut('TestStream_SimpleMR')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "count"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					11.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_SimpleMR", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_VarWhereNotIn(t *testing.T) {
+
+	var script = `
+var servers = ['serverB']
+stream
+	.from().measurement('cpu')
+	.where(lambda: "host" NOT IN servers)
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.count('value'))
+	.httpOut('TestStream_SimpleMR')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "count"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					11.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_SimpleMR", script, 15*time.Second, er, nil, false)
+}
+
 func TestStream_GroupBy(t *testing.T) {
 
 	var script = `
@@ -393,6 +997,90 @@ stream
 	testStreamerWithOutput(t, "TestStream_GroupBy", script, 13*time.Second, er, nil, false)
 }
 
+func TestStream_KafkaOut(t *testing.T) {
+
+	var script = `
+var sums = stream
+	.from().measurement('errors')
+	.groupBy('service')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.sum('value'))
+
+sums.kafkaOut('errors_json')
+
+sums.kafkaOut('errors_line')
+	.format('line')
+	.partitionTag('service')
+`
+
+	// The same sums as TestStream_GroupBy.
+	sums := []struct {
+		service string
+		sec     int
+		sum     string
+	}{
+		{"cartA", 10, "47"},
+		{"login", 10, "45"},
+		{"front", 11, "32"},
+	}
+	expJSON := make(map[KafkaMessage]bool)
+	expLine := make(map[KafkaMessage]bool)
+	for _, s := range sums {
+		tm := time.Date(1971, 1, 1, 0, 0, s.sec, 0, time.UTC)
+		expJSON[KafkaMessage{
+			Key:   "service=" + s.service + ",",
+			Value: `{"name":"errors","time":"` + tm.Format(time.RFC3339) + `","tags":{"service":"` + s.service + `"},"fields":{"sum":` + s.sum + `}}`,
+			Time:  tm,
+		}] = true
+		expLine[KafkaMessage{
+			Key:   s.service,
+			Value: "errors,service=" + s.service + " sum=" + s.sum + " " + strconv.FormatInt(tm.UnixNano(), 10),
+			Time:  tm,
+		}] = true
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_KafkaOut", script, nil)
+	defer tm.Close()
+	k := tm.KafkaService.(*MockKafkaService)
+
+	if err := fastForwardTask(clock, et, replayErr, tm, 13*time.Second); err != nil {
+		t.Error(err)
+	}
+
+	for topic, exp := range map[string]map[KafkaMessage]bool{
+		"errors_json": expJSON,
+		"errors_line": expLine,
+	} {
+		got := make(map[KafkaMessage]bool)
+		for _, m := range k.Messages(topic) {
+			got[m] = true
+		}
+		if !reflect.DeepEqual(exp, got) {
+			t.Errorf("unexpected messages of topic %s:\ngot %v\nexp %v", topic, got, exp)
+		}
+	}
+}
+
+func TestStream_KafkaOutNotEnabled(t *testing.T) {
+	tm := kapacitor.NewTaskMaster(logService)
+	tm.HTTPDService = httpService
+	tm.TaskStore = taskStore{}
+	tm.DeadmanService = deadman{}
+	tm.Open()
+	defer tm.Close()
+
+	task, err := tm.NewTask("TestStream_KafkaOutNotEnabled", "stream.from().measurement('errors').kafkaOut('errors')", kapacitor.StreamTask, dbrps, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The task fails to start instead of failing when it writes the first message.
+	if _, err := tm.StartTask(task); err == nil {
+		t.Error("expected error starting a task with kafkaOut without the kafka service")
+	}
+}
+
 func TestStream_Join(t *testing.T) {
 
 	var script = `
@@ -464,6 +1152,67 @@ errorCounts.join(viewCounts)
 	testStreamerWithOutput(t, "TestStream_Join", script, 13*time.Second, er, nil, true)
 }
 
+func TestStream_JoinOn(t *testing.T) {
+
+	var script = `
+var cpu = stream
+			.from().measurement('cpu')
+			.groupBy('host', 'cpu')
+
+var mem = stream
+			.from().measurement('mem')
+			.groupBy('host')
+
+cpu.join(mem)
+		.as('cpu', 'mem')
+		.on('host')
+		.left()
+		.fill(0.0)
+	.eval(lambda: "cpu.value" + "mem.value")
+		.as('total')
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.sum('total'))
+	.httpOut('TestStream_JoinOn')
+`
+
+	// Host B has no mem points, so they are filled with 0.
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "A", "cpu": "0"},
+				Columns: []string{"time", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					110.0,
+				}},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "A", "cpu": "1"},
+				Columns: []string{"time", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					120.0,
+				}},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "B", "cpu": "0"},
+				Columns: []string{"time", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					30.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_JoinOn", script, 15*time.Second, er, nil, true)
+}
+
 func TestStream_JoinTolerance(t *testing.T) {
 
 	var script = `
@@ -662,6 +1411,94 @@ cpu.union(mem, disk)
 	testStreamerWithOutput(t, "TestStream_Union", script, 15*time.Second, er, nil, false)
 }
 
+func TestStream_Aggregate(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('cpu')
+	.where(lambda: "host" == 'serverA')
+	.window()
+		.period(10s)
+		.every(10s)
+	.aggregate()
+		.count('value')
+		.mean('value').as('mean_value')
+		.max('value')
+		.percentile('value', 50.0)
+		.spread('value')
+	.httpOut('TestStream_Aggregate')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "count", "max", "mean_value", "percentile", "spread"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					10.0,
+					98.0,
+					94.0,
+					93.0,
+					7.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Aggregate", script, 15*time.Second, er, nil, false)
+}
+
+func TestStream_Dedup(t *testing.T) {
+
+	var script = `
+stream
+	.from().measurement('cpu')
+	.dedup()
+	.window()
+		.period(10s)
+		.every(10s)
+	.mapReduce(influxql.sum('value'))
+	.httpOut('TestStream_Dedup')
+`
+	er := kapacitor.Result{
+		Series: imodels.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					15.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Dedup", script, 15*time.Second, er, nil, false)
+
+	stats, err := kapacitor.GetStatsData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range stats {
+		if s.Name != "nodes" || s.Tags["task"] != "TestStream_Dedup" || !strings.HasPrefix(s.Tags["node"], "dedup") {
+			continue
+		}
+		found = true
+		if exp, got := int64(2), s.Values["duplicates"]; got != exp {
+			t.Errorf("unexpected duplicates stat: got %v exp %v", got, exp)
+		}
+		if exp, got := int64(2), s.Values["late"]; got != exp {
+			t.Errorf("unexpected late stat: got %v exp %v", got, exp)
+		}
+	}
+	if !found {
+		t.Error("missing stats for dedup node")
+	}
+}
+
 func TestStream_Aggregations(t *testing.T) {
 
 	type testCase struct {
@@ -2294,10 +3131,11 @@ func testStreamer(
 	tm.UDFService = udfService
 	tm.TaskStore = taskStore{}
 	tm.DeadmanService = deadman{}
+	tm.KafkaService = NewMockKafkaService()
 	tm.Open()
 
 	//Create the task
-	task, err := tm.NewTask(name, script, kapacitor.StreamTask, dbrps, 0)
+	task, err := tm.NewTask(name, script, kapacitor.StreamTask, dbrps, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
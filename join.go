@@ -3,6 +3,7 @@ package kapacitor
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,9 +18,12 @@ type JoinNode struct {
 	j             *pipeline.JoinNode
 	fill          influxql.FillOption
 	fillValue     interface{}
+	dimensions    []string
 	groups        map[models.GroupID]*group
 	mu            sync.Mutex
 	runningGroups sync.WaitGroup
+	// The index of the parent whose points are all kept, -1 for inner and full outer joins.
+	required int
 }
 
 // Create a new  JoinNode, which takes pairs from parent streams combines them into a single point.
@@ -41,8 +45,14 @@ func newJoinNode(et *ExecutingTask, n *pipeline.JoinNode, l *log.Logger) (*JoinN
 	}
 
 	jn := &JoinNode{
-		j:    n,
-		node: node{Node: n, et: et, logger: l},
+		j:        n,
+		node:     node{Node: n, et: et, logger: l},
+		required: -1,
+	}
+	if len(n.Dimensions) > 0 {
+		jn.dimensions = make([]string, len(n.Dimensions))
+		copy(jn.dimensions, n.Dimensions)
+		sort.Strings(jn.dimensions)
 	}
 	// Set fill
 	switch fill := n.Fill.(type) {
@@ -61,6 +71,15 @@ func newJoinNode(et *ExecutingTask, n *pipeline.JoinNode, l *log.Logger) (*JoinN
 	default:
 		jn.fill = influxql.NoFill
 	}
+	// Set the kept parent of left and right joins
+	if n.LeftFlag {
+		jn.required = 0
+	} else if n.RightFlag {
+		jn.required = len(n.Parents()) - 1
+	}
+	if jn.required >= 0 && jn.fill == influxql.NoFill {
+		jn.fill = influxql.NullFill
+	}
 	jn.node.runF = jn.runJoin
 	return jn, nil
 }
@@ -100,12 +119,16 @@ func (j *JoinNode) runJoin([]byte) error {
 
 // safely get the group for the point or create one if it doesn't exist.
 func (j *JoinNode) getGroup(p models.PointInterface) *group {
+	id := p.PointGroup()
+	if len(j.dimensions) > 0 {
+		id = models.TagsToGroupID(j.dimensions, p.PointTags())
+	}
 	j.mu.Lock()
 	defer j.mu.Unlock()
-	group := j.groups[p.PointGroup()]
+	group := j.groups[id]
 	if group == nil {
 		group = newGroup(len(j.ins), j)
-		j.groups[p.PointGroup()] = group
+		j.groups[id] = group
 		j.runningGroups.Add(1)
 		go group.run()
 	}
@@ -117,24 +140,26 @@ func (j *JoinNode) emitJoinedSet(set *joinset) error {
 	if set.name == "" {
 		set.name = set.First().PointName()
 	}
-	switch j.Wants() {
-	case pipeline.StreamEdge:
-		p, ok := set.JoinIntoPoint()
-		if ok {
-			for _, out := range j.outs {
-				err := out.CollectPoint(p)
-				if err != nil {
-					return err
+	for _, values := range set.combinations() {
+		switch j.Wants() {
+		case pipeline.StreamEdge:
+			p, ok := set.JoinIntoPoint(values)
+			if ok {
+				for _, out := range j.outs {
+					err := out.CollectPoint(p)
+					if err != nil {
+						return err
+					}
 				}
 			}
-		}
-	case pipeline.BatchEdge:
-		b, ok := set.JoinIntoBatch()
-		if ok {
-			for _, out := range j.outs {
-				err := out.CollectBatch(b)
-				if err != nil {
-					return err
+		case pipeline.BatchEdge:
+			b, ok := set.JoinIntoBatch(values)
+			if ok {
+				for _, out := range j.outs {
+					err := out.CollectBatch(b)
+					if err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -155,6 +180,8 @@ type group struct {
 	oldestTime time.Time
 	j          *JoinNode
 	points     chan srcPoint
+	// The newest time that was joined because of the timeout.
+	flushed time.Time
 }
 
 func newGroup(i int, j *JoinNode) *group {
@@ -178,16 +205,20 @@ func (g *group) run() {
 // emit the oldest set if we have collected enough data.
 func (g *group) collect(i int, p models.PointInterface) {
 	t := p.PointTime().Round(g.j.j.Tolerance)
+	if !g.flushed.IsZero() && !t.After(g.flushed) {
+		g.j.logger.Println("D! dropping point that arrived after the join timeout", t)
+		return
+	}
 	if t.Before(g.oldestTime) || g.oldestTime.IsZero() {
 		g.oldestTime = t
 	}
 
 	set := g.sets[t]
 	if set == nil {
-		set = newJoinset(g.j.j.StreamName, g.j.fill, g.j.fillValue, g.j.j.Names, g.j.j.Tolerance, t, g.j.logger)
+		set = newJoinset(g.j.j.StreamName, g.j.fill, g.j.fillValue, g.j.j.Names, g.j.required, g.j.j.Tolerance, t, g.j.logger)
 		g.sets[t] = set
 	}
-	set.Add(i, p)
+	set.Add(i, p, len(g.j.dimensions) > 0)
 
 	// Update head
 	g.head[i] = t
@@ -206,6 +237,14 @@ func (g *group) collect(i int, p models.PointInterface) {
 	if emit {
 		g.emit()
 	}
+
+	// Join any sets that have waited longer than the timeout for the other parents.
+	if g.j.j.Timeout > 0 {
+		for len(g.sets) > 0 && t.Sub(g.oldestTime) > g.j.j.Timeout {
+			g.flushed = g.oldestTime
+			g.emit()
+		}
+	}
 }
 
 // emit a set and update the oldestTime.
@@ -235,10 +274,11 @@ type joinset struct {
 	fill      influxql.FillOption
 	fillValue interface{}
 	prefixes  []string
+	required  int
 
 	time      time.Time
 	tolerance time.Duration
-	values    []models.PointInterface
+	values    [][]models.PointInterface
 
 	expected int
 	size     int
@@ -254,6 +294,7 @@ func newJoinset(
 	fill influxql.FillOption,
 	fillValue interface{},
 	prefixes []string,
+	required int,
 	tolerance time.Duration,
 	time time.Time,
 	l *log.Logger,
@@ -264,8 +305,9 @@ func newJoinset(
 		fill:      fill,
 		fillValue: fillValue,
 		prefixes:  prefixes,
+		required:  required,
 		expected:  expected,
-		values:    make([]models.PointInterface, expected),
+		values:    make([][]models.PointInterface, expected),
 		first:     expected,
 		time:      time,
 		logger:    l,
@@ -273,31 +315,84 @@ func newJoinset(
 }
 
 // add a point to the set from a given parent index.
-func (js *joinset) Add(i int, v models.PointInterface) {
+// If many is false the point replaces any previous point from the parent.
+func (js *joinset) Add(i int, v models.PointInterface, many bool) {
 	if i < js.first {
 		js.first = i
 	}
-	js.values[i] = v
+	if many {
+		js.values[i] = append(js.values[i], v)
+	} else {
+		js.values[i] = []models.PointInterface{v}
+	}
 	js.size++
 }
 
 // a valid point in the set
 func (js *joinset) First() models.PointInterface {
-	return js.values[js.first]
+	return js.values[js.first][0]
 }
 
-// join all points into a single point
-func (js *joinset) JoinIntoPoint() (models.Point, bool) {
-	fields := make(models.Fields, js.size*len(js.First().PointFields()))
-	for i, p := range js.values {
+// Return every combination of one point from each parent,
+// a missing parent is nil in each combination.
+func (js *joinset) combinations() [][]models.PointInterface {
+	combos := [][]models.PointInterface{make([]models.PointInterface, js.expected)}
+	for i, vs := range js.values {
+		switch len(vs) {
+		case 0:
+		case 1:
+			for _, c := range combos {
+				c[i] = vs[0]
+			}
+		default:
+			next := make([][]models.PointInterface, 0, len(combos)*len(vs))
+			for _, c := range combos {
+				for _, v := range vs {
+					nc := make([]models.PointInterface, len(c))
+					copy(nc, c)
+					nc[i] = v
+					next = append(next, nc)
+				}
+			}
+			combos = next
+		}
+	}
+	return combos
+}
+
+// Return the first point of a combination and the point with the most dimensions,
+// whose group and tags are used for the joined point.
+func firstAndMostSpecific(values []models.PointInterface) (first, specific models.PointInterface) {
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if first == nil {
+			first = v
+		}
+		if specific == nil || len(v.PointDimensions()) > len(specific.PointDimensions()) {
+			specific = v
+		}
+	}
+	return
+}
+
+// join a combination of points into a single point
+func (js *joinset) JoinIntoPoint(values []models.PointInterface) (models.Point, bool) {
+	first, specific := firstAndMostSpecific(values)
+	fields := make(models.Fields, len(values)*len(first.PointFields()))
+	for i, p := range values {
 		if p == nil {
+			if i == js.required {
+				return models.Point{}, false
+			}
 			switch js.fill {
 			case influxql.NullFill:
-				for k := range js.First().PointFields() {
+				for k := range first.PointFields() {
 					fields[js.prefixes[i]+"."+k] = nil
 				}
 			case influxql.NumberFill:
-				for k := range js.First().PointFields() {
+				for k := range first.PointFields() {
 					fields[js.prefixes[i]+"."+k] = js.fillValue
 				}
 			default:
@@ -312,9 +407,9 @@ func (js *joinset) JoinIntoPoint() (models.Point, bool) {
 	}
 	p := models.Point{
 		Name:       js.name,
-		Group:      js.First().PointGroup(),
-		Tags:       js.First().PointTags(),
-		Dimensions: js.First().PointDimensions(),
+		Group:      specific.PointGroup(),
+		Tags:       specific.PointTags(),
+		Dimensions: specific.PointDimensions(),
 		Time:       js.time,
 		Fields:     fields,
 	}
@@ -322,23 +417,28 @@ func (js *joinset) JoinIntoPoint() (models.Point, bool) {
 	return p, true
 }
 
-// join all batches the set into a single batch
-func (js *joinset) JoinIntoBatch() (models.Batch, bool) {
+// join a combination of batches into a single batch
+func (js *joinset) JoinIntoBatch(values []models.PointInterface) (models.Batch, bool) {
+	if js.required >= 0 && values[js.required] == nil {
+		return models.Batch{}, false
+	}
+	_, specific := firstAndMostSpecific(values)
 	newBatch := models.Batch{
 		Name:  js.name,
-		Group: js.First().PointGroup(),
-		Tags:  js.First().PointTags(),
+		Group: specific.PointGroup(),
+		Tags:  specific.PointTags(),
 		TMax:  js.time,
 	}
 	empty := make([]bool, js.expected)
 	emptyCount := 0
 	indexes := make([]int, js.expected)
 	var fieldNames []string
+BATCH_POINT:
 	for emptyCount < js.expected {
 		set := make([]*models.BatchPoint, js.expected)
 		setTime := time.Time{}
 		count := 0
-		for i, batch := range js.values {
+		for i, batch := range values {
 			if empty[i] {
 				continue
 			}
@@ -378,6 +478,10 @@ func (js *joinset) JoinIntoBatch() (models.Batch, bool) {
 		fields := make(models.Fields, js.expected*len(fieldNames))
 		for i, bp := range set {
 			if bp == nil {
+				if i == js.required {
+					// skip points missing from the kept parent
+					continue BATCH_POINT
+				}
 				switch js.fill {
 				case influxql.NullFill:
 					for _, k := range fieldNames {
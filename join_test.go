@@ -0,0 +1,79 @@
+package kapacitor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdb/influxdb/influxql"
+)
+
+func TestJoinGroup_Timeout(t *testing.T) {
+	testCases := []struct {
+		timeout time.Duration
+		pending int
+	}{
+		// Without a timeout the sets wait for the other parent.
+		{timeout: 0, pending: 10},
+		// Only the sets within the timeout of the newest point are pending.
+		{timeout: 3 * time.Second, pending: 4},
+	}
+	for _, tc := range testCases {
+		pn := &pipeline.JoinNode{Names: []string{"a", "b"}, Timeout: tc.timeout}
+		j := &JoinNode{
+			node:     node{Node: pn, logger: logger},
+			j:        pn,
+			fill:     influxql.NullFill,
+			required: 0,
+		}
+		g := newGroup(2, j)
+		for i := 0; i < 10; i++ {
+			g.collect(0, models.Point{Time: time.Unix(int64(i), 0)})
+		}
+		if got := len(g.sets); got != tc.pending {
+			t.Errorf("timeout %v: unexpected pending sets: got %d exp %d", tc.timeout, got, tc.pending)
+		}
+		if tc.timeout > 0 {
+			// Points for times that were already joined are dropped.
+			g.collect(1, models.Point{Time: time.Unix(2, 0)})
+			if got := len(g.sets); got != tc.pending {
+				t.Errorf("timeout %v: late point was not dropped", tc.timeout)
+			}
+		}
+	}
+}
+
+func TestJoinset_Combinations(t *testing.T) {
+	js := newJoinset("", influxql.NullFill, nil, []string{"cpu", "mem", "disk"}, 1, 0, time.Unix(0, 0), logger)
+	cpu0 := models.Point{Name: "cpu", Dimensions: []string{"cpu", "host"}, Fields: models.Fields{"value": 1.0}}
+	cpu1 := models.Point{Name: "cpu", Dimensions: []string{"cpu", "host"}, Fields: models.Fields{"value": 2.0}}
+	mem := models.Point{Name: "mem", Dimensions: []string{"host"}, Fields: models.Fields{"value": 10.0}}
+	js.Add(0, cpu0, true)
+	js.Add(0, cpu1, true)
+	js.Add(1, mem, true)
+
+	combos := js.combinations()
+	if len(combos) != 2 {
+		t.Fatalf("unexpected number of combinations: got %d exp 2", len(combos))
+	}
+	for i, c := range combos {
+		p, ok := js.JoinIntoPoint(c)
+		if !ok {
+			t.Fatalf("combination %d was not joined", i)
+		}
+		exp := models.Fields{"cpu.value": float64(i + 1), "mem.value": 10.0, "disk.value": nil}
+		if !reflect.DeepEqual(p.Fields, exp) {
+			t.Errorf("unexpected fields for combination %d: got %v exp %v", i, p.Fields, exp)
+		}
+		if len(p.Dimensions) != 2 {
+			t.Errorf("expected the dimensions of the cpu point, got %v", p.Dimensions)
+		}
+	}
+
+	// The right parent is required.
+	if _, ok := js.JoinIntoPoint([]models.PointInterface{cpu0, nil, nil}); ok {
+		t.Error("expected a combination without the required parent to be dropped")
+	}
+}
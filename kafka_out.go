@@ -0,0 +1,121 @@
+package kapacitor
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	imodels "github.com/influxdb/influxdb/models"
+)
+
+const statEncodeErrors = "encode_errors"
+
+type KafkaOutNode struct {
+	node
+	k       *pipeline.KafkaOutNode
+	writer  KafkaWriter
+	statMap *expvar.Map
+}
+
+// Create a new KafkaOutNode which writes each point as a message to a Kafka topic.
+func newKafkaOutNode(et *ExecutingTask, n *pipeline.KafkaOutNode, l *log.Logger) (*KafkaOutNode, error) {
+	if n.Topic == "" {
+		return nil, errors.New("must specify a kafka topic")
+	}
+	switch n.Format {
+	case "json", "line":
+	default:
+		return nil, fmt.Errorf("invalid kafka message format %q, must be json or line", n.Format)
+	}
+	if et.tm.KafkaService == nil {
+		return nil, errors.New("kafka service is not enabled")
+	}
+	sm := newNodeStatistics(et, n)
+	sm.Add(statEncodeErrors, 0)
+	kn := &KafkaOutNode{
+		node:    node{Node: n, et: et, logger: l},
+		k:       n,
+		statMap: sm,
+	}
+	kn.node.runF = kn.runOut
+	return kn, nil
+}
+
+func (k *KafkaOutNode) runOut([]byte) error {
+	defer func() {
+		if k.writer != nil {
+			k.writer.Close()
+		}
+	}()
+	switch k.Wants() {
+	case pipeline.StreamEdge:
+		for p, ok := k.ins[0].NextPoint(); ok; p, ok = k.ins[0].NextPoint() {
+			if err := k.write(p.Name, p.Group, p.Tags, p.Fields, p.Time); err != nil {
+				return err
+			}
+		}
+	case pipeline.BatchEdge:
+		for b, ok := k.ins[0].NextBatch(); ok; b, ok = k.ins[0].NextBatch() {
+			for _, p := range b.Points {
+				if err := k.write(b.Name, b.Group, p.Tags, p.Fields, p.Time); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (k *KafkaOutNode) write(name string, group models.GroupID, tags models.Tags, fields models.Fields, t time.Time) error {
+	if k.writer == nil {
+		w, err := k.et.tm.KafkaService.NewWriter(k.k.Topic, k.statMap)
+		if err != nil {
+			return err
+		}
+		k.writer = w
+	}
+
+	value, err := k.encode(name, tags, fields, t)
+	if err != nil {
+		k.statMap.Add(statEncodeErrors, 1)
+		k.logger.Println("E! failed to encode message", err)
+		return nil
+	}
+	var key []byte
+	if k.k.PartitionTag != "" {
+		if v, ok := tags[k.k.PartitionTag]; ok {
+			key = []byte(v)
+		}
+	} else if group != "" {
+		key = []byte(group)
+	}
+	return k.writer.Write(key, value, t)
+}
+
+func (k *KafkaOutNode) encode(name string, tags models.Tags, fields models.Fields, t time.Time) ([]byte, error) {
+	switch k.k.Format {
+	case "line":
+		p, err := imodels.NewPoint(name, imodels.Tags(tags), imodels.Fields(fields), t)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(p.String()), nil
+	default:
+		return json.Marshal(struct {
+			Name   string        `json:"name"`
+			Time   time.Time     `json:"time"`
+			Tags   models.Tags   `json:"tags"`
+			Fields models.Fields `json:"fields"`
+		}{
+			Name:   name,
+			Time:   t,
+			Tags:   tags,
+			Fields: fields,
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package kapacitor
+
+import (
+	"log"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type LateNode struct {
+	node
+	l *pipeline.LateNode
+}
+
+// Create a new LateNode which emits the late points of its parent window as a stream.
+func newLateNode(et *ExecutingTask, n *pipeline.LateNode, l *log.Logger) (*LateNode, error) {
+	ln := &LateNode{
+		node: node{Node: n, et: et, logger: l},
+		l:    n,
+	}
+	ln.node.runF = ln.runLate
+	return ln, nil
+}
+
+func (l *LateNode) runLate([]byte) error {
+	for b, ok := l.ins[0].NextBatch(); ok; b, ok = l.ins[0].NextBatch() {
+		for _, bp := range b.Points {
+			p := models.Point{
+				Name:       b.Name,
+				Group:      b.Group,
+				Dimensions: models.SortedKeys(b.Tags),
+				Tags:       bp.Tags,
+				Fields:     bp.Fields,
+				Time:       bp.Time,
+			}
+			for _, child := range l.outs {
+				err := child.CollectPoint(p)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
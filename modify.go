@@ -0,0 +1,164 @@
+package kapacitor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type ModifyNode struct {
+	node
+	m *pipeline.ModifyNode
+}
+
+// Create a new ModifyNode which renames, deletes and sets fields or tags.
+func newModifyNode(et *ExecutingTask, n *pipeline.ModifyNode, l *log.Logger) (*ModifyNode, error) {
+	switch n.Target {
+	case pipeline.ModifyFields, pipeline.ModifyTags:
+	default:
+		return nil, fmt.Errorf("unknown modify target %q", n.Target)
+	}
+	mn := &ModifyNode{
+		node: node{Node: n, et: et, logger: l},
+		m:    n,
+	}
+	mn.node.runF = mn.runModify
+	return mn, nil
+}
+
+func (m *ModifyNode) runModify([]byte) error {
+	switch m.Wants() {
+	case pipeline.StreamEdge:
+		for p, ok := m.ins[0].NextPoint(); ok; p, ok = m.ins[0].NextPoint() {
+			var dims []string
+			p.Fields, p.Tags, dims = m.modify(p.Fields, p.Tags, p.Dimensions)
+			p = setGroupOnPoint(p, false, dims)
+			for _, child := range m.outs {
+				err := child.CollectPoint(p)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case pipeline.BatchEdge:
+		for b, ok := m.ins[0].NextBatch(); ok; b, ok = m.ins[0].NextBatch() {
+			points := make([]models.BatchPoint, len(b.Points))
+			for i, p := range b.Points {
+				p.Fields, p.Tags, _ = m.modify(p.Fields, p.Tags, nil)
+				points[i] = p
+			}
+			b.Points = points
+			// The tags of the batch are its group by dimensions.
+			_, tags, dims := m.modify(models.Fields{}, b.Tags, models.SortedKeys(b.Tags))
+			b.Tags = make(map[string]string, len(dims))
+			for _, dim := range dims {
+				b.Tags[dim] = tags[dim]
+			}
+			b.Group = models.TagsToGroupID(dims, b.Tags)
+			for _, child := range m.outs {
+				err := child.CollectBatch(b)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Apply the operations to copies of the fields and tags,
+// and return them with the updated sorted dimensions.
+func (m *ModifyNode) modify(fields models.Fields, tags map[string]string, dimensions []string) (models.Fields, map[string]string, []string) {
+	fields = fields.Copy()
+	newTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		newTags[k] = v
+	}
+	tags = newTags
+	dims := make([]string, len(dimensions))
+	copy(dims, dimensions)
+
+	onTags := m.m.Target == pipeline.ModifyTags
+	for _, op := range m.m.Operations {
+		switch op.Op {
+		case pipeline.ModifyRename:
+			if onTags {
+				if v, ok := tags[op.Name]; ok {
+					delete(tags, op.Name)
+					tags[op.To] = v
+					dims = renameDimension(dims, op.Name, op.To)
+				}
+			} else if v, ok := fields[op.Name]; ok {
+				delete(fields, op.Name)
+				fields[op.To] = v
+			}
+		case pipeline.ModifyDelete:
+			if onTags {
+				delete(tags, op.Name)
+				dims = renameDimension(dims, op.Name, "")
+			} else {
+				delete(fields, op.Name)
+			}
+		case pipeline.ModifySet:
+			if onTags {
+				tags[op.Name] = op.Value.(string)
+			} else {
+				fields[op.Name] = op.Value
+			}
+		case pipeline.ModifyFieldToTag:
+			if v, ok := fields[op.Name]; ok {
+				delete(fields, op.Name)
+				tags[op.Name] = formatTagValue(v)
+			}
+		case pipeline.ModifyTagToField:
+			if v, ok := tags[op.Name]; ok {
+				delete(tags, op.Name)
+				fields[op.Name] = v
+				dims = renameDimension(dims, op.Name, "")
+			}
+		}
+	}
+	sort.Strings(dims)
+	return fields, tags, dims
+}
+
+// Rename a dimension, or remove it if the new name is empty.
+// A dimension renamed to the name of another dimension replaces it.
+func renameDimension(dims []string, old, new string) []string {
+	for i, dim := range dims {
+		if dim != old {
+			continue
+		}
+		dims = append(dims[:i], dims[i+1:]...)
+		if new == "" {
+			return dims
+		}
+		for _, d := range dims {
+			if d == new {
+				return dims
+			}
+		}
+		return append(dims, new)
+	}
+	return dims
+}
+
+// Format a field value as a tag value.
+func formatTagValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,67 @@
+package kapacitor
+
+import (
+	"testing"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModifyNode_Tags(t *testing.T) {
+	assert := assert.New(t)
+
+	n := (&pipeline.ModifyNode{Target: pipeline.ModifyTags}).
+		Rename("dc", "datacenter").
+		Delete("region").
+		TagToField("path").
+		Set("env", "prod").
+		FieldToTag("status").
+		Rename("missing", "other")
+	m := &ModifyNode{m: n}
+
+	fields := models.Fields{"value": 1.0, "status": int64(200)}
+	tags := map[string]string{"host": "serverA", "dc": "east", "region": "us", "path": "/a"}
+	newFields, newTags, dims := m.modify(fields, tags, []string{"dc", "host", "region"})
+
+	assert.Equal(models.Fields{"value": 1.0, "path": "/a"}, newFields)
+	assert.Equal(map[string]string{"host": "serverA", "datacenter": "east", "env": "prod", "status": "200"}, newTags)
+	assert.Equal([]string{"datacenter", "host"}, dims)
+
+	// The original fields and tags are not modified.
+	assert.Equal(models.Fields{"value": 1.0, "status": int64(200)}, fields)
+	assert.Equal("east", tags["dc"])
+}
+
+func TestModifyNode_RenameOntoDimension(t *testing.T) {
+	assert := assert.New(t)
+
+	n := (&pipeline.ModifyNode{Target: pipeline.ModifyTags}).
+		Rename("dc", "host")
+	m := &ModifyNode{m: n}
+
+	tags := map[string]string{"host": "serverA", "dc": "east"}
+	_, newTags, dims := m.modify(models.Fields{}, tags, []string{"dc", "host"})
+
+	assert.Equal(map[string]string{"host": "east"}, newTags)
+	assert.Equal([]string{"host"}, dims)
+	assert.Equal(models.GroupID("host=east,"), models.TagsToGroupID(dims, newTags))
+}
+
+func TestModifyNode_Fields(t *testing.T) {
+	assert := assert.New(t)
+
+	n := (&pipeline.ModifyNode{Target: pipeline.ModifyFields}).
+		Rename("value", "duration").
+		Delete("tmp").
+		Set("version", int64(2)).
+		FieldToTag("ratio")
+	m := &ModifyNode{m: n}
+
+	fields := models.Fields{"value": 1.5, "tmp": true, "ratio": 0.25}
+	newFields, newTags, dims := m.modify(fields, map[string]string{"host": "serverA"}, []string{"host"})
+
+	assert.Equal(models.Fields{"duration": 1.5, "version": int64(2)}, newFields)
+	assert.Equal(map[string]string{"host": "serverA", "ratio": "0.25"}, newTags)
+	assert.Equal([]string{"host"}, dims)
+}
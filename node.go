@@ -2,6 +2,7 @@ package kapacitor
 
 import (
 	"bytes"
+	"expvar"
 	"fmt"
 	"log"
 	"runtime"
@@ -103,6 +104,15 @@ func (n *node) stop() {
 
 }
 
+// Create the statistics of a node that reports more than the counts of its edges.
+func newNodeStatistics(et *ExecutingTask, n pipeline.Node) *expvar.Map {
+	tags := map[string]string{
+		"task": et.Task.Name,
+		"node": n.Name(),
+	}
+	return NewStatistics("nodes", tags)
+}
+
 // no-op snapshot
 func (n *node) snapshot() (b []byte, err error) { return }
 
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"fmt"
+)
+
+// Compute several aggregates of each batch in a single pass.
+// The results of all the aggregates are emitted as the fields of a single point,
+// with the time of the batch.
+//
+// Example:
+//    stream
+//        .from().measurement('cpu')
+//        .groupBy('host')
+//        .window()
+//            .period(1m)
+//            .every(1m)
+//        .aggregate()
+//            .mean('usage_idle').as('idle')
+//            .max('usage_user')
+//            .percentile('usage_system', 99.0)
+//        ...
+//
+// Each aggregate is named after its method, i.e. 'max' and 'percentile' above,
+// use As to give it a different name.
+//
+// Points that do not have the field of an aggregate are skipped by that aggregate.
+// Only count, first and last accept non numeric values.
+//
+// NOTE: Aggregate can only be applied to batch edges, window stream edges first.
+type AggregateNode struct {
+	chainnode
+
+	// The aggregates to compute.
+	// tick:ignore
+	Aggregates []*Aggregate
+}
+
+// A single aggregate of an AggregateNode.
+// tick:ignore
+type Aggregate struct {
+	// The name of the aggregate method, i.e. 'mean'.
+	Method string
+	// The field to aggregate.
+	Field string
+	// The name of the field for the result.
+	As string
+	// The percentile, only used by the 'percentile' method.
+	Percentile float64
+}
+
+func newAggregateNode() *AggregateNode {
+	return &AggregateNode{
+		chainnode: newBasicChainNode("aggregate", BatchEdge, StreamEdge),
+	}
+}
+
+func (a *AggregateNode) add(method, field string) *AggregateNode {
+	a.Aggregates = append(a.Aggregates, &Aggregate{
+		Method: method,
+		Field:  field,
+		As:     method,
+	})
+	return a
+}
+
+// Rename the result of the previous aggregate.
+// tick:property
+func (a *AggregateNode) As(name string) *AggregateNode {
+	if len(a.Aggregates) == 0 {
+		panic("as must follow an aggregate method")
+	}
+	a.Aggregates[len(a.Aggregates)-1].As = name
+	return a
+}
+
+// Count the number of points that have the field.
+// tick:property
+func (a *AggregateNode) Count(field string) *AggregateNode {
+	return a.add("count", field)
+}
+
+// Compute the sum of the field.
+// tick:property
+func (a *AggregateNode) Sum(field string) *AggregateNode {
+	return a.add("sum", field)
+}
+
+// Compute the mean of the field.
+// tick:property
+func (a *AggregateNode) Mean(field string) *AggregateNode {
+	return a.add("mean", field)
+}
+
+// Compute the median of the field.
+// tick:property
+func (a *AggregateNode) Median(field string) *AggregateNode {
+	return a.add("median", field)
+}
+
+// Select the minimum value of the field.
+// tick:property
+func (a *AggregateNode) Min(field string) *AggregateNode {
+	return a.add("min", field)
+}
+
+// Select the maximum value of the field.
+// tick:property
+func (a *AggregateNode) Max(field string) *AggregateNode {
+	return a.add("max", field)
+}
+
+// Compute the difference between the maximum and minimum values of the field.
+// tick:property
+func (a *AggregateNode) Spread(field string) *AggregateNode {
+	return a.add("spread", field)
+}
+
+// Compute the sample standard deviation of the field.
+// tick:property
+func (a *AggregateNode) Stddev(field string) *AggregateNode {
+	return a.add("stddev", field)
+}
+
+// Select the first value of the field.
+// tick:property
+func (a *AggregateNode) First(field string) *AggregateNode {
+	return a.add("first", field)
+}
+
+// Select the last value of the field.
+// tick:property
+func (a *AggregateNode) Last(field string) *AggregateNode {
+	return a.add("last", field)
+}
+
+// Select the value of the field at the given percentile, between 0 and 100.
+// tick:property
+func (a *AggregateNode) Percentile(field string, p float64) *AggregateNode {
+	if p < 0 || p > 100 {
+		panic(fmt.Sprintf("percentile must be between 0 and 100, got %v", p))
+	}
+	a.add("percentile", field)
+	a.Aggregates[len(a.Aggregates)-1].Percentile = p
+	return a
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+// Score how anomalous the values of a field are compared to the history of their group.
+// Each point is emitted with the score in the field named by As and
+// an 'is_anomaly' boolean field that is true when the absolute score exceeds the threshold.
+//
+// The available algorithms are:
+//
+//    * zscore: The number of standard deviations the value is from the mean of the last History values. This is the default.
+//    * mad: The modified z-score of the value using the median and median absolute deviation of the last History values,
+//           which is robust to outliers in the history.
+//    * seasonal: The number of standard deviations the value is from the mean of the values
+//                of the same hour of the week during the last Weeks weeks.
+//
+// Example:
+//    stream
+//        .from().measurement('requests')
+//        .groupBy('host')
+//        .anomaly('value')
+//            .mad()
+//            .history(500)
+//            .threshold(3.5)
+//        .where(lambda: "is_anomaly")
+//        ...
+//
+// Points are scored against the history before they are added to it.
+// The score is 0 until there is enough history, at least two values, or when the history has no variation.
+// The history of each group is kept in the snapshots of the task.
+type AnomalyNode struct {
+	chainnode
+
+	// The field to score.
+	// tick:ignore
+	Field string
+
+	// The algorithm used to compute the score.
+	// tick:ignore
+	Algorithm string
+
+	// The number of previous values used by the zscore and mad algorithms.
+	// Default: 100
+	History int64
+
+	// The number of previous weeks used by the seasonal algorithm.
+	// tick:ignore
+	Weeks int64
+
+	// The absolute score above which a value is an anomaly.
+	// Default: 3.0
+	Threshold float64
+
+	// The name of the score field.
+	// Default: 'anomaly_score'
+	As string
+}
+
+func newAnomalyNode(wants EdgeType, field string) *AnomalyNode {
+	return &AnomalyNode{
+		chainnode: newBasicChainNode("anomaly", wants, wants),
+		Field:     field,
+		Algorithm: "zscore",
+		History:   100,
+		Threshold: 3.0,
+		As:        "anomaly_score",
+	}
+}
+
+// Score values by their z-score over the last History values.
+// tick:property
+func (a *AnomalyNode) Zscore() *AnomalyNode {
+	a.Algorithm = "zscore"
+	return a
+}
+
+// Score values by their modified z-score, using the median absolute deviation
+// of the last History values.
+// tick:property
+func (a *AnomalyNode) Mad() *AnomalyNode {
+	a.Algorithm = "mad"
+	return a
+}
+
+// Score values by their z-score over the values of the same hour of the week
+// during the given number of previous weeks.
+// tick:property
+func (a *AnomalyNode) Seasonal(weeks int64) *AnomalyNode {
+	if weeks < 2 {
+		panic("seasonal needs at least 2 weeks")
+	}
+	a.Algorithm = "seasonal"
+	a.Weeks = weeks
+	return a
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Emit points only when the value of a field changes.
+// The last emitted values are tracked per group, a point is emitted
+// if the value of any of the fields differs from the last emitted value.
+// The first point of each group is always emitted.
+//
+// Example:
+//    stream
+//        .from().measurement('packets')
+//        .groupBy('host')
+//        .changeDetect('state', 'value')
+//            .minDelta(0.5)
+//            .maxSilence(10m)
+//        ...
+//
+// Emit a point whenever the state of a host changes or its value changes by at least 0.5,
+// and at least once every 10 minutes even if nothing changed.
+//
+// For batch edges the points of each batch are filtered in the same way,
+// changes are tracked across batches.
+type ChangeDetectNode struct {
+	chainnode
+
+	// The fields to check for changes.
+	// Not named Fields, a field of that name would hide the fields method
+	// and a fields node could not be chained after a changeDetect node.
+	// tick:ignore
+	FieldList []string
+
+	// The minimum absolute difference from the last emitted value
+	// for a change of a numeric field to be emitted.
+	// Non numeric fields are emitted on any change.
+	// Default: 0, any change is emitted.
+	MinDelta float64
+
+	// The maximum time between emitted points of a group.
+	// If no change has been emitted for MaxSilence the next point is emitted anyway.
+	// Default: 0, points are only emitted on changes.
+	MaxSilence time.Duration
+}
+
+func newChangeDetectNode(wants EdgeType, fields []string) *ChangeDetectNode {
+	return &ChangeDetectNode{
+		chainnode: newBasicChainNode("change_detect", wants, wants),
+		FieldList: fields,
+	}
+}
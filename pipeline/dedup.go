@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Drop duplicate points from a stream.
+// A point is a duplicate if a point of the same series, i.e. the same
+// measurement and tags, with the same time has already been seen.
+// The first point seen is kept and any later duplicates are dropped,
+// the fields of the points are not compared.
+//
+// Example:
+//    stream
+//        .from().measurement('cpu')
+//        .dedup()
+//            .horizon(10s)
+//            .size(10000)
+//        ...
+//
+// The points of each group are remembered until they are older than
+// the horizon relative to the newest point of the group.
+// Points older than that are dropped, since they can no longer be checked
+// for duplicates.
+//
+// The number of dropped points is reported in the 'duplicates' and 'late'
+// statistics of the node.
+//
+// NOTE: Dedup can only be applied to stream edges.
+type DedupNode struct {
+	chainnode
+
+	// How far behind the newest point of a group a point may be and still be accepted.
+	// With the default of 0 any point older than the newest point of its group is dropped.
+	Horizon time.Duration
+
+	// The maximum number of points remembered per group.
+	// Once it is reached the oldest points are forgotten,
+	// so older duplicates may no longer be detected.
+	// Default: 1000
+	Size int64
+}
+
+func newDedupNode() *DedupNode {
+	return &DedupNode{
+		chainnode: newBasicChainNode("dedup", StreamEdge, StreamEdge),
+		Size:      1000,
+	}
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+// Forecast the values of a field using the Holt-Winters method of triple exponential smoothing.
+// Each batch is fitted independently and a batch of forecasted points is emitted,
+// the points are spaced by the interval between the last two points of the batch.
+//
+// Example:
+//    batch
+//        .query('SELECT sum("value") FROM "telegraf"."default".requests')
+//            .period(7d)
+//            .every(1h)
+//            .groupBy(time(1h))
+//        .holtWinters('sum', 24, 24)
+//        ...
+//
+// Forecast the next day of hourly points of a series that has a daily seasonality.
+// Use a seasonality of 0 for series without seasonality.
+//
+// The smoothing parameters are fitted to each batch by minimizing the squared error of
+// the one step forecasts, unless they are set explicitly.
+//
+// With forecastBreach the node instead emits a single point per batch,
+// with the time until the forecast reaches the threshold, see ForecastBreach.
+//
+// NOTE: HoltWinters can only be applied to batch edges.
+type HoltWintersNode struct {
+	chainnode
+
+	// The field to forecast.
+	// tick:ignore
+	Field string
+
+	// The number of points to forecast.
+	// tick:ignore
+	Horizon int64
+
+	// The number of points in a season, 0 means no seasonality.
+	// tick:ignore
+	Seasonality int64
+
+	// The name of the forecasted field.
+	// Default is the name of the field used.
+	// When forecastBreach is used the default is 'time_to_breach'.
+	As string
+
+	// The smoothing parameter of the level, between 0 and 1.
+	// Default: 0, fitted to each batch.
+	Alpha float64
+	// The smoothing parameter of the trend, between 0 and 1.
+	// Default: 0, fitted to each batch.
+	Beta float64
+	// The smoothing parameter of the seasonality, between 0 and 1.
+	// Default: 0, fitted to each batch.
+	Gamma float64
+
+	// The threshold of forecastBreach.
+	// tick:ignore
+	Threshold float64
+
+	// Whether to emit the time until the threshold is reached.
+	// tick:ignore
+	BreachFlag bool
+}
+
+func newHoltWintersNode(field string, horizon, seasonality int64) *HoltWintersNode {
+	if horizon <= 0 {
+		panic("horizon must be positive")
+	}
+	if seasonality < 0 {
+		panic("seasonality must not be negative")
+	}
+	return &HoltWintersNode{
+		chainnode:   newBasicChainNode("holt_winters", BatchEdge, BatchEdge),
+		Field:       field,
+		Horizon:     horizon,
+		Seasonality: seasonality,
+		As:          field,
+	}
+}
+
+// Instead of the forecasted points emit a single point per batch,
+// with the number of seconds from the last point of the batch until
+// the forecast first reaches the threshold, in a field named 'time_to_breach' unless As is set.
+// The threshold is reached when the forecast crosses it from the side of the last value.
+// No point is emitted if the threshold is not reached within the horizon.
+//
+// The point has the time of the batch, so the result can be used with an AlertNode.
+//
+// Example:
+//    ...
+//    .holtWinters('used_percent', 48, 0)
+//        .forecastBreach(90.0)
+//    .alert()
+//        .crit(lambda: "time_to_breach" < 3600)
+//
+// NOTE: The output of the node is a stream edge when forecastBreach is used,
+// so it must be called before any other nodes are chained.
+// tick:property
+func (h *HoltWintersNode) ForecastBreach(threshold float64) *HoltWintersNode {
+	if len(h.Children()) > 0 {
+		panic("forecastBreach must be used before chaining other nodes")
+	}
+	h.Threshold = threshold
+	h.BreachFlag = true
+	if h.As == h.Field {
+		h.As = "time_to_breach"
+	}
+	h.provides = StreamEdge
+	return h
+}
@@ -1,5 +1,9 @@
 package pipeline
 
+import (
+	"time"
+)
+
 // An HTTPOutNode caches the most recent data for each group it has received.
 //
 // The cached data is available at the given endpoint.
@@ -7,6 +11,18 @@ package pipeline
 // For example if the task endpoint is at "/api/v1/task/<task_name>" and endpoint is
 // "top10", then the data can be requested from "/api/v1/task/<task_name>/top10".
 //
+// The data can also be streamed as server-sent events, either from the endpoint with
+// the "Accept: text/event-stream" header or from "/api/v1/task/<task_name>/top10/stream".
+// Each event is the complete cached data, sent whenever a group is updated.
+// Clients that do not keep up with the events are disconnected.
+//
+// A history of the data of each group can be kept with the retain property.
+// The endpoint then returns all retained data, which can be filtered with the parameters:
+//
+//    group    comma separated tag=value pairs the group must have, e.g. "host=A,region=west"
+//    since    an RFC3339 time, only data at or after the time is returned
+//    limit    the maximum number of most recent values returned for each group
+//
 // Example:
 //    stream
 //        .window()
@@ -22,6 +38,15 @@ type HTTPOutNode struct {
 	// The relative path where the cached data is exposed
 	// tick:ignore
 	Endpoint string
+
+	// The number of results retained for each group.
+	// tick:ignore
+	RetainCount int64
+
+	// How long the results of each group are retained,
+	// relative to the time of the most recent result of the group.
+	// tick:ignore
+	RetainDuration time.Duration
 }
 
 func newHTTPOutNode(wants EdgeType, endpoint string) *HTTPOutNode {
@@ -34,3 +59,40 @@ func newHTTPOutNode(wants EdgeType, endpoint string) *HTTPOutNode {
 		Endpoint: endpoint,
 	}
 }
+
+// Retain the history of results of each group instead of only the most recent result,
+// either a number of results or a duration.
+//
+// Example:
+//    stream
+//        .groupBy('host')
+//        .window()
+//            .period(10s)
+//            .every(10s)
+//        .mapReduce(influxql.mean('value'))
+//        .httpOut('mean')
+//            // Keep the last hour of results for each host.
+//            .retain(1h)
+//
+// The last 10 results of host A can then be requested with "/api/v1/task/<task_name>/mean?group=host=A&limit=10".
+//
+// tick:property
+func (h *HTTPOutNode) Retain(retain interface{}) *HTTPOutNode {
+	switch r := retain.(type) {
+	case int64:
+		if r <= 0 {
+			panic("retain count must be positive")
+		}
+		h.RetainCount = r
+		h.RetainDuration = 0
+	case time.Duration:
+		if r <= 0 {
+			panic("retain duration must be positive")
+		}
+		h.RetainDuration = r
+		h.RetainCount = 0
+	default:
+		panic("must pass int64 or duration to retain")
+	}
+	return h
+}
@@ -17,6 +17,12 @@ import (
 // Aliases are used to prefix all fields from the respective nodes.
 //
 // The join can be an inner or outer join, see the JoinNode.Fill property.
+// Left and right joins keep all the points of the first or last parent,
+// see JoinNode.Left and JoinNode.Right.
+//
+// By default points are joined with the points of the same group.
+// Use JoinNode.On to join on a subset of the dimensions, i.e. to join
+// the points of each cpu of a host with the points of the host.
 //
 // Example:
 //    var errors = stream
@@ -43,13 +49,25 @@ import (
 // and then transformed to calculate a combined field.
 type JoinNode struct {
 	chainnode
-	// The alias names of the two parents.
+	// The alias names of the parents.
 	// Note:
-	//       Names[1] corresponds to the left  parent
-	//       Names[0] corresponds to the right parent
+	//       Names[0] corresponds to the left parent, the node join was called on.
+	//       The last name corresponds to the right parent, the last node passed to join.
 	// tick:ignore
 	Names []string
 
+	// The dimensions to join on.
+	// tick:ignore
+	Dimensions []string
+
+	// Whether all points of the left parent are kept.
+	// tick:ignore
+	LeftFlag bool
+
+	// Whether all points of the right parent are kept.
+	// tick:ignore
+	RightFlag bool
+
 	// The name of this new joined data stream.
 	// If empty the name of the left parent is used.
 	StreamName string
@@ -67,7 +85,18 @@ type JoinNode struct {
 	//   - none - (default) skip rows where a point is missing, inner join.
 	//   - null - fill missing points with null, full outer join.
 	//   - Any numerical value - fill fields with given value, full outer join.
+	//
+	// For left and right joins the fill option fills the missing points of the other parents,
+	// with none the missing fields are null.
 	Fill interface{}
+
+	// The maximum time to wait for the points of the other parents.
+	// Once a parent has a point that is newer than a pending point by more than the timeout,
+	// the pending point is joined with the points received so far according to the fill option.
+	// The timeout is relative to the times of the points not the wall clock,
+	// any points that arrive for a time that has already been joined are dropped.
+	// Default: 0, wait until all parents have points newer than the pending point.
+	Timeout time.Duration
 }
 
 func newJoinNode(e EdgeType, parents []Node) *JoinNode {
@@ -91,3 +120,47 @@ func (j *JoinNode) As(names ...string) *JoinNode {
 	j.Names = names
 	return j
 }
+
+// Join the points on the given dimensions, instead of the groups of the points.
+// A point is joined with the points of the other parents that have the same values for the dimensions,
+// so each parent may contribute multiple points to a time, i.e. one for each cpu of a host.
+// A joined point is emitted for each combination of the points,
+// it has the group and tags of the point with the most dimensions.
+//
+// Example:
+//    var cpu = stream
+//        .from().measurement('cpu')
+//        .groupBy('host', 'cpu')
+//    var mem = stream
+//        .from().measurement('mem')
+//        .groupBy('host')
+//    cpu.join(mem)
+//        .as('cpu', 'mem')
+//        .on('host')
+//    ...
+//
+// tick:property
+func (j *JoinNode) On(dims ...string) *JoinNode {
+	j.Dimensions = dims
+	return j
+}
+
+// Perform a left join, all points of the left parent, the node join was called on, are kept.
+// Points of the left parent without a matching point of the other parents are filled
+// according to the Fill property.
+// tick:property
+func (j *JoinNode) Left() *JoinNode {
+	j.LeftFlag = true
+	j.RightFlag = false
+	return j
+}
+
+// Perform a right join, all points of the right parent, the last node passed to join, are kept.
+// Points of the right parent without a matching point of the other parents are filled
+// according to the Fill property.
+// tick:property
+func (j *JoinNode) Right() *JoinNode {
+	j.RightFlag = true
+	j.LeftFlag = false
+	return j
+}
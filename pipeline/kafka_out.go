@@ -0,0 +1,53 @@
+package pipeline
+
+// Writes the data to a Kafka topic as it is received.
+// The connection to the Kafka cluster is configured in the [kafka] section of the configuration.
+//
+// Each point is a message, the points of a batch are written as separate messages.
+// The messages are either JSON objects, the default, or InfluxDB line protocol:
+//
+//    json    {"name":"cpu","time":"2016-01-01T00:00:00Z","tags":{"host":"A"},"fields":{"value":1.5}}
+//    line    cpu,host=A value=1.5 1451606400000000000
+//
+// The key of the messages, which selects their partition, is the group of the data
+// or the value of the tag given with partitionTag.
+//
+// Example:
+//    stream
+//        .from().measurement('cpu')
+//        .groupBy('host')
+//        .window()
+//            .period(10s)
+//            .every(10s)
+//        .mapReduce(influxql.mean('value'))
+//        // Publish the mean of each host, each host to a single partition.
+//        .kafkaOut('cpu_mean')
+//            .format('line')
+//
+type KafkaOutNode struct {
+	node
+
+	// The topic the messages are written to.
+	// tick:ignore
+	Topic string
+
+	// The format of the messages, json or line.
+	// Default: json
+	Format string
+
+	// The tag whose value is the key of the messages.
+	// If empty the group of the data is the key.
+	PartitionTag string
+}
+
+func newKafkaOutNode(wants EdgeType, topic string) *KafkaOutNode {
+	return &KafkaOutNode{
+		node: node{
+			desc:     "kafka_out",
+			wants:    wants,
+			provides: NoEdge,
+		},
+		Topic:  topic,
+		Format: "json",
+	}
+}
@@ -0,0 +1,14 @@
+package pipeline
+
+// Emits the late points of a window as a stream.
+// A LateNode is created with the `late` method of a WindowNode,
+// it receives only the late points while the other children of the window receive the windows.
+type LateNode struct {
+	chainnode
+}
+
+func newLateNode() *LateNode {
+	return &LateNode{
+		chainnode: newBasicChainNode("late", BatchEdge, StreamEdge),
+	}
+}
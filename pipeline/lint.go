@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"sort"
+
+	"github.com/influxdata/kapacitor/tick"
+)
+
+// Lint a script for problems that would only be found once the task is started.
+// In addition to the problems found by tick.Lint, the pipeline created by
+// the script is checked for mismatched edge types, nodes that cannot receive data
+// and nodes whose output is never used.
+// An error is returned if the script cannot be parsed or evaluated.
+func Lint(script string, sourceEdge EdgeType, scope *tick.Scope, deadman DeadmanService, vars map[string]tick.Var) ([]tick.LintError, error) {
+	errs, err := tick.Lint(script)
+	if err != nil {
+		return nil, err
+	}
+	p, err := CreatePipeline(script, sourceEdge, scope, deadman, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes created implicitly have no position of their own,
+	// so they use the position of their first parent that does.
+	positions := make(map[ID]tick.Node)
+	unreachable := make(map[ID]bool)
+	p.Walk(func(n Node) error {
+		pos, ok := scope.NodeOf(n)
+		if !ok && len(n.Parents()) > 0 {
+			pos = positions[n.Parents()[0].ID()]
+		}
+		positions[n.ID()] = pos
+
+		if len(n.Parents()) > 0 {
+			reachable := false
+			for _, parent := range n.Parents() {
+				if unreachable[parent.ID()] {
+					continue
+				}
+				if parent.Provides() != n.Wants() {
+					errs = append(errs, tick.NewLintError(pos,
+						"cannot link %s edge from %s to %s, it wants a %s edge",
+						parent.Provides(), parent.Name(), n.Name(), n.Wants(),
+					))
+					continue
+				}
+				reachable = true
+			}
+			if !reachable {
+				unreachable[n.ID()] = true
+				return nil
+			}
+		}
+
+		if n.Provides() != NoEdge && len(n.Children()) == 0 {
+			errs = append(errs, tick.NewLintError(pos, "output of %s is never used", n.Name()))
+		}
+		return nil
+	})
+
+	// Report nodes that only have unreachable parents, the mismatched edges are reported above.
+	p.Walk(func(n Node) error {
+		if !unreachable[n.ID()] {
+			return nil
+		}
+		for _, parent := range n.Parents() {
+			if !unreachable[parent.ID()] {
+				return nil
+			}
+		}
+		errs = append(errs, tick.NewLintError(positions[n.ID()], "%s is unreachable", n.Name()))
+		return nil
+	})
+	sort.Stable(tick.LintErrors(errs))
+	return errs, nil
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/kapacitor/tick"
+)
+
+func TestLint(t *testing.T) {
+	var tickScript = `
+var s = stream.from()
+s.window()
+	.period(10s)
+	.every(10s)
+	.union(s)
+	.httpOut('out')
+s.sample(2)
+	.where(lambda: 'a' > 1)
+`
+
+	scope := tick.NewScope()
+	errs, err := Lint(tickScript, StreamEdge, scope, deadman{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []tick.LintError{
+		{Line: 6, Char: 3, Msg: "cannot link stream edge from stream1 to union4, it wants a batch edge"},
+		{Line: 9, Char: 3, Msg: "output of where7 is never used"},
+		{Line: 9, Char: 21, Msg: "mismatched type to binary operator. got string > int"},
+	}
+	if !reflect.DeepEqual(errs, exp) {
+		t.Errorf("unexpected lint errors:\ngot %v\nexp %v", errs, exp)
+	}
+}
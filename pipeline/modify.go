@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"fmt"
+)
+
+// The targets of a ModifyNode.
+const (
+	ModifyFields = "fields"
+	ModifyTags   = "tags"
+)
+
+// The operations of a ModifyNode.
+const (
+	ModifyRename     = "rename"
+	ModifyDelete     = "delete"
+	ModifySet        = "set"
+	ModifyFieldToTag = "fieldToTag"
+	ModifyTagToField = "tagToField"
+)
+
+// Modify the fields or tags of points and batches.
+// A ModifyNode is created with either `fields` or `tags`,
+// which determines whether the rename, delete and set operations apply to fields or tags.
+// The fieldToTag and tagToField operations are available on both.
+//
+// Example:
+//    stream
+//        .from().measurement('requests')
+//        .groupBy('host', 'dc')
+//        .tags()
+//            .rename('dc', 'datacenter')
+//            .delete('region')
+//            .tagToField('path')
+//        .fields()
+//            .rename('value', 'duration')
+//            .set('version', 2)
+//            .fieldToTag('status')
+//        ...
+//
+// The operations are applied in the order they are given.
+// Operations on missing fields or tags are ignored.
+//
+// When tags change the group of the data is updated:
+// renamed group by dimensions are renamed, deleted ones and tags moved
+// to fields are no longer dimensions. Tags added by set or fieldToTag are not dimensions.
+type ModifyNode struct {
+	chainnode
+
+	// Either 'fields' or 'tags'.
+	// tick:ignore
+	Target string
+
+	// The operations in the order they are applied.
+	// tick:ignore
+	Operations []ModifyOperation
+}
+
+// A single operation of a ModifyNode.
+type ModifyOperation struct {
+	// The name of the operation.
+	Op string
+	// The field or tag the operation applies to.
+	Name string
+	// The new name of a rename.
+	To string
+	// The value of a set.
+	Value interface{}
+}
+
+func newModifyNode(e EdgeType, target string) *ModifyNode {
+	return &ModifyNode{
+		chainnode: newBasicChainNode(target, e, e),
+		Target:    target,
+	}
+}
+
+// Rename a field or tag.
+// tick:property
+func (m *ModifyNode) Rename(old, new string) *ModifyNode {
+	m.Operations = append(m.Operations, ModifyOperation{Op: ModifyRename, Name: old, To: new})
+	return m
+}
+
+// Delete fields or tags.
+// tick:property
+func (m *ModifyNode) Delete(names ...string) *ModifyNode {
+	for _, name := range names {
+		m.Operations = append(m.Operations, ModifyOperation{Op: ModifyDelete, Name: name})
+	}
+	return m
+}
+
+// Set a field or tag to a value, replacing any existing value.
+// Fields may be set to a float, int, bool or string, tags only to a string.
+// tick:property
+func (m *ModifyNode) Set(name string, value interface{}) *ModifyNode {
+	switch value.(type) {
+	case string:
+	case float64, int64, bool:
+		if m.Target == ModifyTags {
+			panic(fmt.Sprintf("cannot set tag %s to type %T, tags must be strings", name, value))
+		}
+	default:
+		panic(fmt.Sprintf("cannot set field %s to type %T", name, value))
+	}
+	m.Operations = append(m.Operations, ModifyOperation{Op: ModifySet, Name: name, Value: value})
+	return m
+}
+
+// Move a field to a tag of the same name.
+// The value is formatted as a string.
+// tick:property
+func (m *ModifyNode) FieldToTag(field string) *ModifyNode {
+	m.Operations = append(m.Operations, ModifyOperation{Op: ModifyFieldToTag, Name: field})
+	return m
+}
+
+// Move a tag to a string field of the same name.
+// tick:property
+func (m *ModifyNode) TagToField(tag string) *ModifyNode {
+	m.Operations = append(m.Operations, ModifyOperation{Op: ModifyTagToField, Name: tag})
+	return m
+}
@@ -291,6 +291,13 @@ func (n *chainnode) InfluxDBOut() *InfluxDBOutNode {
 	return i
 }
 
+// Create a kafka output node that will write the incoming data to a Kafka topic.
+func (n *chainnode) KafkaOut(topic string) *KafkaOutNode {
+	k := newKafkaOutNode(n.provides, topic)
+	n.linkChild(k)
+	return k
+}
+
 // Create an alert node, which can trigger alerts.
 func (n *chainnode) Alert() *AlertNode {
 	a := newAlertNode(n.provides)
@@ -382,6 +389,48 @@ func (n *chainnode) MapReduce(mr MapReduceInfo) *ReduceNode {
 	return r
 }
 
+// Create a new node that renames, deletes or sets fields,
+// or moves fields to tags and tags to fields.
+func (n *chainnode) Fields() *ModifyNode {
+	m := newModifyNode(n.Provides(), ModifyFields)
+	n.linkChild(m)
+	return m
+}
+
+// Create a new node that renames, deletes or sets tags,
+// or moves fields to tags and tags to fields.
+func (n *chainnode) Tags() *ModifyNode {
+	m := newModifyNode(n.Provides(), ModifyTags)
+	n.linkChild(m)
+	return m
+}
+
+// Create a new node that computes several aggregates of each batch in a single pass.
+// Unlike MapReduce the results of all the aggregates are emitted as a single point.
+//
+// NOTE: Aggregate can only be applied to batch edges.
+func (n *chainnode) Aggregate() *AggregateNode {
+	if n.Provides() != BatchEdge {
+		panic("cannot Aggregate stream edge, did you forget to window the data?")
+	}
+	a := newAggregateNode()
+	n.linkChild(a)
+	return a
+}
+
+// Create a new node that forecasts the next horizon points of a field with the Holt-Winters method.
+// The seasonality is the number of points in a season, use 0 for no seasonality.
+//
+// NOTE: HoltWinters can only be applied to batch edges.
+func (n *chainnode) HoltWinters(field string, horizon, seasonality int64) *HoltWintersNode {
+	if n.Provides() != BatchEdge {
+		panic("cannot HoltWinters stream edge, did you forget to window the data?")
+	}
+	h := newHoltWintersNode(field, horizon, seasonality)
+	n.linkChild(h)
+	return h
+}
+
 // Create a new node that windows the stream by time.
 //
 // NOTE: Window can only be applied to stream edges.
@@ -394,6 +443,18 @@ func (n *chainnode) Window() *WindowNode {
 	return w
 }
 
+// Create a new node that drops duplicate points.
+//
+// NOTE: Dedup can only be applied to stream edges.
+func (n *chainnode) Dedup() *DedupNode {
+	if n.Provides() != StreamEdge {
+		panic("cannot Dedup batch edge")
+	}
+	d := newDedupNode()
+	n.linkChild(d)
+	return d
+}
+
 // Create a new node that samples the incoming points or batches.
 //
 // One point will be emitted every count or duration specified.
@@ -403,9 +464,30 @@ func (n *chainnode) Sample(rate interface{}) *SampleNode {
 	return s
 }
 
+// Create a new node that emits points only when the value of one of the fields changes.
+func (n *chainnode) ChangeDetect(field string, fields ...string) *ChangeDetectNode {
+	c := newChangeDetectNode(n.Provides(), append([]string{field}, fields...))
+	n.linkChild(c)
+	return c
+}
+
+// Create a new node that scores how anomalous the values of a field are.
+func (n *chainnode) Anomaly(field string) *AnomalyNode {
+	a := newAnomalyNode(n.Provides(), field)
+	n.linkChild(a)
+	return a
+}
+
 // Create a new node that computes the derivative of adjacent points.
 func (n *chainnode) Derivative(field string) *DerivativeNode {
 	s := newDerivativeNode(n.Provides(), field)
 	n.linkChild(s)
 	return s
 }
+
+// Create a new node that shifts the incoming points or batches in time.
+func (n *chainnode) Shift(shift time.Duration) *ShiftNode {
+	s := newShiftNode(n.Provides(), shift)
+	n.linkChild(s)
+	return s
+}
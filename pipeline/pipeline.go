@@ -28,8 +28,9 @@ type Pipeline struct {
 }
 
 // Create a pipeline from a given script.
+// The vars override the default values of variables declared in the script.
 // tick:ignore
-func CreatePipeline(script string, sourceEdge EdgeType, scope *tick.Scope, deadman DeadmanService) (*Pipeline, error) {
+func CreatePipeline(script string, sourceEdge EdgeType, scope *tick.Scope, deadman DeadmanService, vars map[string]tick.Var) (*Pipeline, error) {
 	p := &Pipeline{
 		deadman: deadman,
 	}
@@ -46,7 +47,7 @@ func CreatePipeline(script string, sourceEdge EdgeType, scope *tick.Scope, deadm
 	}
 	p.addSource(src)
 
-	err := tick.Evaluate(script, scope)
+	err := tick.EvaluateWithVars(script, scope, vars)
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +34,7 @@ w.every(1s)
 	d := deadman{}
 
 	scope := tick.NewScope()
-	p, err := CreatePipeline(tickScript, StreamEdge, scope, d)
+	p, err := CreatePipeline(tickScript, StreamEdge, scope, d, nil)
 	assert.Nil(err)
 	assert.NotNil(p)
 	assert.Equal(1, len(p.sources[0].Children()))
@@ -77,3 +77,28 @@ func TestPipelineSort(t *testing.T) {
 
 	assert.Equal(sorted, p.sorted)
 }
+
+func TestTICK_To_Pipeline_ChangeDetectFields(t *testing.T) {
+	assert := assert.New(t)
+
+	var tickScript = `
+stream
+	.changeDetect('state')
+	.fields()
+		.rename('state', 'status')
+`
+
+	scope := tick.NewScope()
+	p, err := CreatePipeline(tickScript, StreamEdge, scope, deadman{}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	c, ok := p.sources[0].Children()[0].(*ChangeDetectNode)
+	if assert.True(ok) {
+		assert.Equal([]string{"state"}, c.FieldList)
+		if assert.Equal(1, len(c.Children())) {
+			_, ok := c.Children()[0].(*ModifyNode)
+			assert.True(ok)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Shift points and batches in time, this is useful for comparing
+// batches or points from different times.
+//
+// Example:
+//    var past = batch
+//        .query('SELECT mean("value") FROM "telegraf"."default".cpu')
+//            .period(1h)
+//            .every(1h)
+//            .offset(7d)
+//        .shift(7d)
+//
+//    var current = batch
+//        .query('SELECT mean("value") FROM "telegraf"."default".cpu')
+//            .period(1h)
+//            .every(1h)
+//
+//    past.join(current)
+//        .as('past', 'current')
+//    ...
+//
+// Shift the times of the data from last week forward by a week
+// so that it is joined with the data from this week.
+//
+// A negative duration shifts the data back in time.
+type ShiftNode struct {
+	chainnode
+
+	// The duration to add to the time of each point or batch.
+	// tick:ignore
+	Shift time.Duration
+}
+
+func newShiftNode(wants EdgeType, shift time.Duration) *ShiftNode {
+	return &ShiftNode{
+		chainnode: newBasicChainNode("shift", wants, wants),
+		Shift:     shift,
+	}
+}
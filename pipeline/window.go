@@ -19,6 +19,23 @@ import (
 // and the window contains the last `10 minutes` worth of data.
 // As a result each time the window is emitted it contains half new data and half old data.
 //
+// Windows can also be defined by a number of points instead of time,
+// using `periodCount` and `everyCount`.
+//
+// Example:
+//    stream
+//        .window()
+//            .periodCount(100)
+//            .everyCount(10)
+//        .httpOut('recent')
+//
+// The above example emits the last `100` points of each group every `10` points.
+//
+// Points may arrive out of order, see Lateness, for how long windows wait for them.
+//
+// Session windows, see Session, group the points of a burst of activity
+// and are emitted once no points arrive for the duration of the gap.
+//
 // NOTE: Time for a window (or any node) is implemented by inspecting the times on the incoming data points.
 // As a result if the incoming data stream stops then no more windows will be emitted because time is no longer
 // increasing for the window node.
@@ -28,6 +45,24 @@ type WindowNode struct {
 	Period time.Duration
 	// How often the current window is emitted into the pipeline.
 	Every time.Duration
+	// The number of points in the window.
+	// Cannot be used with period or every.
+	PeriodCount int64
+	// How often, in number of points, the current window is emitted into the pipeline.
+	// Default is periodCount, so that windows do not overlap.
+	EveryCount int64
+	// How far the times of points may lag behind the newest point of the group.
+	// A window is emitted once the newest time minus the lateness passes the end of the window,
+	// points older than the start of the oldest window not yet emitted are then late and are dropped,
+	// unless they are routed to a side output with Late.
+	// Late points are counted in the 'late' statistic of the node.
+	// With the default of 0 windows are emitted as soon as a point after their end arrives
+	// and late points are added to the next window.
+	// Can only be used with period and every.
+	Lateness time.Duration
+	// The quiet period that closes a session window.
+	// tick:ignore
+	SessionGap time.Duration
 	// Wether to align the window edges with the zero time
 	// tick:ignore
 	AlignFlag bool
@@ -47,3 +82,48 @@ func (w *WindowNode) Align() *WindowNode {
 	w.AlignFlag = true
 	return w
 }
+
+// Window the points of each group into sessions.
+// A session is emitted when a point arrives at least gap after the previous point of the group,
+// that point then starts the next session.
+// Cannot be used with period, every or the count properties.
+//
+// Example:
+//    stream
+//        .from().measurement('requests')
+//        .groupBy('trace_id')
+//        .window()
+//            .session(30s)
+//        ...
+//
+// tick:property
+func (w *WindowNode) Session(gap time.Duration) *WindowNode {
+	if gap <= 0 {
+		panic("session gap must be positive")
+	}
+	w.SessionGap = gap
+	return w
+}
+
+// Create a side output of the late points of the window, see Lateness, which must be set.
+// The late points are emitted as a stream.
+//
+// Example:
+//    var w = stream
+//        .from().measurement('cpu')
+//        .window()
+//            .period(1m)
+//            .every(1m)
+//            .lateness(10s)
+//    w.mapReduce(influxql.mean('value'))
+//        ...
+//    w.late()
+//        .influxDBOut()
+//            .database('late')
+//        ...
+//
+func (w *WindowNode) Late() *LateNode {
+	l := newLateNode()
+	w.linkChild(l)
+	return l
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+type Config struct {
+	Dir string `toml:"dir"`
+	// Users that are created when the service starts if they do not exist.
+	// Existing users are not modified, so their passwords can be changed once the server is running.
+	Users []UserConfig `toml:"users"`
+}
+
+type UserConfig struct {
+	Name     string `toml:"name"`
+	Password string `toml:"password"`
+	// One of 'read', 'write' or 'admin'.
+	Privilege string `toml:"privilege"`
+	// The teams the user is a member of.
+	Teams []string `toml:"teams"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Dir: "./auth",
+	}
+}
+
+// Validate returns an error if the config is invalid.
+// When authentication is enabled an admin user must be configured,
+// since only admins can create users.
+func (c Config) Validate(authEnabled bool) error {
+	if c.Dir == "" {
+		return fmt.Errorf("must specify auth dir")
+	}
+	admin := false
+	for _, u := range c.Users {
+		if u.Name == "" {
+			return fmt.Errorf("must specify auth user name")
+		}
+		if u.Password == "" {
+			return fmt.Errorf("must specify password for auth user %s", u.Name)
+		}
+		p, err := httpd.ParsePrivilege(u.Privilege)
+		if err != nil {
+			return fmt.Errorf("invalid privilege for auth user %s: %s", u.Name, err)
+		}
+		if p == httpd.AdminPrivilege {
+			admin = true
+		}
+	}
+	if authEnabled && !admin {
+		return fmt.Errorf("must specify an auth user with the admin privilege when auth-enabled is true")
+	}
+	return nil
+}
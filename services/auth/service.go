@@ -0,0 +1,474 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const authDB = "auth.db"
+
+// The number of random bytes in a token.
+const tokenSize = 32
+
+var (
+	usersBucket  = []byte("users")
+	tokensBucket = []byte("tokens")
+)
+
+var (
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	ErrNoSuchUser           = errors.New("no such user")
+)
+
+// Service stores the users of the API and authenticates them,
+// by their password or by a bearer token.
+//
+// Passwords are stored as bcrypt hashes and tokens as SHA-256 hashes.
+type Service struct {
+	dbpath       string
+	db           *bolt.DB
+	users        []UserConfig
+	routes       []httpd.Route
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+
+	logger *log.Logger
+}
+
+// A user as it is stored.
+type user struct {
+	Name      string
+	Hash      []byte
+	Privilege httpd.Privilege
+	Teams     []string
+}
+
+func NewService(conf Config, l *log.Logger) *Service {
+	return &Service{
+		dbpath: path.Join(conf.Dir, authDB),
+		users:  conf.Users,
+		logger: l,
+	}
+}
+
+func (s *Service) Open() error {
+	err := os.MkdirAll(path.Dir(s.dbpath), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Open db
+	db, err := bolt.Open(s.dbpath, 0600, nil)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(usersBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(tokensBucket)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bootstrap the configured users
+	for _, u := range s.users {
+		_, err := s.user(u.Name)
+		if err == nil {
+			continue
+		}
+		if err != ErrNoSuchUser {
+			return err
+		}
+		p, err := httpd.ParsePrivilege(u.Privilege)
+		if err != nil {
+			return err
+		}
+		err = s.SaveUser(u.Name, u.Password, p, u.Teams)
+		if err != nil {
+			return err
+		}
+		s.logger.Printf("I! created user %s with the %s privilege", u.Name, p)
+	}
+
+	// Define API routes
+	s.routes = []httpd.Route{
+		{
+			"user-list",
+			"GET",
+			"/users",
+			true,
+			true,
+			s.handleUsers,
+			httpd.AdminPrivilege,
+			false,
+		},
+		{
+			"user-save",
+			"POST",
+			"/user",
+			true,
+			true,
+			s.handleSave,
+			httpd.AdminPrivilege,
+			false,
+		},
+		{
+			"user-delete",
+			"DELETE",
+			"/user",
+			true,
+			true,
+			s.handleDelete,
+			httpd.AdminPrivilege,
+			false,
+		},
+		{
+			"user-token",
+			"POST",
+			"/user/token",
+			true,
+			true,
+			s.handleToken,
+			httpd.ReadPrivilege,
+			false,
+		},
+		{
+			"user-token-revoke",
+			"DELETE",
+			"/user/token",
+			true,
+			true,
+			s.handleRevoke,
+			httpd.ReadPrivilege,
+			false,
+		},
+	}
+	return s.HTTPDService.AddRoutes(s.routes)
+}
+
+func (s *Service) Close() error {
+	s.HTTPDService.DelRoutes(s.routes)
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// Authenticate a user by name and password.
+func (s *Service) Authenticate(username, password string) (httpd.User, error) {
+	u, err := s.user(username)
+	if err != nil {
+		return httpd.User{}, ErrAuthenticationFailed
+	}
+	err = bcrypt.CompareHashAndPassword(u.Hash, []byte(password))
+	if err != nil {
+		return httpd.User{}, ErrAuthenticationFailed
+	}
+	return u.httpdUser(), nil
+}
+
+// Authenticate a user by a token created with CreateToken.
+func (s *Service) AuthenticateToken(token string) (httpd.User, error) {
+	var name string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		name = string(tx.Bucket(tokensBucket).Get(hashToken(token)))
+		return nil
+	})
+	if err != nil {
+		return httpd.User{}, err
+	}
+	if name == "" {
+		return httpd.User{}, ErrAuthenticationFailed
+	}
+	u, err := s.user(name)
+	if err != nil {
+		return httpd.User{}, ErrAuthenticationFailed
+	}
+	return u.httpdUser(), nil
+}
+
+// Create or update a user.
+// An existing user keeps its password if the password is empty,
+// its privilege if the privilege is NoPrivileges and its teams if teams is nil.
+func (s *Service) SaveUser(name, password string, privilege httpd.Privilege, teams []string) error {
+	if name == "" {
+		return errors.New("must provide user name")
+	}
+	u, err := s.user(name)
+	if err == ErrNoSuchUser {
+		if password == "" {
+			return errors.New("must provide a password for a new user")
+		}
+		if privilege == httpd.NoPrivileges {
+			return errors.New("must provide a privilege for a new user")
+		}
+		u = user{Name: name}
+	} else if err != nil {
+		return err
+	}
+	if password != "" {
+		u.Hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+	}
+	if privilege != httpd.NoPrivileges {
+		u.Privilege = privilege
+	}
+	if teams != nil {
+		u.Teams = teams
+	}
+
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(u)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).Put([]byte(name), buf.Bytes())
+	})
+}
+
+// Delete a user and all of its tokens.
+func (s *Service) DeleteUser(name string) error {
+	if _, err := s.user(name); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(usersBucket).Delete([]byte(name))
+		if err != nil {
+			return err
+		}
+		return deleteTokens(tx, name)
+	})
+}
+
+// Return all users ordered by name.
+func (s *Service) Users() ([]httpd.User, error) {
+	var users []httpd.User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var u user
+			err := gob.NewDecoder(bytes.NewReader(v)).Decode(&u)
+			if err != nil {
+				return err
+			}
+			users = append(users, u.httpdUser())
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(usersByName(users))
+	return users, nil
+}
+
+// Create a new token for a user.
+// The token itself is not stored and cannot be retrieved again.
+func (s *Service) CreateToken(name string) (string, error) {
+	if _, err := s.user(name); err != nil {
+		return "", err
+	}
+	b := make([]byte, tokenSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Put(hashToken(token), []byte(name))
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke all tokens of a user.
+func (s *Service) RevokeTokens(name string) error {
+	if _, err := s.user(name); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteTokens(tx, name)
+	})
+}
+
+func (s *Service) user(name string) (user, error) {
+	var u user
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data = tx.Bucket(usersBucket).Get([]byte(name))
+		if data == nil {
+			return ErrNoSuchUser
+		}
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&u)
+	})
+	return u, err
+}
+
+func (u user) httpdUser() httpd.User {
+	return httpd.User{Name: u.Name, Privilege: u.Privilege, Teams: u.Teams}
+}
+
+func deleteTokens(tx *bolt.Tx, name string) error {
+	b := tx.Bucket(tokensBucket)
+	var hashes [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if string(v) == name {
+			hashes = append(hashes, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if err := b.Delete(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+type usersByName []httpd.User
+
+func (u usersByName) Len() int           { return len(u) }
+func (u usersByName) Less(i, j int) bool { return u[i].Name < u[j].Name }
+func (u usersByName) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+
+type userInfo struct {
+	Name      string
+	Privilege string
+	Teams     []string
+}
+
+func (s *Service) handleUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.Users()
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	infos := make([]userInfo, len(users))
+	for i, u := range users {
+		infos[i] = userInfo{Name: u.Name, Privilege: u.Privilege.String(), Teams: u.Teams}
+	}
+
+	type response struct {
+		Users []userInfo `json:"Users"`
+	}
+
+	w.Write(httpd.MarshalJSON(response{infos}, true))
+}
+
+// Save a user, the password is the body of the request.
+// The teams are a comma separated list, an empty list removes the user from all teams.
+func (s *Service) handleSave(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	privilege := httpd.NoPrivileges
+	if p := r.URL.Query().Get("privilege"); p != "" {
+		var err error
+		privilege, err = httpd.ParsePrivilege(p)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+			return
+		}
+	}
+	var teams []string
+	if t, ok := r.URL.Query()["teams"]; ok {
+		teams = make([]string, 0)
+		if len(t) > 0 && t[0] != "" {
+			teams = strings.Split(t[0], ",")
+		}
+	}
+	password, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+
+	err = s.SaveUser(name, string(password), privilege, teams)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	err := s.DeleteUser(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+}
+
+// Create a token, users may create tokens for themselves while admins may create tokens for any user.
+func (s *Service) handleToken(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if !authorizeTokens(u, name) {
+		httpd.HttpError(w, fmt.Sprintf("user %q cannot create tokens for user %q", u.Name, name), true, http.StatusForbidden)
+		return
+	}
+
+	token, err := s.CreateToken(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+
+	type response struct {
+		Token string `json:"Token"`
+	}
+
+	w.Write(httpd.MarshalJSON(response{token}, true))
+}
+
+func (s *Service) handleRevoke(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if !authorizeTokens(u, name) {
+		httpd.HttpError(w, fmt.Sprintf("user %q cannot revoke tokens of user %q", u.Name, name), true, http.StatusForbidden)
+		return
+	}
+
+	err := s.RevokeTokens(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+}
+
+// Whether the authenticated user may manage the tokens of the named user.
+// The user is nil when authentication is disabled.
+func authorizeTokens(u *httpd.User, name string) bool {
+	return u == nil || u.Name == name || u.Authorize(httpd.AdminPrivilege)
+}
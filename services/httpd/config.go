@@ -1,13 +1,32 @@
 package httpd
 
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
 type Config struct {
-	BindAddress      string `toml:"bind-address"`
-	AuthEnabled      bool   `toml:"auth-enabled"`
-	LogEnabled       bool   `toml:"log-enabled"`
-	WriteTracing     bool   `toml:"write-tracing"`
-	PprofEnabled     bool   `toml:"pprof-enabled"`
-	HttpsEnabled     bool   `toml:"https-enabled"`
+	BindAddress  string `toml:"bind-address"`
+	AuthEnabled  bool   `toml:"auth-enabled"`
+	LogEnabled   bool   `toml:"log-enabled"`
+	WriteTracing bool   `toml:"write-tracing"`
+	PprofEnabled bool   `toml:"pprof-enabled"`
+	HttpsEnabled bool   `toml:"https-enabled"`
+	// PEM encoded certificate, it also contains the private key if HttpsPrivateKey is empty.
 	HttpsCertificate string `toml:"https-certificate"`
+	// PEM encoded private key of the certificate.
+	HttpsPrivateKey string `toml:"https-private-key"`
+	// PEM encoded CA certificates used to verify client certificates.
+	HttpsClientCA string `toml:"https-client-ca"`
+	// How client certificates are handled, one of
+	// 'none', 'request', 'require', 'verify-if-given' or 'require-and-verify'.
+	// Defaults to 'require-and-verify' if a client CA is configured, otherwise 'none'.
+	HttpsClientAuth string `toml:"https-client-auth"`
+	// The minimum TLS version, one of '1.0', '1.1', '1.2' or '1.3'.
+	HttpsMinVersion string `toml:"https-min-version"`
+	// The names of the allowed cipher suites, all secure suites are allowed if empty.
+	HttpsCiphers []string `toml:"https-ciphers"`
 }
 
 func NewConfig() Config {
@@ -15,5 +34,29 @@ func NewConfig() Config {
 		BindAddress:      ":9092",
 		LogEnabled:       true,
 		HttpsCertificate: "/etc/ssl/kapacitor.pem",
+		HttpsMinVersion:  "1.2",
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.HttpsEnabled {
+		return nil
+	}
+	if c.HttpsCertificate == "" {
+		return errors.New("must specify https-certificate when https is enabled")
+	}
+	clientAuth, err := c.clientAuth()
+	if err != nil {
+		return err
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && c.HttpsClientCA == "" {
+		return fmt.Errorf("must specify https-client-ca to verify client certificates with https-client-auth %q", c.HttpsClientAuth)
+	}
+	if _, err := c.minVersion(); err != nil {
+		return err
+	}
+	if _, err := c.cipherSuites(); err != nil {
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package httpd
+
+import (
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(c *Config)
+		valid  bool
+	}{
+		{
+			name:   "https disabled",
+			modify: func(c *Config) { c.HttpsClientAuth = "unknown" },
+			valid:  true,
+		},
+		{
+			name:   "defaults",
+			modify: func(c *Config) {},
+			valid:  true,
+		},
+		{
+			name: "client CA",
+			modify: func(c *Config) {
+				c.HttpsClientCA = "/etc/ssl/ca.pem"
+				c.HttpsClientAuth = "verify-if-given"
+			},
+			valid: true,
+		},
+		{
+			name:   "verify without client CA",
+			modify: func(c *Config) { c.HttpsClientAuth = "require-and-verify" },
+		},
+		{
+			name:   "require without client CA",
+			modify: func(c *Config) { c.HttpsClientAuth = "require" },
+			valid:  true,
+		},
+		{
+			name:   "unknown client auth",
+			modify: func(c *Config) { c.HttpsClientAuth = "always" },
+		},
+		{
+			name:   "unknown min version",
+			modify: func(c *Config) { c.HttpsMinVersion = "1.4" },
+		},
+		{
+			name:   "ciphers",
+			modify: func(c *Config) { c.HttpsCiphers = []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"} },
+			valid:  true,
+		},
+		{
+			name:   "unknown cipher",
+			modify: func(c *Config) { c.HttpsCiphers = []string{"TLS_NULL"} },
+		},
+	}
+	for _, tc := range testCases {
+		c := NewConfig()
+		c.HttpsEnabled = tc.name != "https disabled"
+		tc.modify(&c)
+		err := c.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
@@ -32,6 +32,9 @@ const (
 	statPointsWrittenFail         = "points_written_fail" // Number of points that failed to be written
 )
 
+// The path prefix of the versioned API.
+const APIPrefix = "/v1/"
+
 type Route struct {
 	Name        string
 	Method      string
@@ -39,6 +42,10 @@ type Route struct {
 	Gzipped     bool
 	Log         bool
 	HandlerFunc interface{}
+	// The privilege a user needs to use the route when authentication is enabled.
+	Privilege Privilege
+	// Public routes are served without authentication.
+	Public bool
 }
 
 // Handler represents an HTTP handler for the Kapacitor API server.
@@ -49,8 +56,11 @@ type Handler struct {
 
 	MetaClient interface {
 		Database(name string) (*meta.DatabaseInfo, error)
-		Authenticate(username, password string) (ui *meta.UserInfo, err error)
-		Users() ([]meta.UserInfo, error)
+	}
+
+	AuthService interface {
+		Authenticate(username, password string) (User, error)
+		AuthenticateToken(token string) (User, error)
 	}
 
 	PointsWriter interface {
@@ -61,6 +71,11 @@ type Handler struct {
 	loggingEnabled bool // Log every HTTP access.
 	WriteTrace     bool // Detailed logging of write path
 	statMap        *expvar.Map
+
+	// The debug endpoints are served before routing,
+	// but require the same authentication as the routes.
+	pprofHandler http.Handler
+	varsHandler  http.Handler
 }
 
 // NewHandler returns a new instance of handler with routes.
@@ -73,47 +88,71 @@ func NewHandler(requireAuthentication, loggingEnabled, writeTrace bool, statMap
 		WriteTrace:            writeTrace,
 		statMap:               statMap,
 	}
+	h.pprofHandler = authenticate(servePprof, h, requireAuthentication, AdminPrivilege)
+	h.varsHandler = authenticate(func(w http.ResponseWriter, r *http.Request, _ *User) {
+		serveExpvar(w, r)
+	}, h, requireAuthentication, ReadPrivilege)
 
 	h.AddRoutes([]Route{
 		Route{ // Ping
 			"ping",
-			"GET", "/ping", true, true, h.servePing,
+			"GET", "/ping", true, true, h.servePing, NoPrivileges, true,
 		},
 		Route{ // Ping
 			"ping-head",
-			"HEAD", "/ping", true, true, h.servePing,
+			"HEAD", "/ping", true, true, h.servePing, NoPrivileges, true,
 		},
 		Route{
 			"write", // Satisfy CORS checks.
-			"OPTIONS", "/write", true, true, h.serveOptions,
+			"OPTIONS", "/write", true, true, h.serveOptions, NoPrivileges, true,
 		},
 		Route{
 			"write", // Data-ingest route.
-			"POST", "/write", true, true, h.serveWrite,
+			"POST", "/write", true, true, h.serveWrite, WritePrivilege, false,
 		},
 		Route{
 			"routes", // Display current API routes
-			"GET", "/:routes", true, true, h.serveRoutes,
+			"GET", "/:routes", true, true, h.serveRoutes, ReadPrivilege, false,
 		},
 		Route{
 			"log-level", // Display current API routes
-			"POST", "/loglevel", true, true, h.serveLogLevel,
+			"POST", "/loglevel", true, true, h.serveLogLevel, AdminPrivilege, false,
+		},
+		Route{
+			"404", // Catch all 404
+			"GET", "/", true, true, h.serve404, NoPrivileges, true,
 		},
 		Route{
 			"404", // Catch all 404
-			"GET", "/", true, true, h.serve404,
+			"POST", "/", true, true, h.serve404, NoPrivileges, true,
 		},
 		Route{
 			"404", // Catch all 404
-			"POST", "/", true, true, h.serve404,
+			"DELETE", "/", true, true, h.serve404, NoPrivileges, true,
 		},
 		Route{
 			"404", // Catch all 404
-			"DELETE", "/", true, true, h.serve404,
+			"HEAD", "/", true, true, h.serve404, NoPrivileges, true,
 		},
 		Route{
 			"404", // Catch all 404
-			"HEAD", "/", true, true, h.serve404,
+			"PATCH", "/", true, true, h.serve404, NoPrivileges, true,
+		},
+		Route{
+			"404", // Catch all 404 of the versioned API
+			"GET", APIPrefix, true, true, h.serveAPI404, NoPrivileges, true,
+		},
+		Route{
+			"404", // Catch all 404 of the versioned API
+			"POST", APIPrefix, true, true, h.serveAPI404, NoPrivileges, true,
+		},
+		Route{
+			"404", // Catch all 404 of the versioned API
+			"PATCH", APIPrefix, true, true, h.serveAPI404, NoPrivileges, true,
+		},
+		Route{
+			"404", // Catch all 404 of the versioned API
+			"DELETE", APIPrefix, true, true, h.serveAPI404, NoPrivileges, true,
 		},
 	})
 
@@ -132,13 +171,16 @@ func (h *Handler) AddRoutes(routes []Route) error {
 
 func (h *Handler) AddRoute(r Route) error {
 	var handler http.Handler
-	// If it's a handler func that requires authorization, wrap it in authorization
-	if hf, ok := r.HandlerFunc.(func(http.ResponseWriter, *http.Request, *meta.UserInfo)); ok {
-		handler = authenticate(hf, h, h.requireAuthentication)
+	requireAuthentication := h.requireAuthentication && !r.Public
+	// If it's a handler func that needs the user, pass the authenticated user
+	if hf, ok := r.HandlerFunc.(func(http.ResponseWriter, *http.Request, *User)); ok {
+		handler = authenticate(hf, h, requireAuthentication, r.Privilege)
 	}
-	// This is a normal handler signature and does not require authorization
+	// This is a normal handler signature that does not need the user
 	if hf, ok := r.HandlerFunc.(func(http.ResponseWriter, *http.Request)); ok {
-		handler = http.HandlerFunc(hf)
+		handler = authenticate(func(w http.ResponseWriter, r *http.Request, _ *User) {
+			hf(w, r)
+		}, h, requireAuthentication, r.Privilege)
 	}
 
 	if r.Gzipped {
@@ -180,18 +222,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// FIXME(benbjohnson): Add pprof enabled flag.
 	if strings.HasPrefix(r.URL.Path, "/debug/pprof") {
-		switch r.URL.Path {
-		case "/debug/pprof/cmdline":
-			pprof.Cmdline(w, r)
-		case "/debug/pprof/profile":
-			pprof.Profile(w, r)
-		case "/debug/pprof/symbol":
-			pprof.Symbol(w, r)
-		default:
-			pprof.Index(w, r)
-		}
+		h.pprofHandler.ServeHTTP(w, r)
 	} else if strings.HasPrefix(r.URL.Path, "/debug/vars") {
-		serveExpvar(w, r)
+		h.varsHandler.ServeHTTP(w, r)
 	} else {
 		method := r.Method
 		if method == "" {
@@ -231,6 +264,10 @@ func (h *Handler) serve404(w http.ResponseWriter, r *http.Request) {
 	HttpError(w, "Not Found", true, http.StatusNotFound)
 }
 
+func (h *Handler) serveAPI404(w http.ResponseWriter, r *http.Request) {
+	APIError(w, "Not Found", http.StatusNotFound)
+}
+
 func (h *Handler) writeError(w http.ResponseWriter, result influxql.Result, statusCode int) {
 	w.WriteHeader(statusCode)
 	w.Write([]byte(result.Err.Error()))
@@ -248,7 +285,7 @@ func (h *Handler) servePing(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user *meta.UserInfo) {
+func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request) {
 	h.statMap.Add(statWriteRequest, 1)
 
 	// Handle gzip decoding of the body
@@ -276,11 +313,11 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user *meta.
 		h.Logger.Printf("E! write body received by handler: %s", string(b))
 	}
 
-	h.serveWriteLine(w, r, b, user)
+	h.serveWriteLine(w, r, b)
 }
 
 // serveWriteLine receives incoming series data in line protocol format and writes it to the database.
-func (h *Handler) serveWriteLine(w http.ResponseWriter, r *http.Request, body []byte, user *meta.UserInfo) {
+func (h *Handler) serveWriteLine(w http.ResponseWriter, r *http.Request, body []byte) {
 	// Some clients may not set the content-type header appropriately and send JSON with a non-json
 	// content-type.  If the body looks JSON, try to handle it as as JSON instead
 	if len(body) > 0 {
@@ -329,16 +366,6 @@ func (h *Handler) serveWriteLine(w http.ResponseWriter, r *http.Request, body []
 		return
 	}
 
-	if h.requireAuthentication && user == nil {
-		h.writeError(w, influxql.Result{Err: fmt.Errorf("user is required to write to database %q", database)}, http.StatusUnauthorized)
-		return
-	}
-
-	if h.requireAuthentication && !user.Authorize(influxql.WritePrivilege, database) {
-		h.writeError(w, influxql.Result{Err: fmt.Errorf("%q user is not authorized to write to database %q", user.Name, database)}, http.StatusUnauthorized)
-		return
-	}
-
 	// Determine required consistency level.
 	consistency := cluster.ConsistencyLevelOne
 	switch r.Form.Get("consistency") {
@@ -388,6 +415,20 @@ func MarshalJSON(v interface{}, pretty bool) []byte {
 	return b
 }
 
+// servePprof serves the pprof profiles.
+func servePprof(w http.ResponseWriter, r *http.Request, _ *User) {
+	switch r.URL.Path {
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(w, r)
+	case "/debug/pprof/profile":
+		pprof.Profile(w, r)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
+
 // serveExpvar serves registered expvar information over HTTP.
 func serveExpvar(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -422,6 +463,27 @@ func HttpError(w http.ResponseWriter, err string, pretty bool, code int) {
 	w.Write(b)
 }
 
+// APIError writes an error of the versioned API as the JSON object {"error": "..."}.
+func APIError(w http.ResponseWriter, err string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	type errResponse struct {
+		Error string `json:"error"`
+	}
+
+	w.Write(MarshalJSON(errResponse{Error: err}, true))
+}
+
+// Write an error in the format of the API the request was made to.
+func httpError(w http.ResponseWriter, r *http.Request, err string, code int) {
+	if strings.HasPrefix(r.URL.Path, APIPrefix) {
+		APIError(w, err, code)
+		return
+	}
+	HttpError(w, err, true, code)
+}
+
 func resultError(w http.ResponseWriter, result influxql.Result, code int) {
 	w.Header().Add("content-type", "application/json")
 	w.WriteHeader(code)
@@ -447,17 +509,52 @@ func parseCredentials(r *http.Request) (string, string, error) {
 	return "", "", fmt.Errorf("unable to parse Basic Auth credentials")
 }
 
-// authenticate wraps a handler and ensures that if user credentials are passed in
-// an attempt is made to authenticate that user. If authentication fails, an error is returned.
-// NOT IMPLEMENTED YET
-func authenticate(inner func(http.ResponseWriter, *http.Request, *meta.UserInfo), h *Handler, requireAuthentication bool) http.Handler {
+// parseToken returns the bearer token of a request.
+// as header: Authorization: Bearer <token>
+func parseToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// authenticate wraps a handler and ensures that the user of the request is authenticated
+// and has the privilege required by the route. If authentication fails, an error is returned.
+func authenticate(inner func(http.ResponseWriter, *http.Request, *User), h *Handler, requireAuthentication bool, privilege Privilege) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Return early if we are not authenticating
 		if !requireAuthentication {
 			inner(w, r, nil)
 			return
 		}
-		panic("Authentication not implemented")
+		if h.AuthService == nil {
+			httpError(w, r, "authentication is enabled but no users are configured", http.StatusInternalServerError)
+			return
+		}
+
+		var user User
+		var err error
+		if token, ok := parseToken(r); ok {
+			user, err = h.AuthService.AuthenticateToken(token)
+		} else {
+			var username, password string
+			username, password, err = parseCredentials(r)
+			if err == nil {
+				user, err = h.AuthService.Authenticate(username, password)
+			}
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kapacitor"`)
+			httpError(w, r, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !user.Authorize(privilege) {
+			httpError(w, r, fmt.Sprintf("user %q does not have the %s privilege", user.Name, privilege), http.StatusForbidden)
+			return
+		}
+		inner(w, r, &user)
 	})
 }
 
@@ -472,6 +569,10 @@ func (w gzipResponseWriter) Write(b []byte) (int, error) {
 
 func (w gzipResponseWriter) Flush() {
 	w.Writer.(*gzip.Writer).Flush()
+	// Send the compressed data to the client, e.g. for streaming responses.
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 // determines if the client can accept compressed responses, and encodes accordingly
@@ -508,6 +609,7 @@ func cors(inner http.Handler) http.Handler {
 				`DELETE`,
 				`GET`,
 				`OPTIONS`,
+				`PATCH`,
 				`POST`,
 				`PUT`,
 			}, ", "))
@@ -0,0 +1,63 @@
+package httpd
+
+import (
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type authService struct{}
+
+func (authService) Authenticate(username, password string) (User, error) {
+	if username == "viewer" && password == "pass" {
+		return User{Name: username, Privilege: ReadPrivilege}, nil
+	}
+	return User{}, errors.New("invalid credentials")
+}
+
+func (authService) AuthenticateToken(token string) (User, error) {
+	return User{}, errors.New("invalid token")
+}
+
+func TestHandler_AddRoute_Authentication(t *testing.T) {
+	h := NewHandler(true, false, false, &expvar.Map{}, log.New(os.Stderr, "[httpd] ", log.LstdFlags))
+	h.AuthService = authService{}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	err := h.AddRoutes([]Route{
+		{Name: "default", Method: "GET", Pattern: "/default", HandlerFunc: ok},
+		{Name: "public", Method: "GET", Pattern: "/public", HandlerFunc: ok, Public: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path string
+		user string
+		code int
+	}{
+		// Routes require authentication unless they are public.
+		{path: "/default", code: http.StatusUnauthorized},
+		{path: "/default", user: "viewer", code: http.StatusOK},
+		{path: "/public", code: http.StatusOK},
+		{path: "/ping", code: http.StatusNoContent},
+	}
+	for _, tc := range testCases {
+		r, err := http.NewRequest("GET", tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.user != "" {
+			r.SetBasicAuth(tc.user, "pass")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("GET %s as %q: unexpected status code: got %d exp %d", tc.path, tc.user, w.Code, tc.code)
+		}
+	}
+}
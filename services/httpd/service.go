@@ -8,15 +8,20 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 type Service struct {
 	ln    net.Listener
 	addr  string
 	https bool
-	cert  string
+	conf  Config
 	err   chan error
 
+	// The TLS configuration used for new connections, replaced by Reload.
+	mu        sync.RWMutex
+	tlsConfig *tls.Config
+
 	Handler *Handler
 
 	Logger *log.Logger
@@ -28,7 +33,7 @@ func NewService(c Config, l *log.Logger) *Service {
 	s := &Service{
 		addr:  c.BindAddress,
 		https: c.HttpsEnabled,
-		cert:  c.HttpsCertificate,
+		conf:  c,
 		err:   make(chan error),
 		Handler: NewHandler(
 			c.AuthEnabled,
@@ -50,13 +55,14 @@ func (s *Service) Open() error {
 
 	// Open listener.
 	if s.https {
-		cert, err := tls.LoadX509KeyPair(s.cert, s.cert)
+		tc, err := s.conf.tlsConfig()
 		if err != nil {
 			return err
 		}
+		s.tlsConfig = tc
 
 		listener, err := tls.Listen("tcp", s.addr, &tls.Config{
-			Certificates: []tls.Certificate{cert},
+			GetConfigForClient: s.getTLSConfig,
 		})
 		if err != nil {
 			return err
@@ -79,6 +85,29 @@ func (s *Service) Open() error {
 	return nil
 }
 
+// Reload the certificate, private key and client CAs from disk.
+// New connections use the reloaded files, on error the previous files remain in use.
+func (s *Service) Reload() error {
+	if !s.https {
+		return nil
+	}
+	tc, err := s.conf.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.tlsConfig = tc
+	s.mu.Unlock()
+	s.Logger.Println("I! Reloaded HTTPS certificates")
+	return nil
+}
+
+func (s *Service) getTLSConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tlsConfig, nil
+}
+
 // Close closes the underlying listener.
 func (s *Service) Close() error {
 	if s.ln != nil {
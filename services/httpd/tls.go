@@ -0,0 +1,112 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (c Config) clientAuth() (tls.ClientAuthType, error) {
+	if c.HttpsClientAuth == "" {
+		if c.HttpsClientCA != "" {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	}
+	t, ok := clientAuthTypes[c.HttpsClientAuth]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("unknown https-client-auth %q, must be one of 'none', 'request', 'require', 'verify-if-given' or 'require-and-verify'", c.HttpsClientAuth)
+	}
+	return t, nil
+}
+
+func (c Config) minVersion() (uint16, error) {
+	if c.HttpsMinVersion == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[c.HttpsMinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown https-min-version %q, must be one of '1.0', '1.1', '1.2' or '1.3'", c.HttpsMinVersion)
+	}
+	return v, nil
+}
+
+func (c Config) cipherSuites() ([]uint16, error) {
+	if len(c.HttpsCiphers) == 0 {
+		return nil, nil
+	}
+	ids := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		ids[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		ids[s.Name] = s.ID
+	}
+	suites := make([]uint16, len(c.HttpsCiphers))
+	for i, name := range c.HttpsCiphers {
+		id, ok := ids[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown https cipher suite %q", name)
+		}
+		suites[i] = id
+	}
+	return suites, nil
+}
+
+// Load the certificate, private key and client CAs from disk into a TLS configuration.
+func (c Config) tlsConfig() (*tls.Config, error) {
+	key := c.HttpsPrivateKey
+	if key == "" {
+		key = c.HttpsCertificate
+	}
+	cert, err := tls.LoadX509KeyPair(c.HttpsCertificate, key)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := c.clientAuth()
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := c.minVersion()
+	if err != nil {
+		return nil, err
+	}
+	suites, err := c.cipherSuites()
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+	}
+	if c.HttpsClientCA != "" {
+		pem, err := ioutil.ReadFile(c.HttpsClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tc.ClientCAs = x509.NewCertPool()
+		if !tc.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in https-client-ca " + c.HttpsClientCA)
+		}
+	}
+	return tc, nil
+}
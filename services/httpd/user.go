@@ -0,0 +1,116 @@
+package httpd
+
+import (
+	"fmt"
+)
+
+// Privilege is the level of access a user has to the API.
+// Each privilege includes the privileges below it.
+type Privilege int
+
+const (
+	// Routes that require no privilege are open to any authenticated user.
+	NoPrivileges Privilege = iota
+	// Read tasks, templates, recordings and outputs.
+	ReadPrivilege
+	// Define, enable, disable and delete tasks, templates and recordings, and write data.
+	WritePrivilege
+	// Manage users and the server.
+	AdminPrivilege
+)
+
+func (p Privilege) String() string {
+	switch p {
+	case NoPrivileges:
+		return "none"
+	case ReadPrivilege:
+		return "read"
+	case WritePrivilege:
+		return "write"
+	case AdminPrivilege:
+		return "admin"
+	default:
+		return fmt.Sprintf("Privilege(%d)", int(p))
+	}
+}
+
+// Parse a privilege from its name, one of 'read', 'write' or 'admin'.
+func ParsePrivilege(s string) (Privilege, error) {
+	switch s {
+	case "read":
+		return ReadPrivilege, nil
+	case "write":
+		return WritePrivilege, nil
+	case "admin":
+		return AdminPrivilege, nil
+	default:
+		return NoPrivileges, fmt.Errorf("unknown privilege %q, must be one of 'read', 'write' or 'admin'", s)
+	}
+}
+
+// An authenticated user of the API.
+type User struct {
+	Name      string
+	Privilege Privilege
+	// The teams the user is a member of.
+	Teams []string
+}
+
+// Whether the user has the privilege.
+func (u User) Authorize(p Privilege) bool {
+	return u.Privilege >= p
+}
+
+// Whether the user is a member of the team.
+func (u User) InTeam(team string) bool {
+	for _, t := range u.Teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is the access a user has to a task or recording,
+// which is owned by a user and optionally shared with a team.
+// Each role includes the roles below it.
+type Role int
+
+const (
+	// The user cannot see the resource.
+	NoRole Role = iota
+	// The user can see the resource.
+	ViewerRole
+	// The user can also update, enable and disable the resource.
+	MemberRole
+	// The user can also delete the resource and change its team.
+	OwnerRole
+)
+
+// Role returns the role of the user for a resource with the owner and team.
+//
+// The owner of a resource has the owner role and the members of its team the member role.
+// Users with only the read privilege are at most viewers.
+// Admins, and the nil user when authentication is disabled, own every resource.
+// Resources without an owner, created while authentication was disabled, are owned by the admins
+// and every other user is a member of them.
+func (u *User) Role(owner, team string) Role {
+	if u == nil || u.Authorize(AdminPrivilege) {
+		return OwnerRole
+	}
+	var r Role
+	switch {
+	case owner == "":
+		r = MemberRole
+	case owner == u.Name:
+		r = OwnerRole
+	case team != "" && u.InTeam(team):
+		r = MemberRole
+	default:
+		return NoRole
+	}
+	if !u.Authorize(WritePrivilege) {
+		return ViewerRole
+	}
+	return r
+}
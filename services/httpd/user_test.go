@@ -0,0 +1,39 @@
+package httpd
+
+import (
+	"testing"
+)
+
+func TestUser_Role(t *testing.T) {
+	admin := &User{Name: "admin", Privilege: AdminPrivilege}
+	alice := &User{Name: "alice", Privilege: WritePrivilege, Teams: []string{"ops"}}
+	bob := &User{Name: "bob", Privilege: ReadPrivilege, Teams: []string{"ops"}}
+	testCases := []struct {
+		user        *User
+		owner, team string
+		role        Role
+	}{
+		{user: nil, owner: "alice", team: "ops", role: OwnerRole},
+		{user: admin, owner: "alice", team: "", role: OwnerRole},
+		{user: alice, owner: "alice", team: "", role: OwnerRole},
+		{user: admin, owner: "", team: "", role: OwnerRole},
+		{user: alice, owner: "", team: "", role: MemberRole},
+		{user: alice, owner: "", team: "dev", role: MemberRole},
+		{user: alice, owner: "carol", team: "ops", role: MemberRole},
+		{user: alice, owner: "carol", team: "dev", role: NoRole},
+		{user: alice, owner: "carol", team: "", role: NoRole},
+		{user: bob, owner: "alice", team: "ops", role: ViewerRole},
+		{user: bob, owner: "bob", team: "", role: ViewerRole},
+		{user: bob, owner: "", team: "", role: ViewerRole},
+		{user: bob, owner: "alice", team: "dev", role: NoRole},
+	}
+	for _, tc := range testCases {
+		name := "<nil>"
+		if tc.user != nil {
+			name = tc.user.Name
+		}
+		if got := tc.user.Role(tc.owner, tc.team); got != tc.role {
+			t.Errorf("%s for owner %q team %q: unexpected role: got %d exp %d", name, tc.owner, tc.team, got, tc.role)
+		}
+	}
+}
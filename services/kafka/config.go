@@ -0,0 +1,112 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+)
+
+const (
+	DefaultClientID     = "kapacitor"
+	DefaultTimeout      = toml.Duration(10 * time.Second)
+	DefaultRequiredAcks = 1
+	DefaultBatchSize    = 100
+	DefaultBatchTimeout = toml.Duration(time.Second)
+	DefaultBuffer       = 1000
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// The host:port addresses used to discover the cluster.
+	Brokers  []string      `toml:"brokers"`
+	ClientID string        `toml:"client-id"`
+	Timeout  toml.Duration `toml:"timeout"`
+	// The acknowledgements the leader must receive before answering a write:
+	// 0 for none, 1 for the leader only and -1 for all in sync replicas.
+	RequiredAcks int `toml:"required-acks"`
+
+	// The maximum number of messages written at once to a topic.
+	BatchSize int `toml:"batch-size"`
+	// How long messages wait for the batch to fill before they are written.
+	BatchTimeout toml.Duration `toml:"batch-timeout"`
+	// The number of messages queued for a topic, writes block while the queue is full.
+	Buffer int `toml:"buffer"`
+
+	UseTLS             bool   `toml:"use-tls"`
+	SSLCA              string `toml:"ssl-ca"`
+	SSLCert            string `toml:"ssl-cert"`
+	SSLKey             string `toml:"ssl-key"`
+	InsecureSkipVerify bool   `toml:"insecure-skip-verify"`
+
+	// Authenticate with the PLAIN SASL mechanism if the username is set.
+	SASLUsername string `toml:"sasl-username"`
+	SASLPassword string `toml:"sasl-password"`
+}
+
+func NewConfig() Config {
+	return Config{
+		ClientID:     DefaultClientID,
+		Timeout:      DefaultTimeout,
+		RequiredAcks: DefaultRequiredAcks,
+		BatchSize:    DefaultBatchSize,
+		BatchTimeout: DefaultBatchTimeout,
+		Buffer:       DefaultBuffer,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Brokers) == 0 {
+		return errors.New("must specify at least one broker")
+	}
+	if c.RequiredAcks < -1 || c.RequiredAcks > 1 {
+		return errors.New("required-acks must be -1, 0 or 1")
+	}
+	if c.BatchSize <= 0 {
+		return errors.New("batch-size must be positive")
+	}
+	if c.Buffer < 0 {
+		return errors.New("buffer must not be negative")
+	}
+	if c.Timeout <= 0 || c.BatchTimeout <= 0 {
+		return errors.New("timeout and batch-timeout must be positive")
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return errors.New("ssl-cert and ssl-key must be specified together")
+	}
+	return nil
+}
+
+// The TLS configuration of the connections to the brokers, nil if TLS is not used.
+func (c Config) tlsConfig() (*tls.Config, error) {
+	if !c.UseTLS {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.SSLCA != "" {
+		pem, err := ioutil.ReadFile(c.SSLCA)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in ssl-ca " + c.SSLCA)
+		}
+	}
+	if c.SSLCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.SSLCert, c.SSLKey)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
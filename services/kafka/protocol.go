@@ -0,0 +1,475 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// The subset of the Kafka protocol used to produce messages.
+// Only versions supported by all brokers since Kafka 1.0 are used.
+const (
+	apiProduce          int16 = 0
+	apiMetadata         int16 = 3
+	apiSaslHandshake    int16 = 17
+	apiSaslAuthenticate int16 = 36
+
+	produceVersion          int16 = 3
+	metadataVersion         int16 = 4
+	saslHandshakeVersion    int16 = 1
+	saslAuthenticateVersion int16 = 0
+)
+
+// An error code returned by a broker.
+type KafkaError int16
+
+const (
+	ErrNone                         KafkaError = 0
+	ErrCorruptMessage               KafkaError = 2
+	ErrUnknownTopicOrPartition      KafkaError = 3
+	ErrLeaderNotAvailable           KafkaError = 5
+	ErrNotLeaderForPartition        KafkaError = 6
+	ErrRequestTimedOut              KafkaError = 7
+	ErrMessageTooLarge              KafkaError = 10
+	ErrNetworkException             KafkaError = 13
+	ErrNotEnoughReplicas            KafkaError = 19
+	ErrNotEnoughReplicasAfterAppend KafkaError = 20
+	ErrTopicAuthorizationFailed     KafkaError = 29
+	ErrUnsupportedSaslMechanism     KafkaError = 33
+	ErrSaslAuthenticationFailed     KafkaError = 58
+)
+
+var kafkaErrorNames = map[KafkaError]string{
+	ErrCorruptMessage:               "corrupt message",
+	ErrUnknownTopicOrPartition:      "unknown topic or partition",
+	ErrLeaderNotAvailable:           "leader not available",
+	ErrNotLeaderForPartition:        "not leader for partition",
+	ErrRequestTimedOut:              "request timed out",
+	ErrMessageTooLarge:              "message too large",
+	ErrNetworkException:             "network exception",
+	ErrNotEnoughReplicas:            "not enough replicas",
+	ErrNotEnoughReplicasAfterAppend: "not enough replicas after append",
+	ErrTopicAuthorizationFailed:     "topic authorization failed",
+	ErrUnsupportedSaslMechanism:     "unsupported SASL mechanism",
+	ErrSaslAuthenticationFailed:     "SASL authentication failed",
+}
+
+func (e KafkaError) Error() string {
+	if name, ok := kafkaErrorNames[e]; ok {
+		return fmt.Sprintf("kafka error %d: %s", int16(e), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// Whether the error may be resolved by refreshing the metadata and retrying.
+func (e KafkaError) retriable() bool {
+	switch e {
+	case ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderForPartition, ErrRequestTimedOut, ErrNetworkException, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend:
+		return true
+	}
+	return false
+}
+
+var errShortBuffer = errors.New("kafka: malformed message, unexpected end of data")
+
+// Encodes the primitive types of the protocol in big endian.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8) {
+	e.b = append(e.b, byte(v))
+}
+
+func (e *encoder) int16(v int16) {
+	e.b = append(e.b, byte(v>>8), byte(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.b = append(e.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.int32(int32(v >> 32))
+	e.int32(int32(v))
+}
+
+func (e *encoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// Set the int32 at offset i, used for lengths and checksums known after encoding.
+func (e *encoder) putInt32(i int, v int32) {
+	binary.BigEndian.PutUint32(e.b[i:], uint32(v))
+}
+
+// Decodes the primitive types of the protocol,
+// the first error is kept and all later reads return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errShortBuffer
+		d.b = nil
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errShortBuffer
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+func (d *decoder) varbytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// The length of an array, a null array has no elements.
+func (d *decoder) arrayLen() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	// Each element has at least one byte.
+	if n > len(d.b) {
+		d.err = errShortBuffer
+		return 0
+	}
+	return n
+}
+
+// Encode a request with its header, framed by its size.
+func encodeRequest(apiKey, version int16, correlationID int32, clientID string, body []byte) []byte {
+	e := &encoder{b: make([]byte, 0, 4+10+len(clientID)+len(body))}
+	e.int32(0)
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(correlationID)
+	e.string(clientID)
+	e.b = append(e.b, body...)
+	e.putInt32(0, int32(len(e.b)-4))
+	return e.b
+}
+
+// A message to produce.
+type Message struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Encode messages as a version 2 record batch.
+func encodeRecordBatch(messages []Message) []byte {
+	first := messages[0].Time
+	max := first
+	for _, m := range messages {
+		if m.Time.After(max) {
+			max = m.Time
+		}
+	}
+
+	e := &encoder{}
+	e.int64(0)  // base offset
+	e.int32(0)  // batch length
+	e.int32(-1) // partition leader epoch
+	e.int8(2)   // magic
+	e.int32(0)  // crc
+	crcStart := len(e.b)
+	e.int16(0) // attributes, no compression
+	e.int32(int32(len(messages) - 1))
+	e.int64(millis(first))
+	e.int64(millis(max))
+	e.int64(-1) // producer id
+	e.int16(-1) // producer epoch
+	e.int32(-1) // base sequence
+	e.int32(int32(len(messages)))
+	for i, m := range messages {
+		r := &encoder{}
+		r.int8(0) // attributes
+		r.varint(millis(m.Time) - millis(first))
+		r.varint(int64(i))
+		r.varbytes(m.Key)
+		r.varbytes(m.Value)
+		r.varint(0) // headers
+		e.varint(int64(len(r.b)))
+		e.b = append(e.b, r.b...)
+	}
+	e.putInt32(8, int32(len(e.b)-12))
+	e.putInt32(crcStart-4, int32(crc32.Checksum(e.b[crcStart:], crc32c)))
+	return e.b
+}
+
+// Decode the messages of version 2 record batches.
+func decodeRecordBatches(b []byte) ([]Message, error) {
+	var messages []Message
+	d := &decoder{b: b}
+	for len(d.b) > 0 && d.err == nil {
+		d.int64() // base offset
+		length := d.int32()
+		batch := &decoder{b: d.next(int(length))}
+		if d.err != nil {
+			return nil, d.err
+		}
+		batch.int32() // partition leader epoch
+		if magic := batch.int8(); magic != 2 {
+			return nil, fmt.Errorf("kafka: unsupported record batch version %d", magic)
+		}
+		crc := uint32(batch.int32())
+		if batch.err == nil && crc32.Checksum(batch.b, crc32c) != crc {
+			return nil, ErrCorruptMessage
+		}
+		if attributes := batch.int16(); attributes&0x7 != 0 {
+			return nil, errors.New("kafka: compressed record batches are not supported")
+		}
+		batch.int32() // last offset delta
+		first := batch.int64()
+		batch.int64() // max timestamp
+		batch.int64() // producer id
+		batch.int16() // producer epoch
+		batch.int32() // base sequence
+		n := batch.arrayLen()
+		for i := 0; i < n; i++ {
+			r := &decoder{b: batch.next(int(batch.varint()))}
+			r.int8() // attributes
+			delta := r.varint()
+			r.varint() // offset delta
+			m := Message{
+				Key:   r.varbytes(),
+				Value: r.varbytes(),
+				Time:  fromMillis(first + delta),
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+			messages = append(messages, m)
+		}
+		if batch.err != nil {
+			return nil, batch.err
+		}
+	}
+	return messages, d.err
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// The metadata of the brokers and the partitions of topics.
+type metadata struct {
+	brokers map[int32]string
+	topics  map[string]topicMetadata
+}
+
+type topicMetadata struct {
+	err KafkaError
+	// The leaders of the partitions, indexed by partition.
+	leaders []int32
+}
+
+func encodeMetadataRequest(topics []string) []byte {
+	e := &encoder{}
+	e.int32(int32(len(topics)))
+	for _, t := range topics {
+		e.string(t)
+	}
+	e.int8(1) // allow auto topic creation
+	return e.b
+}
+
+func decodeMetadataResponse(b []byte) (metadata, error) {
+	d := &decoder{b: b}
+	md := metadata{
+		brokers: make(map[int32]string),
+		topics:  make(map[string]topicMetadata),
+	}
+	d.int32() // throttle time
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		md.brokers[id] = fmt.Sprintf("%s:%d", host, port)
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		tm := topicMetadata{err: KafkaError(d.int16())}
+		name := d.string()
+		d.int8() // is internal
+		np := d.arrayLen()
+		tm.leaders = make([]int32, np)
+		for j := 0; j < np; j++ {
+			d.int16() // partition error
+			partition := d.int32()
+			leader := d.int32()
+			for k, nr := 0, d.arrayLen(); k < nr; k++ {
+				d.int32() // replica
+			}
+			for k, ni := 0, d.arrayLen(); k < ni; k++ {
+				d.int32() // in sync replica
+			}
+			if partition >= 0 && int(partition) < np {
+				tm.leaders[partition] = leader
+			}
+		}
+		md.topics[name] = tm
+	}
+	return md, d.err
+}
+
+func encodeProduceRequest(acks int16, timeout time.Duration, topic string, partitions map[int32][]Message) []byte {
+	e := &encoder{}
+	e.nullableString(nil) // transactional id
+	e.int16(acks)
+	e.int32(int32(timeout / time.Millisecond))
+	e.int32(1)
+	e.string(topic)
+	e.int32(int32(len(partitions)))
+	for p, messages := range partitions {
+		e.int32(p)
+		e.bytes(encodeRecordBatch(messages))
+	}
+	return e.b
+}
+
+func encodeSaslHandshakeRequest(mechanism string) []byte {
+	e := &encoder{}
+	e.string(mechanism)
+	return e.b
+}
+
+// Encode the authenticate request of the PLAIN mechanism, see RFC 4616,
+// without an authorization identity.
+func encodeSaslPlainRequest(username, password string) []byte {
+	e := &encoder{}
+	e.bytes([]byte("\x00" + username + "\x00" + password))
+	return e.b
+}
+
+// Decode a produce response and return the errors of the partitions that failed.
+func decodeProduceResponse(b []byte) (map[int32]KafkaError, error) {
+	d := &decoder{b: b}
+	errs := make(map[int32]KafkaError)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, np := 0, d.arrayLen(); j < np; j++ {
+			p := d.int32()
+			if code := KafkaError(d.int16()); code != ErrNone {
+				errs[p] = code
+			}
+			d.int64() // base offset
+			d.int64() // log append time
+		}
+	}
+	d.int32() // throttle time
+	if d.err != nil {
+		return nil, d.err
+	}
+	return errs, nil
+}
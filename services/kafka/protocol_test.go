@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// The expected bytes are written out field by field from the protocol guide,
+// https://kafka.apache.org/protocol, and the record batch format,
+// https://kafka.apache.org/documentation/#recordbatch,
+// so that they do not depend on the encoder and decoder of this package.
+
+// Two messages, the second without a key and 2ms after the first.
+var goldenMessages = []Message{
+	{Key: []byte("h"), Value: []byte("v1"), Time: time.Unix(1, 0)},
+	{Value: []byte("v2"), Time: time.Unix(1, 2*int64(time.Millisecond))},
+}
+
+var goldenRecordBatch = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // baseOffset: 0
+	0x00, 0x00, 0x00, 0x44, // batchLength: 68, the bytes after this field
+	0xff, 0xff, 0xff, 0xff, // partitionLeaderEpoch: -1
+	0x02,                   // magic: 2
+	0x8b, 0xb6, 0xe4, 0x59, // crc: CRC-32C of attributes to the end of the batch
+	0x00, 0x00, // attributes: no compression, create time
+	0x00, 0x00, 0x00, 0x01, // lastOffsetDelta: 1
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xe8, // firstTimestamp: 1000ms
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xea, // maxTimestamp: 1002ms
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // producerId: -1
+	0xff, 0xff, // producerEpoch: -1
+	0xff, 0xff, 0xff, 0xff, // baseSequence: -1
+	0x00, 0x00, 0x00, 0x02, // records: 2
+	// The fields of a record are zigzag varints.
+	0x12,      // length: 9
+	0x00,      // attributes
+	0x00,      // timestampDelta: 0
+	0x00,      // offsetDelta: 0
+	0x02, 'h', // keyLength: 1, key
+	0x04, 'v', '1', // valueLength: 2, value
+	0x00,           // headers: 0
+	0x10,           // length: 8
+	0x00,           // attributes
+	0x04,           // timestampDelta: 2
+	0x02,           // offsetDelta: 1
+	0x01,           // keyLength: -1, null key
+	0x04, 'v', '2', // valueLength: 2, value
+	0x00, // headers: 0
+}
+
+func TestEncodeRecordBatch(t *testing.T) {
+	if got := encodeRecordBatch(goldenMessages); !bytes.Equal(got, goldenRecordBatch) {
+		t.Errorf("unexpected record batch:\ngot % x\nexp % x", got, goldenRecordBatch)
+	}
+}
+
+func TestDecodeRecordBatches(t *testing.T) {
+	messages, err := decodeRecordBatches(goldenRecordBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, got := len(goldenMessages), len(messages); got != exp {
+		t.Fatalf("unexpected number of messages: got %d exp %d", got, exp)
+	}
+	for i, m := range messages {
+		exp := goldenMessages[i]
+		if !bytes.Equal(m.Key, exp.Key) || !bytes.Equal(m.Value, exp.Value) || !m.Time.Equal(exp.Time) {
+			t.Errorf("unexpected message %d: got %+v exp %+v", i, m, exp)
+		}
+	}
+}
+
+func TestEncodeProduceRequest(t *testing.T) {
+	exp := []byte{
+		0xff, 0xff, // transactional_id: null
+		0xff, 0xff, // acks: -1
+		0x00, 0x00, 0x27, 0x10, // timeout: 10000ms
+		0x00, 0x00, 0x00, 0x01, // topic_data: 1
+		0x00, 0x03, 'c', 'p', 'u', // topic
+		0x00, 0x00, 0x00, 0x01, // data: 1
+		0x00, 0x00, 0x00, 0x02, // partition: 2
+		0x00, 0x00, 0x00, 0x50, // record_set: 80 bytes
+	}
+	exp = append(exp, goldenRecordBatch...)
+	got := encodeProduceRequest(-1, 10*time.Second, "cpu", map[int32][]Message{2: goldenMessages})
+	if !bytes.Equal(got, exp) {
+		t.Errorf("unexpected produce request:\ngot % x\nexp % x", got, exp)
+	}
+}
+
+func TestEncodeMetadataRequest(t *testing.T) {
+	exp := []byte{
+		0x00, 0x00, 0x00, 0x1d, // size: 29
+		0x00, 0x03, // api_key: Metadata
+		0x00, 0x04, // api_version: 4
+		0x00, 0x00, 0x00, 0x07, // correlation_id: 7
+		0x00, 0x09, 'k', 'a', 'p', 'a', 'c', 'i', 't', 'o', 'r', // client_id
+		0x00, 0x00, 0x00, 0x01, // topics: 1
+		0x00, 0x03, 'c', 'p', 'u', // name
+		0x01, // allow_auto_topic_creation: true
+	}
+	got := encodeRequest(apiMetadata, metadataVersion, 7, "kapacitor", encodeMetadataRequest([]string{"cpu"}))
+	if !bytes.Equal(got, exp) {
+		t.Errorf("unexpected metadata request:\ngot % x\nexp % x", got, exp)
+	}
+}
+
+func TestEncodeSaslRequests(t *testing.T) {
+	exp := []byte{
+		0x00, 0x00, 0x00, 0x1a, // size: 26
+		0x00, 0x11, // api_key: SaslHandshake
+		0x00, 0x01, // api_version: 1
+		0x00, 0x00, 0x00, 0x01, // correlation_id: 1
+		0x00, 0x09, 'k', 'a', 'p', 'a', 'c', 'i', 't', 'o', 'r', // client_id
+		0x00, 0x05, 'P', 'L', 'A', 'I', 'N', // mechanism
+	}
+	got := encodeRequest(apiSaslHandshake, saslHandshakeVersion, 1, "kapacitor", encodeSaslHandshakeRequest("PLAIN"))
+	if !bytes.Equal(got, exp) {
+		t.Errorf("unexpected SASL handshake request:\ngot % x\nexp % x", got, exp)
+	}
+
+	exp = []byte{
+		0x00, 0x00, 0x00, 0x23, // size: 35
+		0x00, 0x24, // api_key: SaslAuthenticate
+		0x00, 0x00, // api_version: 0
+		0x00, 0x00, 0x00, 0x02, // correlation_id: 2
+		0x00, 0x09, 'k', 'a', 'p', 'a', 'c', 'i', 't', 'o', 'r', // client_id
+		0x00, 0x00, 0x00, 0x0c, // auth_bytes: 12 bytes
+		0x00, 'a', 'l', 'i', 'c', 'e', 0x00, 's', 'e', 'c', 'r', 't', // NUL authcid NUL passwd
+	}
+	got = encodeRequest(apiSaslAuthenticate, saslAuthenticateVersion, 2, "kapacitor", encodeSaslPlainRequest("alice", "secrt"))
+	if !bytes.Equal(got, exp) {
+		t.Errorf("unexpected SASL authenticate request:\ngot % x\nexp % x", got, exp)
+	}
+}
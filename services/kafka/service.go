@@ -0,0 +1,370 @@
+// The kafka service writes the messages of kafkaOut nodes to Kafka topics.
+//
+// The messages of a topic are queued and written asynchronously in batches to the
+// leaders of their partitions, the partition of a message is chosen from the hash of its key.
+// The messages of the partitions that fail are retried, messages that cannot be written
+// after retrying are dropped and counted in the stats of the node.
+//
+// The service speaks the Kafka protocol itself instead of depending on a client library.
+// protocol.go implements only what producing needs: the metadata, produce and SASL PLAIN requests
+// in the versions every broker since Kafka 1.0 supports, and record batches without compression.
+// It is tested against the encodings of the protocol guide and against a fake broker.
+// Features beyond that, such as compression, idempotent writes or consumers,
+// should replace protocol.go with a maintained client such as sarama rather than extend it.
+package kafka
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// The number of times a batch is written before it is dropped.
+const produceAttempts = 3
+
+// The largest response accepted from a broker,
+// a larger size is taken to be a corrupt or hostile response.
+const maxResponseSize = 100 * 1024 * 1024
+
+type Service struct {
+	config    Config
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	conns    map[string]*brokerConn
+	brokers  map[int32]string
+	topics   map[string]topicMetadata
+	nextPart uint32
+
+	logger *log.Logger
+}
+
+func NewService(c Config, l *log.Logger) *Service {
+	return &Service{
+		config:  c,
+		conns:   make(map[string]*brokerConn),
+		brokers: make(map[int32]string),
+		topics:  make(map[string]topicMetadata),
+		logger:  l,
+	}
+}
+
+func (s *Service) Open() error {
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+	tc, err := s.config.tlsConfig()
+	if err != nil {
+		return err
+	}
+	s.tlsConfig = tc
+	s.logger.Println("I! opened service, brokers:", s.config.Brokers)
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for addr, c := range s.conns {
+		c.conn.Close()
+		delete(s.conns, addr)
+	}
+	return nil
+}
+
+// Write messages to a topic and return the messages that were not written.
+// The messages of the partitions that failed are retried
+// on errors that may be resolved by refreshing the metadata.
+func (s *Service) produce(topic string, messages []Message) ([]Message, error) {
+	var err error
+	for attempt := 0; attempt < produceAttempts; attempt++ {
+		if attempt > 0 {
+			s.logger.Printf("D! retrying write of %d messages to topic %s: %s", len(messages), topic, err)
+			s.invalidate(topic)
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		messages, err = s.tryProduce(topic, messages)
+		if err == nil {
+			return nil, nil
+		}
+		if ke, ok := err.(KafkaError); ok && !ke.retriable() {
+			return messages, err
+		}
+	}
+	return messages, err
+}
+
+// Write messages to the leaders of their partitions
+// and return the messages of the partitions that failed with the last error.
+func (s *Service) tryProduce(topic string, messages []Message) ([]Message, error) {
+	leaders, err := s.leaders(topic)
+	if err != nil {
+		return messages, err
+	}
+
+	// Group the messages by the leader of their partition.
+	byLeader := make(map[int32]map[int32][]Message)
+	for _, m := range messages {
+		p := s.partition(m.Key, len(leaders))
+		leader := leaders[p]
+		if byLeader[leader] == nil {
+			byLeader[leader] = make(map[int32][]Message)
+		}
+		byLeader[leader][p] = append(byLeader[leader][p], m)
+	}
+
+	var failed []Message
+	err = nil
+	for leader, partitions := range byLeader {
+		errs, lerr := s.produceTo(leader, topic, partitions)
+		if lerr != nil {
+			for _, pm := range partitions {
+				failed = append(failed, pm...)
+			}
+			err = lerr
+			continue
+		}
+		for p, code := range errs {
+			failed = append(failed, partitions[p]...)
+			err = code
+		}
+	}
+	return failed, err
+}
+
+// Write the messages of partitions to their leader
+// and return the errors of the partitions that failed.
+func (s *Service) produceTo(leader int32, topic string, partitions map[int32][]Message) (map[int32]KafkaError, error) {
+	s.mu.Lock()
+	addr, ok := s.brokers[leader]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrLeaderNotAvailable
+	}
+	acks := int16(s.config.RequiredAcks)
+	body := encodeProduceRequest(acks, time.Duration(s.config.Timeout), topic, partitions)
+	resp, err := s.request(addr, apiProduce, produceVersion, body, acks != 0)
+	if err != nil || acks == 0 {
+		return nil, err
+	}
+	return decodeProduceResponse(resp)
+}
+
+// The partition of a key, messages without a key are spread over the partitions.
+func (s *Service) partition(key []byte, n int) int32 {
+	if key == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.nextPart++
+		return int32(s.nextPart % uint32(n))
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(n))
+}
+
+// The leaders of the partitions of a topic, the metadata is requested if it is not known.
+func (s *Service) leaders(topic string) ([]int32, error) {
+	s.mu.Lock()
+	tm, ok := s.topics[topic]
+	s.mu.Unlock()
+	if !ok {
+		var err error
+		tm, err = s.refreshMetadata(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if tm.err != ErrNone {
+		return nil, tm.err
+	}
+	if len(tm.leaders) == 0 {
+		return nil, ErrLeaderNotAvailable
+	}
+	for _, l := range tm.leaders {
+		if l < 0 {
+			return nil, ErrLeaderNotAvailable
+		}
+	}
+	return tm.leaders, nil
+}
+
+// Request the metadata of a topic from the first broker that answers.
+func (s *Service) refreshMetadata(topic string) (topicMetadata, error) {
+	var err error
+	for _, addr := range s.config.Brokers {
+		var resp []byte
+		resp, err = s.request(addr, apiMetadata, metadataVersion, encodeMetadataRequest([]string{topic}), true)
+		if err != nil {
+			continue
+		}
+		var md metadata
+		md, err = decodeMetadataResponse(resp)
+		if err != nil {
+			continue
+		}
+		tm, ok := md.topics[topic]
+		if !ok {
+			return tm, ErrUnknownTopicOrPartition
+		}
+		s.mu.Lock()
+		for id, a := range md.brokers {
+			s.brokers[id] = a
+		}
+		if tm.err == ErrNone {
+			s.topics[topic] = tm
+		}
+		s.mu.Unlock()
+		return tm, nil
+	}
+	return topicMetadata{}, fmt.Errorf("failed to get metadata of topic %s from brokers %v: %s", topic, s.config.Brokers, err)
+}
+
+func (s *Service) invalidate(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.topics, topic)
+}
+
+// Send a request to a broker and return the response body.
+// The connection is closed on errors and reopened by the next request.
+func (s *Service) request(addr string, apiKey, version int16, body []byte, response bool) ([]byte, error) {
+	c, err := s.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.roundTrip(apiKey, version, body, response)
+	if err != nil {
+		s.mu.Lock()
+		if s.conns[addr] == c {
+			delete(s.conns, addr)
+		}
+		s.mu.Unlock()
+		c.conn.Close()
+	}
+	return resp, err
+}
+
+func (s *Service) conn(addr string) (*brokerConn, error) {
+	s.mu.Lock()
+	c, ok := s.conns[addr]
+	s.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	c, err := s.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.conns[addr]; ok {
+		c.conn.Close()
+		return existing, nil
+	}
+	s.conns[addr] = c
+	return c, nil
+}
+
+func (s *Service) dial(addr string) (*brokerConn, error) {
+	timeout := time.Duration(s.config.Timeout)
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &brokerConn{
+		conn:     conn,
+		clientID: s.config.ClientID,
+		timeout:  timeout,
+	}
+	if s.config.SASLUsername != "" {
+		if err := c.authenticate(s.config.SASLUsername, s.config.SASLPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate with broker %s: %s", addr, err)
+		}
+	}
+	return c, nil
+}
+
+// A connection to a broker, requests are sent one at a time.
+type brokerConn struct {
+	mu            sync.Mutex
+	conn          net.Conn
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+func (c *brokerConn) roundTrip(apiKey, version int16, body []byte, response bool) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.correlationID++
+	id := c.correlationID
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(encodeRequest(apiKey, version, id, c.clientID, body)); err != nil {
+		return nil, err
+	}
+	if !response {
+		return nil, nil
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxResponseSize {
+		return nil, fmt.Errorf("kafka: response of %d bytes exceeds the maximum of %d bytes", n, maxResponseSize)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != id {
+		return nil, errors.New("kafka: response does not match the request")
+	}
+	return resp[4:], nil
+}
+
+// Authenticate with the PLAIN SASL mechanism.
+func (c *brokerConn) authenticate(username, password string) error {
+	resp, err := c.roundTrip(apiSaslHandshake, saslHandshakeVersion, encodeSaslHandshakeRequest("PLAIN"), true)
+	if err != nil {
+		return err
+	}
+	d := &decoder{b: resp}
+	if code := KafkaError(d.int16()); code != ErrNone {
+		return code
+	}
+
+	resp, err = c.roundTrip(apiSaslAuthenticate, saslAuthenticateVersion, encodeSaslPlainRequest(username, password), true)
+	if err != nil {
+		return err
+	}
+	d = &decoder{b: resp}
+	code := KafkaError(d.int16())
+	msg := d.string()
+	if d.err != nil {
+		return d.err
+	}
+	if code != ErrNone {
+		if msg != "" {
+			return fmt.Errorf("%s: %s", code, msg)
+		}
+		return code
+	}
+	return nil
+}
@@ -0,0 +1,578 @@
+package kafka
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+)
+
+// An in-process broker that leads all partitions of all topics
+// and keeps the messages written to them.
+type fakeBroker struct {
+	ln         net.Listener
+	partitions int32
+	username   string
+	password   string
+	// The brokers of the cluster, the ID of a broker is its index
+	// and partition p is led by broker p % len(cluster).
+	// The broker leads all partitions if there is no cluster.
+	cluster []*fakeBroker
+
+	mu sync.Mutex
+	// The messages of each partition of each topic.
+	messages map[string]map[int32][]Message
+	// The number of requests of each API.
+	requests map[int16]int
+	// The number of produce requests answered with an error.
+	failProduce int
+}
+
+// Start serving the broker b, with TLS if tc is not nil.
+func newFakeBroker(t *testing.T, b *fakeBroker, tc *tls.Config) *fakeBroker {
+	var ln net.Listener
+	var err error
+	if tc != nil {
+		ln, err = tls.Listen("tcp", "127.0.0.1:0", tc)
+	} else {
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ln = ln
+	b.messages = make(map[string]map[int32][]Message)
+	b.requests = make(map[int16]int)
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+func (b *fakeBroker) Close() {
+	b.ln.Close()
+}
+
+func (b *fakeBroker) Messages(topic string) map[int32][]Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.messages[topic]
+}
+
+func (b *fakeBroker) Requests(apiKey int16) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests[apiKey]
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.serveConn(conn)
+	}
+}
+
+func (b *fakeBroker) serveConn(conn net.Conn) {
+	defer conn.Close()
+	authenticated := b.username == ""
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := &decoder{b: req}
+		apiKey := d.int16()
+		d.int16() // version
+		correlationID := d.int32()
+		d.string() // client id
+		if d.err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.requests[apiKey]++
+		b.mu.Unlock()
+
+		resp := &encoder{}
+		resp.int32(0)
+		resp.int32(correlationID)
+		switch apiKey {
+		case apiSaslHandshake:
+			if d.string() == "PLAIN" {
+				resp.int16(int16(ErrNone))
+			} else {
+				resp.int16(int16(ErrUnsupportedSaslMechanism))
+			}
+			resp.int32(1)
+			resp.string("PLAIN")
+		case apiSaslAuthenticate:
+			if string(d.bytes()) == "\x00"+b.username+"\x00"+b.password {
+				authenticated = true
+				resp.int16(int16(ErrNone))
+				resp.string("")
+			} else {
+				resp.int16(int16(ErrSaslAuthenticationFailed))
+				resp.string("invalid credentials")
+			}
+			resp.bytes([]byte{})
+		default:
+			if !authenticated {
+				return
+			}
+			if !b.handle(apiKey, d, resp) {
+				continue
+			}
+		}
+		resp.putInt32(0, int32(len(resp.b)-4))
+		if _, err := conn.Write(resp.b); err != nil {
+			return
+		}
+	}
+}
+
+// Handle a request, returns whether a response is sent.
+func (b *fakeBroker) handle(apiKey int16, d *decoder, resp *encoder) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch apiKey {
+	case apiMetadata:
+		cluster := b.cluster
+		if len(cluster) == 0 {
+			cluster = []*fakeBroker{b}
+		}
+		resp.int32(0) // throttle time
+		resp.int32(int32(len(cluster)))
+		for id, cb := range cluster {
+			host, port, _ := net.SplitHostPort(cb.Addr())
+			p, _ := strconv.Atoi(port)
+			resp.int32(int32(id))
+			resp.string(host)
+			resp.int32(int32(p))
+			resp.nullableString(nil)
+		}
+		resp.nullableString(nil) // cluster id
+		resp.int32(0)            // controller id
+		n := d.arrayLen()
+		resp.int32(int32(n))
+		for i := 0; i < n; i++ {
+			resp.int16(int16(ErrNone))
+			resp.string(d.string())
+			resp.int8(0)
+			resp.int32(b.partitions)
+			for p := int32(0); p < b.partitions; p++ {
+				resp.int16(int16(ErrNone))
+				resp.int32(p)
+				resp.int32(p % int32(len(cluster))) // leader
+				resp.int32(1)
+				resp.int32(0)
+				resp.int32(1)
+				resp.int32(0)
+			}
+		}
+	case apiProduce:
+		d.string() // transactional id
+		acks := d.int16()
+		d.int32() // timeout
+		code := ErrNone
+		if b.failProduce > 0 {
+			b.failProduce--
+			code = ErrNotLeaderForPartition
+		}
+		nt := d.arrayLen()
+		resp.int32(int32(nt))
+		for i := 0; i < nt; i++ {
+			topic := d.string()
+			resp.string(topic)
+			np := d.arrayLen()
+			resp.int32(int32(np))
+			for j := 0; j < np; j++ {
+				p := d.int32()
+				messages, err := decodeRecordBatches(d.bytes())
+				if err != nil {
+					panic(err)
+				}
+				if code == ErrNone {
+					if b.messages[topic] == nil {
+						b.messages[topic] = make(map[int32][]Message)
+					}
+					b.messages[topic][p] = append(b.messages[topic][p], messages...)
+				}
+				resp.int32(p)
+				resp.int16(int16(code))
+				resp.int64(0)
+				resp.int64(-1)
+			}
+		}
+		resp.int32(0) // throttle time
+		if acks == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestService(t *testing.T, c Config) *Service {
+	c.Enabled = true
+	s := NewService(c, log.New(ioutil.Discard, "", 0))
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newStatMap() *expvar.Map {
+	sm := &expvar.Map{}
+	sm.Init()
+	return sm
+}
+
+func TestService_Write(t *testing.T) {
+	b := newFakeBroker(t, &fakeBroker{partitions: 3}, nil)
+	defer b.Close()
+
+	c := NewConfig()
+	c.Brokers = []string{b.Addr()}
+	c.BatchSize = 2
+	c.BatchTimeout = toml.Duration(10 * time.Millisecond)
+	s := newTestService(t, c)
+	defer s.Close()
+
+	sm := newStatMap()
+	w, err := s.NewWriter("cpu", sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := []string{"A", "B", "A", "C", "A"}
+	for i, key := range keys {
+		if err := w.Write([]byte(key), []byte(strconv.Itoa(i)), now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(nil, []byte("no key"), now); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if err := w.Write(nil, []byte("closed"), now); err != errWriterClosed {
+		t.Errorf("unexpected error writing to a closed writer got %v exp %v", err, errWriterClosed)
+	}
+
+	// All messages of a key are in the same partition in order.
+	partitions := make(map[string]int32)
+	values := make(map[string]string)
+	count := 0
+	for p, messages := range b.Messages("cpu") {
+		for _, m := range messages {
+			count++
+			key := string(m.Key)
+			if m.Key == nil {
+				if string(m.Value) != "no key" {
+					t.Errorf("unexpected message without key %q", m.Value)
+				}
+				continue
+			}
+			if other, ok := partitions[key]; ok && other != p {
+				t.Errorf("messages of key %s in partitions %d and %d", key, other, p)
+			}
+			partitions[key] = p
+			values[key] += string(m.Value)
+			i, _ := strconv.Atoi(string(m.Value))
+			if exp := now.Add(time.Duration(i) * time.Second); !m.Time.Equal(exp) {
+				t.Errorf("unexpected time of message %s got %v exp %v", m.Value, m.Time, exp)
+			}
+		}
+	}
+	if count != len(keys)+1 {
+		t.Errorf("unexpected number of messages got %d exp %d", count, len(keys)+1)
+	}
+	if exp := map[string]string{"A": "024", "B": "1", "C": "3"}; !mapsEqual(values, exp) {
+		t.Errorf("unexpected values got %v exp %v", values, exp)
+	}
+	if got := sm.Get(statMessagesWritten).String(); got != "6" {
+		t.Errorf("unexpected messages written got %s exp 6", got)
+	}
+	if got := b.Requests(apiMetadata); got != 1 {
+		t.Errorf("unexpected metadata requests got %d exp 1", got)
+	}
+}
+
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func TestService_Retry(t *testing.T) {
+	b := newFakeBroker(t, &fakeBroker{partitions: 1, failProduce: 1}, nil)
+	defer b.Close()
+
+	c := NewConfig()
+	c.Brokers = []string{b.Addr()}
+	s := newTestService(t, c)
+	defer s.Close()
+
+	if _, err := s.produce("cpu", []Message{{Value: []byte("1"), Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(b.Messages("cpu")[0]); got != 1 {
+		t.Errorf("unexpected number of messages got %d exp 1", got)
+	}
+	// The metadata is refreshed after the error.
+	if got := b.Requests(apiMetadata); got != 2 {
+		t.Errorf("unexpected metadata requests got %d exp 2", got)
+	}
+}
+
+func TestService_RetryFailedPartitions(t *testing.T) {
+	b0 := newFakeBroker(t, &fakeBroker{partitions: 2}, nil)
+	defer b0.Close()
+	b1 := newFakeBroker(t, &fakeBroker{partitions: 2, failProduce: 1}, nil)
+	defer b1.Close()
+	cluster := []*fakeBroker{b0, b1}
+	b0.cluster = cluster
+	b1.cluster = cluster
+
+	c := NewConfig()
+	c.Brokers = []string{b0.Addr()}
+	s := newTestService(t, c)
+	defer s.Close()
+
+	// Messages without a key are spread over both partitions and so both leaders.
+	var messages []Message
+	for i := 0; i < 4; i++ {
+		messages = append(messages, Message{Value: []byte(strconv.Itoa(i)), Time: time.Now()})
+	}
+	if _, err := s.produce("cpu", messages); err != nil {
+		t.Fatal(err)
+	}
+	// Only the messages of the failed leader are retried, so no message is written twice.
+	values := make(map[string]int)
+	for _, b := range cluster {
+		for _, pm := range b.Messages("cpu") {
+			for _, m := range pm {
+				values[string(m.Value)]++
+			}
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if got := values[strconv.Itoa(i)]; got != 1 {
+			t.Errorf("unexpected number of writes of message %d got %d exp 1", i, got)
+		}
+	}
+}
+
+func TestService_ResponseTooLarge(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			closed <- err
+			return
+		}
+		defer conn.Close()
+		// Announce a response larger than the maximum and wait for the connection to be closed.
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], maxResponseSize+1)
+		conn.Write(size[:])
+		_, err = io.Copy(ioutil.Discard, conn)
+		closed <- err
+	}()
+
+	c := NewConfig()
+	c.Brokers = []string{ln.Addr().String()}
+	s := newTestService(t, c)
+	defer s.Close()
+	if _, err := s.request(ln.Addr().String(), apiMetadata, metadataVersion, encodeMetadataRequest([]string{"cpu"}), true); err == nil {
+		t.Fatal("expected error for a response larger than the maximum")
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the connection to be closed")
+	}
+}
+
+func TestService_NoAcks(t *testing.T) {
+	b := newFakeBroker(t, &fakeBroker{partitions: 1}, nil)
+	defer b.Close()
+
+	c := NewConfig()
+	c.Brokers = []string{b.Addr()}
+	c.RequiredAcks = 0
+	s := newTestService(t, c)
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.produce("cpu", []Message{{Value: []byte("1"), Time: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The broker does not answer, wait for it to receive the messages.
+	for i := 0; i < 100 && len(b.Messages("cpu")[0]) < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(b.Messages("cpu")[0]); got != 2 {
+		t.Errorf("unexpected number of messages got %d exp 2", got)
+	}
+}
+
+func TestService_SASL(t *testing.T) {
+	b := newFakeBroker(t, &fakeBroker{partitions: 1, username: "kapacitor", password: "secret"}, nil)
+	defer b.Close()
+
+	c := NewConfig()
+	c.Brokers = []string{b.Addr()}
+	c.SASLUsername = "kapacitor"
+	c.SASLPassword = "wrong"
+	s := newTestService(t, c)
+	sm := newStatMap()
+	w, err := s.NewWriter("cpu", sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(nil, []byte("1"), time.Now())
+	w.Close()
+	s.Close()
+	if got := sm.Get(statMessagesDropped).String(); got != "1" {
+		t.Errorf("unexpected messages dropped got %s exp 1", got)
+	}
+	if got := sm.Get(statWriteErrors).String(); got != "1" {
+		t.Errorf("unexpected write errors got %s exp 1", got)
+	}
+
+	c.SASLPassword = "secret"
+	s = newTestService(t, c)
+	defer s.Close()
+	if _, err := s.produce("cpu", []Message{{Value: []byte("2"), Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if messages := b.Messages("cpu")[0]; len(messages) != 1 || !bytes.Equal(messages[0].Value, []byte("2")) {
+		t.Errorf("unexpected messages %v", messages)
+	}
+}
+
+func TestService_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := generateCert(t, dir)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newFakeBroker(t, &fakeBroker{partitions: 1}, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer b.Close()
+
+	c := NewConfig()
+	c.Brokers = []string{b.Addr()}
+	c.UseTLS = true
+	c.SSLCA = certFile
+	s := newTestService(t, c)
+	defer s.Close()
+	if _, err := s.produce("cpu", []Message{{Value: []byte("1"), Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(b.Messages("cpu")[0]); got != 1 {
+		t.Errorf("unexpected number of messages got %d exp 1", got)
+	}
+}
+
+// Generate a self signed certificate for 127.0.0.1 and return the certificate and key files.
+func generateCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "broker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "broker.pem")
+	keyFile := filepath.Join(dir, "broker-key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := NewConfig()
+	c.Enabled = true
+	if err := c.Validate(); err == nil {
+		t.Error("expected error without brokers")
+	}
+	c.Brokers = []string{"localhost:9092"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	c.RequiredAcks = 2
+	if err := c.Validate(); err == nil {
+		t.Error("expected error with invalid required acks")
+	}
+	c.RequiredAcks = DefaultRequiredAcks
+	c.Buffer = -1
+	if err := c.Validate(); err == nil {
+		t.Error("expected error with negative buffer")
+	}
+}
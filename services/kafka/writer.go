@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor"
+)
+
+// statistics gathered by the writers of the kafka service.
+const (
+	statMessagesWritten = "messages_written"
+	statMessagesDropped = "messages_dropped"
+	statWriteErrors     = "write_errors"
+)
+
+var errWriterClosed = errors.New("kafka writer closed")
+
+// Create a writer of messages to a topic.
+// The stats of the writer are added to the given map.
+func (s *Service) NewWriter(topic string, statMap *expvar.Map) (kapacitor.KafkaWriter, error) {
+	if topic == "" {
+		return nil, errors.New("must specify a topic")
+	}
+	statMap.Add(statMessagesWritten, 0)
+	statMap.Add(statMessagesDropped, 0)
+	statMap.Add(statWriteErrors, 0)
+	w := &writer{
+		s:        s,
+		topic:    topic,
+		messages: make(chan Message, s.config.Buffer),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+		statMap:  statMap,
+	}
+	go w.run()
+	return w, nil
+}
+
+// Queues messages of a topic and writes them in batches.
+type writer struct {
+	s        *Service
+	topic    string
+	messages chan Message
+	closing  chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	statMap  *expvar.Map
+}
+
+// Queue a message, blocking while the queue is full.
+func (w *writer) Write(key, value []byte, t time.Time) error {
+	select {
+	case <-w.closing:
+		return errWriterClosed
+	default:
+	}
+	select {
+	case w.messages <- Message{Key: key, Value: value, Time: t}:
+		return nil
+	case <-w.closing:
+		return errWriterClosed
+	}
+}
+
+// Write the queued messages and stop the writer.
+func (w *writer) Close() error {
+	w.once.Do(func() {
+		close(w.closing)
+	})
+	<-w.done
+	return nil
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+	size := w.s.config.BatchSize
+	batch := make([]Message, 0, size)
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+			timeout = nil
+		}
+		if len(batch) > 0 {
+			w.write(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case m := <-w.messages:
+			batch = append(batch, m)
+			if len(batch) == 1 {
+				timer = time.NewTimer(time.Duration(w.s.config.BatchTimeout))
+				timeout = timer.C
+			}
+			if len(batch) >= size {
+				flush()
+			}
+		case <-timeout:
+			timer = nil
+			flush()
+		case <-w.closing:
+			for {
+				select {
+				case m := <-w.messages:
+					batch = append(batch, m)
+					if len(batch) >= size {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *writer) write(batch []Message) {
+	dropped, err := w.s.produce(w.topic, batch)
+	if err != nil {
+		w.statMap.Add(statWriteErrors, 1)
+		w.statMap.Add(statMessagesDropped, int64(len(dropped)))
+		w.s.logger.Printf("E! failed to write %d messages to topic %s: %s", len(dropped), w.topic, err)
+	}
+	w.statMap.Add(statMessagesWritten, int64(len(batch)-len(dropped)))
+}
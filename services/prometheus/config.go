@@ -0,0 +1,11 @@
+package prometheus
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Enabled: true,
+	}
+}
@@ -0,0 +1,208 @@
+// The prometheus service exposes the internal stats at /metrics
+// in the Prometheus text exposition format.
+//
+// Each stat is a metric named kapacitor_<name>_<stat>, for example kapacitor_nodes_avg_exec_time_ns,
+// and the tags of the stat, such as the task and node, are its labels.
+// Since the type of the stats is not known all metrics are untyped.
+//
+// Example scrape configuration:
+//
+//    scrape_configs:
+//      - job_name: kapacitor
+//        static_configs:
+//          - targets: ['localhost:9092']
+//
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+const (
+	metricsPath = "/metrics"
+	// The version 0.0.4 text exposition format.
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+	namespace   = "kapacitor"
+)
+
+type Service struct {
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+	TaskMaster interface {
+		IsExecuting(name string) bool
+	}
+
+	routes []httpd.Route
+	logger *log.Logger
+}
+
+func NewService(c Config, l *log.Logger) *Service {
+	return &Service{
+		logger: l,
+	}
+}
+
+func (s *Service) Open() error {
+	s.routes = []httpd.Route{
+		{
+			"metrics",
+			"GET",
+			metricsPath,
+			true,
+			false,
+			s.handleMetrics,
+			httpd.ReadPrivilege,
+			false,
+		},
+	}
+	if err := s.HTTPDService.AddRoutes(s.routes); err != nil {
+		return err
+	}
+	s.logger.Println("I! opened service")
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.HTTPDService.DelRoutes(s.routes)
+	return nil
+}
+
+func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	data, err := kapacitor.GetStatsData()
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := WriteMetrics(w, s.executingStats(data)); err != nil {
+		s.logger.Println("E! failed to write metrics", err)
+	}
+}
+
+// Drop the stats of the tasks that are not executing, e.g. deleted or disabled tasks,
+// whose stats stay published as expvars cannot be removed.
+func (s *Service) executingStats(data []kapacitor.StatsData) []kapacitor.StatsData {
+	executing := data[:0]
+	for _, d := range data {
+		if task, ok := d.Tags["task"]; ok && !s.TaskMaster.IsExecuting(task) {
+			continue
+		}
+		executing = append(executing, d)
+	}
+	return executing
+}
+
+type sample struct {
+	labels string
+	value  string
+}
+
+// Write the stats in the Prometheus text exposition format.
+// Metrics and samples are sorted so the output is stable.
+//
+// The stats of a restarted task are published again with the same tags,
+// only the last sample of each metric and set of labels is written,
+// which is that of the newest stats as the data is ordered by creation.
+func WriteMetrics(w io.Writer, data []kapacitor.StatsData) error {
+	metrics := make(map[string][]sample)
+	// The index of the sample of each metric and set of labels.
+	seen := make(map[string]int)
+	for _, d := range data {
+		labels := formatLabels(d.Tags)
+		for stat, v := range d.Values {
+			value, ok := formatValue(v)
+			if !ok {
+				continue
+			}
+			name := metricName(d.Name, stat)
+			key := name + labels
+			if i, ok := seen[key]; ok {
+				metrics[name][i].value = value
+				continue
+			}
+			seen[key] = len(metrics[name])
+			metrics[name] = append(metrics[name], sample{labels: labels, value: value})
+		}
+	}
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		samples := metrics[name]
+		sort.Slice(samples, func(i, j int) bool { return samples[i].labels < samples[j].labels })
+		fmt.Fprintf(bw, "# TYPE %s untyped\n", name)
+		for _, s := range samples {
+			fmt.Fprintf(bw, "%s%s %s\n", name, s.labels, s.value)
+		}
+	}
+	return bw.Flush()
+}
+
+// The metric name of a stat, the global stats are named kapacitor_<stat>.
+func metricName(name, stat string) string {
+	if name == namespace || name == "" {
+		return sanitize(namespace + "_" + stat)
+	}
+	return sanitize(namespace + "_" + name + "_" + stat)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Format the tags as sorted labels, e.g. {node="window1",task="cpu"}.
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = sanitize(k) + `="` + labelValueEscaper.Replace(tags[k]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case int64:
+		return strconv.FormatInt(value, 10), true
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// Replace the characters not allowed in metric and label names with underscores.
+func sanitize(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
@@ -3,6 +3,7 @@ package replay
 import (
 	"archive/zip"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -28,6 +29,9 @@ import (
 const streamEXT = ".srpl"
 const batchEXT = ".brpl"
 
+// The ownership of a recording is stored next to it.
+const ownerEXT = ".owner"
+
 const precision = "n"
 
 // Handles recording, starting, and waiting on replays
@@ -36,6 +40,7 @@ type Service struct {
 	routes    []httpd.Route
 	TaskStore interface {
 		Load(name string) (*kapacitor.Task, error)
+		Ownership(name string) (owner, team string, err error)
 	}
 	HTTPDService interface {
 		AddRoutes([]httpd.Route) error
@@ -75,6 +80,8 @@ func (r *Service) Open() error {
 			true,
 			true,
 			r.handleList,
+			httpd.ReadPrivilege,
+			false,
 		},
 		{
 			"recording-delete",
@@ -83,6 +90,8 @@ func (r *Service) Open() error {
 			true,
 			true,
 			r.handleDelete,
+			httpd.WritePrivilege,
+			false,
 		},
 		{
 			"record",
@@ -91,6 +100,8 @@ func (r *Service) Open() error {
 			true,
 			true,
 			r.handleRecord,
+			httpd.WritePrivilege,
+			false,
 		},
 		{
 			"record",
@@ -99,6 +110,8 @@ func (r *Service) Open() error {
 			true,
 			true,
 			r.handleGetRecording,
+			httpd.ReadPrivilege,
+			false,
 		},
 		{
 			"replay",
@@ -107,6 +120,8 @@ func (r *Service) Open() error {
 			true,
 			true,
 			r.handleReplay,
+			httpd.WritePrivilege,
+			false,
 		},
 	}
 
@@ -123,18 +138,25 @@ func (r *Service) Close() error {
 	return nil
 }
 
-func (s *Service) handleList(w http.ResponseWriter, req *http.Request) {
+func (s *Service) handleList(w http.ResponseWriter, req *http.Request, u *httpd.User) {
 	ridsStr := req.URL.Query().Get("rids")
 	var rids []string
 	if ridsStr != "" {
 		rids = strings.Split(ridsStr, ",")
 	}
 
-	infos, err := s.GetRecordings(rids)
+	all, err := s.GetRecordings(rids)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
 		return
 	}
+	// Only list the recordings the user can see.
+	infos := make([]RecordingInfo, 0, len(all))
+	for _, info := range all {
+		if u.Role(info.Owner, info.Team) >= httpd.ViewerRole {
+			infos = append(infos, info)
+		}
+	}
 
 	type response struct {
 		Recordings []RecordingInfo `json:"Recordings"`
@@ -143,12 +165,15 @@ func (s *Service) handleList(w http.ResponseWriter, req *http.Request) {
 	w.Write(httpd.MarshalJSON(response{infos}, true))
 }
 
-func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	rid := r.URL.Query().Get("rid")
+	if !s.authorize(w, rid, u, httpd.OwnerRole) {
+		return
+	}
 	s.Delete(rid)
 }
 
-func (r *Service) handleReplay(w http.ResponseWriter, req *http.Request) {
+func (r *Service) handleReplay(w http.ResponseWriter, req *http.Request, u *httpd.User) {
 	name := req.URL.Query().Get("name")
 	id := req.URL.Query().Get("id")
 	clockTyp := req.URL.Query().Get("clock")
@@ -163,6 +188,12 @@ func (r *Service) handleReplay(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	if !r.authorize(w, id, u, httpd.ViewerRole) {
+		return
+	}
+	if _, ok := r.authorizeTask(w, name, u); !ok {
+		return
+	}
 	t, err := r.TaskStore.Load(name)
 	if err != nil {
 		httpd.HttpError(w, "task load: "+err.Error(), true, http.StatusNotFound)
@@ -237,11 +268,26 @@ func (r *Service) handleReplay(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request) {
+func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request, u *httpd.User) {
 	type doFunc func() error
 	var doF doFunc
 	started := make(chan struct{})
 
+	// The recording is owned by the user and shared with the team,
+	// which defaults to the team of the recorded task.
+	var o ownership
+	if u != nil {
+		o.Owner = u.Name
+	}
+	team, hasTeam := req.URL.Query()["team"]
+	if hasTeam {
+		o.Team = team[0]
+		if o.Team != "" && u != nil && !u.Authorize(httpd.AdminPrivilege) && !u.InTeam(o.Team) {
+			httpd.HttpError(w, fmt.Sprintf("user %q is not a member of team %q", u.Name, o.Team), true, http.StatusForbidden)
+			return
+		}
+	}
+
 	rid := uuid.NewV4()
 	typ := req.URL.Query().Get("type")
 	switch typ {
@@ -251,6 +297,13 @@ func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request) {
 			httpd.HttpError(w, "no task specified", true, http.StatusBadRequest)
 			return
 		}
+		taskTeam, ok := r.authorizeTask(w, task, u)
+		if !ok {
+			return
+		}
+		if !hasTeam {
+			o.Team = taskTeam
+		}
 		t, err := r.TaskStore.Load(task)
 		if err != nil {
 			httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
@@ -319,6 +372,13 @@ func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request) {
 			httpd.HttpError(w, "no task specified", true, http.StatusBadRequest)
 			return
 		}
+		taskTeam, ok := r.authorizeTask(w, task, u)
+		if !ok {
+			return
+		}
+		if !hasTeam {
+			o.Team = taskTeam
+		}
 
 		t, err := r.TaskStore.Load(task)
 		if err != nil {
@@ -356,6 +416,12 @@ func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request) {
 		httpd.HttpError(w, "invalid recording type", true, http.StatusBadRequest)
 		return
 	}
+	err := r.saveOwnership(rid.String(), o)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+
 	// Store recording in running recordings.
 	errC := make(chan error, 1)
 	func() {
@@ -388,8 +454,11 @@ func (r *Service) handleRecord(w http.ResponseWriter, req *http.Request) {
 	w.Write(httpd.MarshalJSON(response{rid.String()}, true))
 }
 
-func (r *Service) handleGetRecording(w http.ResponseWriter, req *http.Request) {
+func (r *Service) handleGetRecording(w http.ResponseWriter, req *http.Request, u *httpd.User) {
 	rid := req.URL.Query().Get("id")
+	if !r.authorize(w, rid, u, httpd.ViewerRole) {
+		return
+	}
 
 	// First check if its still running
 	var errC <-chan error
@@ -433,6 +502,8 @@ type RecordingInfo struct {
 	Size    int64              `json:",omitempty"`
 	Created time.Time          `json:",omitempty"`
 	Error   string             `json:",omitempty"`
+	Owner   string             `json:",omitempty"`
+	Team    string             `json:",omitempty"`
 }
 
 func (r *Service) GetRecordings(rids []string) ([]RecordingInfo, error) {
@@ -468,11 +539,17 @@ func (r *Service) GetRecordings(rids []string) ([]RecordingInfo, error) {
 		default:
 			continue
 		}
+		o, err := r.loadOwnership(id)
+		if err != nil {
+			return nil, err
+		}
 		rinfo := RecordingInfo{
 			ID:      id,
 			Type:    typ,
 			Size:    info.Size(),
 			Created: info.ModTime().UTC(),
+			Owner:   o.Owner,
+			Team:    o.Team,
 		}
 		infos = append(infos, rinfo)
 	}
@@ -542,8 +619,73 @@ func (r *Service) FindBatchRecording(id string) ([]io.ReadCloser, error) {
 func (r *Service) Delete(id string) {
 	ps := path.Join(r.saveDir, id+streamEXT)
 	pb := path.Join(r.saveDir, id+batchEXT)
+	po := path.Join(r.saveDir, id+ownerEXT)
 	os.Remove(ps)
 	os.Remove(pb)
+	os.Remove(po)
+}
+
+// The user that made a recording and the team it is shared with.
+type ownership struct {
+	Owner string
+	Team  string
+}
+
+func (r *Service) saveOwnership(id string, o ownership) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(r.saveDir, id+ownerEXT), data, 0644)
+}
+
+// Load the ownership of a recording.
+// Recordings made without authentication have no owner.
+func (r *Service) loadOwnership(id string) (ownership, error) {
+	var o ownership
+	data, err := ioutil.ReadFile(path.Join(r.saveDir, id+ownerEXT))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return o, nil
+		}
+		return o, err
+	}
+	err = json.Unmarshal(data, &o)
+	return o, err
+}
+
+// Check that the user has at least the role for a recording.
+// If not, an error is written to the response.
+// Recordings the user cannot see are reported as not found.
+func (r *Service) authorize(w http.ResponseWriter, id string, u *httpd.User, role httpd.Role) bool {
+	o, err := r.loadOwnership(id)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return false
+	}
+	switch got := u.Role(o.Owner, o.Team); {
+	case got >= role:
+		return true
+	case got == httpd.NoRole:
+		httpd.HttpError(w, "recording not found", true, http.StatusNotFound)
+	default:
+		httpd.HttpError(w, fmt.Sprintf("user %q is not allowed to modify recording %s", u.Name, id), true, http.StatusForbidden)
+	}
+	return false
+}
+
+// Check that the user can see a task, returning the team of the task.
+// If not, an error is written to the response.
+func (r *Service) authorizeTask(w http.ResponseWriter, name string, u *httpd.User) (string, bool) {
+	owner, team, err := r.TaskStore.Ownership(name)
+	if err == nil && u.Role(owner, team) == httpd.NoRole {
+		err = fmt.Errorf("unknown task %s", name)
+	}
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return "", false
+	}
+	return team, true
 }
 
 type rc struct {
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdb/influxdb/toml"
+)
+
+const (
+	DefaultRetentionPolicy  = "default"
+	DefaultScrapeInterval   = toml.Duration(10 * time.Second)
+	DefaultMetricsPath      = "/metrics"
+	DefaultScheme           = "http"
+	DefaultDiscoveryRefresh = toml.Duration(5 * time.Minute)
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// The name of the scraper, added to the points as the job tag.
+	Name string `toml:"name"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	ScrapeInterval toml.Duration `toml:"scrape-interval"`
+	// The timeout of a scrape, defaults to the scrape interval.
+	ScrapeTimeout      toml.Duration `toml:"scrape-timeout"`
+	MetricsPath        string        `toml:"metrics-path"`
+	Scheme             string        `toml:"scheme"`
+	InsecureSkipVerify bool          `toml:"insecure-skip-verify"`
+
+	// The host:port addresses of the targets.
+	StaticTargets []string `toml:"static-targets"`
+	// Glob patterns of JSON files listing targets in the Prometheus file discovery format:
+	//    [{"targets": ["host:port", ...], "labels": {"name": "value", ...}}, ...]
+	DiscoveryFiles []string `toml:"discovery-files"`
+	// How often the discovery files are read again.
+	DiscoveryRefresh toml.Duration `toml:"discovery-refresh-interval"`
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.RetentionPolicy == "" {
+		d.RetentionPolicy = DefaultRetentionPolicy
+	}
+	if d.ScrapeInterval == 0 {
+		d.ScrapeInterval = DefaultScrapeInterval
+	}
+	if d.ScrapeTimeout == 0 {
+		d.ScrapeTimeout = d.ScrapeInterval
+	}
+	if d.MetricsPath == "" {
+		d.MetricsPath = DefaultMetricsPath
+	}
+	if d.Scheme == "" {
+		d.Scheme = DefaultScheme
+	}
+	if d.DiscoveryRefresh == 0 {
+		d.DiscoveryRefresh = DefaultDiscoveryRefresh
+	}
+	return &d
+}
+
+func (c Config) Validate() error {
+	d := c.WithDefaults()
+	if d.Name == "" {
+		return errors.New("must specify a name")
+	}
+	if d.Database == "" {
+		return errors.New("must specify a database")
+	}
+	if len(d.StaticTargets) == 0 && len(d.DiscoveryFiles) == 0 {
+		return errors.New("must specify static targets or discovery files")
+	}
+	if d.Scheme != "http" && d.Scheme != "https" {
+		return fmt.Errorf("invalid scheme %q, must be http or https", d.Scheme)
+	}
+	if d.ScrapeInterval < 0 || d.ScrapeTimeout < 0 || d.DiscoveryRefresh < 0 {
+		return errors.New("intervals and timeouts must be positive")
+	}
+	if d.ScrapeTimeout > d.ScrapeInterval {
+		return errors.New("scrape timeout must not be greater than the scrape interval")
+	}
+	return nil
+}
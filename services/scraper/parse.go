@@ -0,0 +1,136 @@
+package scraper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A sample of the Prometheus text exposition format.
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+	time   time.Time
+}
+
+// Parse the samples of the Prometheus text exposition format.
+// Samples without a timestamp are at the given time, comments and type hints are ignored.
+func parseSamples(r io.Reader, now time.Time) ([]sample, error) {
+	var samples []sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		s, err := parseSample(line, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+func parseSample(line string, now time.Time) (sample, error) {
+	s := sample{time: now}
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return s, errors.New("invalid sample, expected a metric name and a value")
+	}
+	s.name = line[:i]
+	rest := line[i:]
+	if rest[0] == '{' {
+		labels, n, err := parseLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		s.labels = labels
+		rest = rest[n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, errors.New("invalid sample, expected a value and an optional timestamp")
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value %q", fields[0])
+	}
+	s.value = v
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		s.time = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+	return s, nil
+}
+
+// Parse the labels at the start of the string, e.g. {method="post",code="200"},
+// and return them with the length of the labels.
+func parseLabels(str string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(str) && (str[i] == ' ' || str[i] == '\t') {
+			i++
+		}
+		if i < len(str) && str[i] == '}' {
+			return labels, i + 1, nil
+		}
+		eq := strings.IndexByte(str[i:], '=')
+		if eq < 0 {
+			return nil, 0, errors.New("invalid labels, expected name=\"value\"")
+		}
+		name := strings.TrimSpace(str[i : i+eq])
+		if name == "" {
+			return nil, 0, errors.New("invalid labels, empty label name")
+		}
+		i += eq + 1
+		for i < len(str) && (str[i] == ' ' || str[i] == '\t') {
+			i++
+		}
+		if i >= len(str) || str[i] != '"' {
+			return nil, 0, fmt.Errorf("invalid value of label %q, expected a quoted string", name)
+		}
+		i++
+		var value []byte
+		for ; i < len(str) && str[i] != '"'; i++ {
+			c := str[i]
+			if c == '\\' && i+1 < len(str) {
+				i++
+				switch str[i] {
+				case 'n':
+					c = '\n'
+				default:
+					c = str[i]
+				}
+			}
+			value = append(value, c)
+		}
+		if i >= len(str) {
+			return nil, 0, fmt.Errorf("invalid value of label %q, missing closing quote", name)
+		}
+		i++
+		labels[name] = string(value)
+		for i < len(str) && (str[i] == ' ' || str[i] == '\t') {
+			i++
+		}
+		if i < len(str) && str[i] == ',' {
+			i++
+			continue
+		}
+		if i < len(str) && str[i] == '}' {
+			return labels, i + 1, nil
+		}
+		return nil, 0, errors.New("invalid labels, expected , or }")
+	}
+}
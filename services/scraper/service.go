@@ -0,0 +1,320 @@
+// The scraper service periodically scrapes Prometheus endpoints and writes the samples
+// to the Kapacitor stream under the configured database and retention policy.
+//
+// Each sample is a point named after its metric with the sample in the value field.
+// The labels of the sample are the tags of the point, along with the job tag set to the name of
+// the scraper, the instance tag set to the address of the target and the labels of the target.
+// Labels of the sample that conflict with those are renamed exported_<label>.
+// Samples that are NaN or infinite are dropped.
+//
+// Example:
+//
+//    [[scraper]]
+//        enabled = true
+//        name = "node"
+//        database = "prometheus"
+//        static-targets = ["localhost:9100"]
+//
+// The samples can then be used in stream tasks:
+//
+//    stream
+//        .from().database('prometheus').measurement('node_load1')
+//        .alert()
+//            .crit(lambda: "value" > 4.0)
+//
+package scraper
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/models"
+)
+
+// statistics gathered by the scraper service.
+const (
+	statTargets           = "targets"
+	statScrapes           = "scrapes"
+	statScrapeFail        = "scrape_fail"
+	statSamplesDropped    = "samples_dropped"
+	statPointsTransmitted = "points_tx"
+	statTransmitFail      = "tx_fail"
+)
+
+// A target to scrape and the labels added to its samples.
+type target struct {
+	addr   string
+	labels map[string]string
+}
+
+// A group of targets of the Prometheus file discovery format.
+type targetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type Service struct {
+	config Config
+	client *http.Client
+
+	PointsWriter interface {
+		WritePoints(p *cluster.WritePointsRequest) error
+	}
+
+	mu      sync.Mutex
+	targets []target
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	statMap *expvar.Map
+	logger  *log.Logger
+}
+
+func NewService(c Config, l *log.Logger) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config: d,
+		client: &http.Client{
+			Timeout: time.Duration(d.ScrapeTimeout),
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: d.InsecureSkipVerify},
+			},
+		},
+		logger: l,
+	}
+}
+
+func (s *Service) Open() error {
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+	s.statMap = kapacitor.NewStatistics("scraper", map[string]string{"scraper": s.config.Name})
+	for _, stat := range []string{
+		statTargets,
+		statScrapes,
+		statScrapeFail,
+		statSamplesDropped,
+		statPointsTransmitted,
+		statTransmitFail,
+	} {
+		s.statMap.Add(stat, 0)
+	}
+
+	targets, err := s.discover()
+	if err != nil {
+		return err
+	}
+	s.setTargets(targets)
+
+	s.closing = make(chan struct{})
+	s.wg.Add(1)
+	go s.run()
+	s.logger.Printf("I! opened service, scraping %d targets every %s", len(targets), time.Duration(s.config.ScrapeInterval))
+	return nil
+}
+
+func (s *Service) Close() error {
+	if s.closing == nil {
+		return errors.New("service not open")
+	}
+	close(s.closing)
+	s.wg.Wait()
+	s.closing = nil
+	s.logger.Println("I! closed service")
+	return nil
+}
+
+func (s *Service) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.config.ScrapeInterval))
+	defer ticker.Stop()
+	var refresh <-chan time.Time
+	if len(s.config.DiscoveryFiles) > 0 {
+		rt := time.NewTicker(time.Duration(s.config.DiscoveryRefresh))
+		defer rt.Stop()
+		refresh = rt.C
+	}
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-refresh:
+			targets, err := s.discover()
+			if err != nil {
+				s.logger.Println("E! failed to discover targets, keeping the previous targets:", err)
+				continue
+			}
+			s.setTargets(targets)
+		case <-ticker.C:
+			s.scrapeAll()
+		}
+	}
+}
+
+func (s *Service) setTargets(targets []target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = targets
+	s.statMap.Set(statTargets, intVar(int64(len(targets))))
+}
+
+// Read the static targets and the targets of the discovery files.
+func (s *Service) discover() ([]target, error) {
+	var targets []target
+	for _, addr := range s.config.StaticTargets {
+		targets = append(targets, target{addr: addr})
+	}
+	for _, pattern := range s.config.DiscoveryFiles {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var groups []targetGroup
+			if err := json.Unmarshal(data, &groups); err != nil {
+				return nil, fmt.Errorf("invalid discovery file %s: %s", file, err)
+			}
+			for _, g := range groups {
+				for _, addr := range g.Targets {
+					targets = append(targets, target{addr: addr, labels: g.Labels})
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// Scrape all targets concurrently and wait for the scrapes to finish.
+func (s *Service) scrapeAll() {
+	s.mu.Lock()
+	targets := s.targets
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for _, t := range targets {
+		go func(t target) {
+			defer wg.Done()
+			s.statMap.Add(statScrapes, 1)
+			if err := s.scrape(t); err != nil {
+				s.statMap.Add(statScrapeFail, 1)
+				s.logger.Printf("E! failed to scrape %s: %s", t.addr, err)
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (s *Service) scrape(t target) error {
+	u := url.URL{
+		Scheme: s.config.Scheme,
+		Host:   t.addr,
+		Path:   s.config.MetricsPath,
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	now := time.Now().UTC()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	samples, err := parseSamples(resp.Body, now)
+	if err != nil {
+		return err
+	}
+
+	points := make([]models.Point, 0, len(samples))
+	for _, smpl := range samples {
+		if math.IsNaN(smpl.value) || math.IsInf(smpl.value, 0) {
+			s.statMap.Add(statSamplesDropped, 1)
+			continue
+		}
+		p, err := models.NewPoint(
+			smpl.name,
+			s.tags(t, smpl.labels),
+			models.Fields{"value": smpl.value},
+			smpl.time,
+		)
+		if err != nil {
+			s.statMap.Add(statSamplesDropped, 1)
+			continue
+		}
+		points = append(points, p)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := s.PointsWriter.WritePoints(&cluster.WritePointsRequest{
+		Database:         s.config.Database,
+		RetentionPolicy:  s.config.RetentionPolicy,
+		ConsistencyLevel: cluster.ConsistencyLevelOne,
+		Points:           points,
+	}); err != nil {
+		s.statMap.Add(statTransmitFail, 1)
+		return fmt.Errorf("failed to write points to database %q: %s", s.config.Database, err)
+	}
+	s.statMap.Add(statPointsTransmitted, int64(len(points)))
+	return nil
+}
+
+// The tags of a sample of a target, labels with empty values are omitted.
+// The labels of the target, which may override the job and instance, take precedence over the labels of the sample.
+func (s *Service) tags(t target, labels map[string]string) models.Tags {
+	targetLabels := map[string]string{
+		"job":      s.config.Name,
+		"instance": t.addr,
+	}
+	for k, v := range t.labels {
+		targetLabels[k] = v
+	}
+
+	tags := make(models.Tags, len(labels)+len(targetLabels))
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		if _, ok := targetLabels[k]; ok {
+			k = "exported_" + k
+		}
+		tags[k] = v
+	}
+	for k, v := range targetLabels {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+func intVar(i int64) *expvar.Int {
+	v := &expvar.Int{}
+	v.Set(i)
+	return v
+}
@@ -0,0 +1,183 @@
+package scraper
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdb/influxdb/cluster"
+	"github.com/influxdb/influxdb/models"
+	"github.com/influxdb/influxdb/toml"
+)
+
+func TestParseSamples(t *testing.T) {
+	now := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{ method = "post" , code="400", } 3
+
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+go_goroutines 12
+rpc_duration_seconds{quantile="0.5"} NaN
+`
+	samples, err := parseSamples(strings.NewReader(metrics), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []sample{
+		{
+			name:   "http_requests_total",
+			labels: map[string]string{"method": "post", "code": "200"},
+			value:  1027,
+			time:   time.Date(2014, 3, 17, 14, 26, 3, 0, time.UTC),
+		},
+		{
+			name:   "http_requests_total",
+			labels: map[string]string{"method": "post", "code": "400"},
+			value:  3,
+			time:   now,
+		},
+		{
+			name:   "msdos_file_access_time_seconds",
+			labels: map[string]string{"path": `C:\DIR\FILE.TXT`, "error": "Cannot find file:\n\"FILE.TXT\""},
+			value:  1.458255915e9,
+			time:   now,
+		},
+		{
+			name:  "go_goroutines",
+			value: 12,
+			time:  now,
+		},
+	}
+	if len(samples) != len(exp)+1 {
+		t.Fatalf("unexpected number of samples got %d exp %d", len(samples), len(exp)+1)
+	}
+	// NaN is not equal to itself.
+	if nan := samples[len(exp)]; nan.name != "rpc_duration_seconds" || nan.value == nan.value {
+		t.Errorf("unexpected NaN sample %v", nan)
+	}
+	if !reflect.DeepEqual(exp, samples[:len(exp)]) {
+		t.Errorf("unexpected samples:\ngot %v\nexp %v", samples[:len(exp)], exp)
+	}
+
+	for _, invalid := range []string{
+		"go_goroutines",
+		"go_goroutines twelve",
+		"go_goroutines 12 yesterday",
+		`http_requests_total{method=post} 1`,
+		`http_requests_total{method="post" 1`,
+		`http_requests_total{method="post} 1`,
+	} {
+		if _, err := parseSamples(strings.NewReader(invalid), now); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
+
+type pointsWriter struct {
+	points chan []models.Point
+}
+
+func (w *pointsWriter) WritePoints(p *cluster.WritePointsRequest) error {
+	if p.Database != "prometheus" || p.RetentionPolicy != DefaultRetentionPolicy {
+		panic("unexpected database or retention policy " + p.Database + "." + p.RetentionPolicy)
+	}
+	w.points <- p.Points
+	return nil
+}
+
+func TestService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`up{job="exporter"} 1 1000
+temperature{room="kitchen",sensor=""} NaN
+`))
+	}))
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	dir, err := ioutil.TempDir("", "scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	targets := `[{"targets": ["` + addr + `"], "labels": {"dc": "east", "instance": "exporter"}}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, "targets.json"), []byte(targets), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		Enabled:        true,
+		Name:           "test",
+		Database:       "prometheus",
+		ScrapeInterval: toml.Duration(10 * time.Millisecond),
+		StaticTargets:  []string{addr},
+		DiscoveryFiles: []string{filepath.Join(dir, "*.json")},
+	}
+	s := NewService(c, log.New(ioutil.Discard, "", 0))
+	w := &pointsWriter{points: make(chan []models.Point, 10)}
+	s.PointsWriter = w
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// The static target and the discovered target are scraped,
+	// the instance label of the discovered target replaces its address.
+	exp := map[string]bool{
+		`up,exported_job=exporter,instance=` + addr + `,job=test value=1 1000000000`:     true,
+		`up,dc=east,exported_job=exporter,instance=exporter,job=test value=1 1000000000`: true,
+	}
+	got := make(map[string]bool)
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(exp) {
+		select {
+		case points := <-w.points:
+			if len(points) != 1 {
+				t.Fatalf("unexpected number of points got %d exp 1", len(points))
+			}
+			got[points[0].String()] = true
+		case <-timeout:
+			t.Fatalf("timed out waiting for points, got %v", got)
+		}
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected points:\ngot %v\nexp %v", got, exp)
+	}
+	if dropped := s.statMap.Get(statSamplesDropped).String(); dropped == "0" {
+		t.Error("expected the NaN samples to be dropped")
+	}
+	if targets := s.statMap.Get(statTargets).String(); targets != "2" {
+		t.Errorf("unexpected targets got %s exp 2", targets)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Name: "test", Database: "prometheus", StaticTargets: []string{"localhost:9100"}}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []Config{
+		{Database: "prometheus", StaticTargets: []string{"localhost:9100"}},
+		{Name: "test", StaticTargets: []string{"localhost:9100"}},
+		{Name: "test", Database: "prometheus"},
+		{Name: "test", Database: "prometheus", StaticTargets: []string{"localhost:9100"}, Scheme: "ftp"},
+		{Name: "test", Database: "prometheus", StaticTargets: []string{"localhost:9100"}, ScrapeTimeout: toml.Duration(time.Minute)},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error validating %+v", c)
+		}
+	}
+}
@@ -11,21 +11,24 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/tick"
 	"github.com/influxdb/influxdb/influxql"
 )
 
 const taskDB = "task.db"
 
 var (
-	tasksBucket    = []byte("tasks")
-	enabledBucket  = []byte("enabled")
-	snapshotBucket = []byte("snapshots")
+	tasksBucket     = []byte("tasks")
+	enabledBucket   = []byte("enabled")
+	snapshotBucket  = []byte("snapshots")
+	templatesBucket = []byte("templates")
 )
 
 type Service struct {
@@ -44,9 +47,12 @@ type Service struct {
 			tt kapacitor.TaskType,
 			dbrps []kapacitor.DBRP,
 			snapshotInterval time.Duration,
+			vars map[string]tick.Var,
 		) (*kapacitor.Task, error)
+		LintTask(script string, tt kapacitor.TaskType, vars map[string]tick.Var) ([]tick.LintError, error)
 		StartTask(t *kapacitor.Task) (*kapacitor.ExecutingTask, error)
 		StopTask(name string) error
+		StopExecutingTask(et *kapacitor.ExecutingTask) error
 		IsExecuting(name string) bool
 		ExecutingDot(name string) string
 	}
@@ -90,6 +96,8 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleTask,
+			httpd.ReadPrivilege,
+			false,
 		},
 		{
 			"task-list",
@@ -98,6 +106,8 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleTasks,
+			httpd.ReadPrivilege,
+			false,
 		},
 		{
 			"task-save",
@@ -106,6 +116,18 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleSave,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"task-validate",
+			"POST",
+			"/task/validate",
+			true,
+			true,
+			ts.handleValidate,
+			httpd.ReadPrivilege,
+			false,
 		},
 		{
 			"task-delete",
@@ -114,6 +136,8 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleDelete,
+			httpd.WritePrivilege,
+			false,
 		},
 		{
 			"task-enable",
@@ -122,6 +146,8 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleEnable,
+			httpd.WritePrivilege,
+			false,
 		},
 		{
 			"task-disable",
@@ -130,8 +156,51 @@ func (ts *Service) Open() error {
 			true,
 			true,
 			ts.handleDisable,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"template-show",
+			"GET",
+			"/template",
+			true,
+			true,
+			ts.handleTemplate,
+			httpd.ReadPrivilege,
+			false,
+		},
+		{
+			"template-list",
+			"GET",
+			"/templates",
+			true,
+			true,
+			ts.handleTemplates,
+			httpd.ReadPrivilege,
+			false,
+		},
+		{
+			"template-save",
+			"POST",
+			"/template",
+			true,
+			true,
+			ts.handleSaveTemplate,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"template-delete",
+			"DELETE",
+			"/template",
+			true,
+			true,
+			ts.handleDeleteTemplate,
+			httpd.WritePrivilege,
+			false,
 		},
 	}
+	ts.routes = append(ts.routes, ts.apiRoutes()...)
 	err = ts.HTTPDService.AddRoutes(ts.routes)
 	if err != nil {
 		return err
@@ -273,29 +342,39 @@ type TaskInfo struct {
 	Type       kapacitor.TaskType
 	DBRPs      []kapacitor.DBRP
 	TICKscript string
+	Template   string
+	Vars       map[string]Var
 	Dot        string
 	Enabled    bool
 	Executing  bool
 	Error      string
+	Owner      string
+	Team       string
 }
 
-func (ts *Service) handleTask(w http.ResponseWriter, r *http.Request) {
+func (ts *Service) handleTask(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	name := r.URL.Query().Get("name")
 	if name == "" {
 		httpd.HttpError(w, "must pass task name", true, http.StatusBadRequest)
 		return
 	}
 
-	raw, err := ts.LoadRaw(name)
-	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+	raw, ok := ts.authorizeTask(w, name, u, httpd.ViewerRole)
+	if !ok {
 		return
 	}
 
+	w.Write(httpd.MarshalJSON(ts.taskInfo(raw), true))
+}
+
+// Describe a task, including its current DOT and the TICKscript of its template.
+func (ts *Service) taskInfo(raw *rawTask) TaskInfo {
+	name := raw.Name
 	executing := ts.TaskMaster.IsExecuting(name)
 	errMsg := raw.Error
 	dot := ""
-	task, err := ts.Load(name)
+	script := raw.TICKscript
+	task, err := ts.newTask(raw)
 	if err == nil {
 		if executing {
 			dot = ts.TaskMaster.ExecutingDot(name)
@@ -305,33 +384,47 @@ func (ts *Service) handleTask(w http.ResponseWriter, r *http.Request) {
 	} else {
 		errMsg = err.Error()
 	}
+	if raw.Template != "" {
+		if tmpl, err := ts.LoadTemplate(raw.Template); err == nil {
+			script = tmpl.TICKscript
+		}
+	}
 
-	info := TaskInfo{
+	return TaskInfo{
 		Name:       name,
 		Type:       raw.Type,
 		DBRPs:      raw.DBRPs,
-		TICKscript: raw.TICKscript,
+		TICKscript: script,
+		Template:   raw.Template,
+		Vars:       raw.Vars,
 		Dot:        dot,
 		Enabled:    ts.IsEnabled(name),
 		Executing:  executing,
 		Error:      errMsg,
+		Owner:      raw.Owner,
+		Team:       raw.Team,
 	}
-
-	w.Write(httpd.MarshalJSON(info, true))
 }
 
-func (ts *Service) handleTasks(w http.ResponseWriter, r *http.Request) {
+func (ts *Service) handleTasks(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	tasksStr := r.URL.Query().Get("tasks")
 	var tasks []string
 	if tasksStr != "" {
 		tasks = strings.Split(tasksStr, ",")
 	}
 
-	infos, err := ts.GetTaskInfo(tasks)
+	all, err := ts.GetTaskInfo(tasks)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
 		return
 	}
+	// Only list the tasks the user can see.
+	infos := make([]taskInfo, 0, len(all))
+	for _, info := range all {
+		if u.Role(info.Owner, info.Team) >= httpd.ViewerRole {
+			infos = append(infos, info)
+		}
+	}
 
 	type response struct {
 		Tasks []taskInfo `json:"Tasks"`
@@ -352,31 +445,82 @@ type rawTask struct {
 	// The DBs and RPs the task is allowed to access.
 	DBRPs            []kapacitor.DBRP
 	SnapshotInterval time.Duration
+	// The name of the template the task was defined from, if any.
+	// Tasks defined from a template use the template's TICKscript.
+	Template string
+	// The vars overriding the defaults declared in the template.
+	Vars map[string]Var
+	// The user that defined the task, empty if it was defined without authentication.
+	Owner string
+	// The team the task is shared with, if any.
+	Team string
+}
+
+// A typed value for a var declared in a template.
+// Durations and regexes are represented as strings.
+type Var struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
 }
 
-func (ts *Service) handleSave(w http.ResponseWriter, r *http.Request) {
+func (ts *Service) handleSave(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	name := r.URL.Query().Get("name")
 	newTask := &rawTask{
 		Name:             name,
 		SnapshotInterval: ts.snapshotInterval,
 	}
+	if u != nil {
+		newTask.Owner = u.Name
+	}
 
 	// Check for existing task
 	raw, err := ts.LoadRaw(name)
 	exists := err == nil
 	if exists {
+		if !authorize(w, name, u, raw.Owner, raw.Team, httpd.MemberRole) {
+			return
+		}
 		newTask = raw
 	}
 
+	// Get team, only the owner may share the task with a team.
+	if team, ok := r.URL.Query()["team"]; ok && team[0] != newTask.Team {
+		if exists && !authorize(w, name, u, raw.Owner, raw.Team, httpd.OwnerRole) {
+			return
+		}
+		if team[0] != "" && u != nil && !u.Authorize(httpd.AdminPrivilege) && !u.InTeam(team[0]) {
+			httpd.HttpError(w, fmt.Sprintf("user %q is not a member of team %q", u.Name, team[0]), true, http.StatusForbidden)
+			return
+		}
+		newTask.Team = team[0]
+	}
+
+	// Get template, the task type is inherited from the template.
+	template := r.URL.Query().Get("template")
+	if template != "" {
+		tmpl, code, err := ts.authorizeTemplate(template, u, httpd.ViewerRole)
+		if err != nil {
+			if code == http.StatusNotFound {
+				code = http.StatusBadRequest
+			}
+			httpd.HttpError(w, err.Error(), true, code)
+			return
+		}
+		newTask.Template = template
+		newTask.TICKscript = ""
+		newTask.Type = tmpl.Type
+	}
+
 	// Get task type
 	ttStr := r.URL.Query().Get("type")
+	var tt kapacitor.TaskType
 	switch ttStr {
 	case "stream":
-		newTask.Type = kapacitor.StreamTask
+		tt = kapacitor.StreamTask
 	case "batch":
-		newTask.Type = kapacitor.BatchTask
+		tt = kapacitor.BatchTask
 	default:
-		if !exists {
+		if !exists && template == "" {
 			if ttStr == "" {
 				httpd.HttpError(w, fmt.Sprintf("no task with name %q exists cannot infer type.", name), true, http.StatusBadRequest)
 			} else {
@@ -384,16 +528,34 @@ func (ts *Service) handleSave(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		tt = newTask.Type
+	}
+	if template != "" && tt != newTask.Type {
+		httpd.HttpError(w, fmt.Sprintf("task type %s does not match type %s of template %s", tt, newTask.Type, template), true, http.StatusBadRequest)
+		return
 	}
+	newTask.Type = tt
 
-	// Get tick script
-	tick, err := ioutil.ReadAll(r.Body)
+	// Get tick script, or the vars if the task is defined from a template.
+	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
-	if len(tick) > 0 {
-		newTask.TICKscript = string(tick)
+	if template != "" {
+		vars := make(map[string]Var)
+		if len(data) > 0 {
+			err = json.Unmarshal(data, &vars)
+			if err != nil {
+				httpd.HttpError(w, fmt.Sprintf("invalid vars: %s", err), true, http.StatusBadRequest)
+				return
+			}
+		}
+		newTask.Vars = vars
+	} else if len(data) > 0 {
+		newTask.TICKscript = string(data)
+		newTask.Template = ""
+		newTask.Vars = nil
 	} else if !exists {
 		httpd.HttpError(w, fmt.Sprintf("must provide TICKscript via POST data."), true, http.StatusBadRequest)
 		return
@@ -432,8 +594,107 @@ func (ts *Service) handleSave(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ts *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+// Convert vars into their typed TICKscript values.
+func tickVars(vars map[string]Var) (map[string]tick.Var, error) {
+	tvars := make(map[string]tick.Var, len(vars))
+	for name, v := range vars {
+		tv, err := tick.NewVar(v.Type, v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid var %q: %s", name, err)
+		}
+		tvars[name] = tv
+	}
+	return tvars, nil
+}
+
+// Lint a TICKscript without saving it.
+// The script is either the POST data, the template given by the template parameter
+// with the POST data as its vars, or the script of the existing task given by the name parameter.
+func (ts *Service) handleValidate(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	var script string
+	var tt kapacitor.TaskType
+	vars := make(map[string]Var)
 	name := r.URL.Query().Get("name")
+	template := r.URL.Query().Get("template")
+	switch {
+	case template != "":
+		tmpl, code, err := ts.authorizeTemplate(template, u, httpd.ViewerRole)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, code)
+			return
+		}
+		if len(data) > 0 {
+			err = json.Unmarshal(data, &vars)
+			if err != nil {
+				httpd.HttpError(w, fmt.Sprintf("invalid vars: %s", err), true, http.StatusBadRequest)
+				return
+			}
+		}
+		script = tmpl.TICKscript
+		tt = tmpl.Type
+	case len(data) > 0:
+		switch ttStr := r.URL.Query().Get("type"); ttStr {
+		case "stream":
+			tt = kapacitor.StreamTask
+		case "batch":
+			tt = kapacitor.BatchTask
+		default:
+			httpd.HttpError(w, fmt.Sprintf("unknown type %q", ttStr), true, http.StatusBadRequest)
+			return
+		}
+		script = string(data)
+	case name != "":
+		raw, ok := ts.authorizeTask(w, name, u, httpd.ViewerRole)
+		if !ok {
+			return
+		}
+		script = raw.TICKscript
+		tt = raw.Type
+		if raw.Template != "" {
+			tmpl, err := ts.LoadTemplate(raw.Template)
+			if err != nil {
+				httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+				return
+			}
+			script = tmpl.TICKscript
+			vars = raw.Vars
+		}
+	default:
+		httpd.HttpError(w, "must provide TICKscript via POST data or a task name.", true, http.StatusBadRequest)
+		return
+	}
+
+	tvars, err := tickVars(vars)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	errs, err := ts.TaskMaster.LintTask(script, tt, tvars)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+
+	type response struct {
+		Errors []tick.LintError `json:"Errors"`
+	}
+	if errs == nil {
+		errs = make([]tick.LintError, 0)
+	}
+	w.Write(httpd.MarshalJSON(response{errs}, true))
+}
+
+func (ts *Service) handleDelete(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if _, ok := ts.authorizeTask(w, name, u, httpd.OwnerRole); !ok {
+		return
+	}
 
 	err := ts.Delete(name)
 	if err != nil {
@@ -442,8 +703,11 @@ func (ts *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ts *Service) handleEnable(w http.ResponseWriter, r *http.Request) {
+func (ts *Service) handleEnable(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	name := r.URL.Query().Get("name")
+	if _, ok := ts.authorizeTask(w, name, u, httpd.MemberRole); !ok {
+		return
+	}
 	err := ts.Enable(name)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
@@ -451,8 +715,11 @@ func (ts *Service) handleEnable(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ts *Service) handleDisable(w http.ResponseWriter, r *http.Request) {
+func (ts *Service) handleDisable(w http.ResponseWriter, r *http.Request, u *httpd.User) {
 	name := r.URL.Query().Get("name")
+	if _, ok := ts.authorizeTask(w, name, u, httpd.MemberRole); !ok {
+		return
+	}
 	err := ts.Disable(name)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
@@ -460,18 +727,67 @@ func (ts *Service) handleDisable(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Load a task and check that the user has at least the role for it.
+// If not, an error is written to the response.
+func (ts *Service) authorizeTask(w http.ResponseWriter, name string, u *httpd.User, role httpd.Role) (*rawTask, bool) {
+	raw, err := ts.LoadRaw(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return nil, false
+	}
+	return raw, authorize(w, name, u, raw.Owner, raw.Team, role)
+}
+
+// Check that the user has at least the role for a resource with the owner and team.
+// Resources the user cannot see are reported as not found.
+func authorize(w http.ResponseWriter, name string, u *httpd.User, owner, team string, role httpd.Role) bool {
+	if code, err := checkRole(name, u, owner, team, role); err != nil {
+		httpd.HttpError(w, err.Error(), true, code)
+		return false
+	}
+	return true
+}
+
+// Check that the user has at least the role for a task with the owner and team,
+// returning the status code and error if not.
+func checkRole(name string, u *httpd.User, owner, team string, role httpd.Role) (int, error) {
+	switch got := u.Role(owner, team); {
+	case got >= role:
+		return http.StatusOK, nil
+	case got == httpd.NoRole:
+		return http.StatusNotFound, fmt.Errorf("unknown task %s", name)
+	default:
+		return http.StatusForbidden, fmt.Errorf("user %q is not allowed to modify task %s", u.Name, name)
+	}
+}
+
+// Return the owner and team of a task.
+func (ts *Service) Ownership(name string) (owner, team string, err error) {
+	raw, err := ts.LoadRaw(name)
+	if err != nil {
+		return "", "", err
+	}
+	return raw.Owner, raw.Team, nil
+}
+
+// An error in the definition of a task, as opposed to an error of the storage.
+type invalidTaskError struct {
+	err error
+}
+
+func (e invalidTaskError) Error() string {
+	return "invalid task: " + e.err.Error()
+}
+
 func (ts *Service) Save(task *rawTask) error {
 
 	// Validate task
-	_, err := ts.TaskMaster.NewTask(task.Name,
-		task.TICKscript,
-		task.Type,
-		task.DBRPs,
-		task.SnapshotInterval,
-	)
+	t, err := ts.newTask(task)
 	if err != nil {
-		return fmt.Errorf("invalid task: %s", err)
+		return invalidTaskError{err}
 	}
+	// Tasks defined from a template always have the type of the template.
+	task.Type = t.Type
 
 	// Write 0 snapshot interval if it is the default.
 	// This way if the default changes the task will change too.
@@ -559,11 +875,39 @@ func (ts *Service) Load(name string) (*kapacitor.Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ts.newTask(task)
+}
+
+// Create a task from its raw definition.
+// Tasks defined from a template use the TICKscript of the template.
+func (ts *Service) newTask(task *rawTask) (*kapacitor.Task, error) {
+	if task.Template == "" {
+		return ts.TaskMaster.NewTask(task.Name,
+			task.TICKscript,
+			task.Type,
+			task.DBRPs,
+			task.SnapshotInterval,
+			nil,
+		)
+	}
+	tmpl, err := ts.LoadTemplate(task.Template)
+	if err != nil {
+		return nil, err
+	}
+	return ts.newTaskFromTemplate(task, tmpl)
+}
+
+func (ts *Service) newTaskFromTemplate(task *rawTask, tmpl *rawTemplate) (*kapacitor.Task, error) {
+	vars, err := tickVars(task.Vars)
+	if err != nil {
+		return nil, err
+	}
 	return ts.TaskMaster.NewTask(task.Name,
-		task.TICKscript,
-		task.Type,
+		tmpl.TICKscript,
+		tmpl.Type,
 		task.DBRPs,
 		task.SnapshotInterval,
+		vars,
 	)
 }
 
@@ -624,8 +968,8 @@ func (ts *Service) StartTask(t *kapacitor.Task) error {
 	go func() {
 		// Wait for task to finish
 		err := et.Err()
-		// Stop task
-		ts.TaskMaster.StopTask(et.Task.Name)
+		// Stop task, unless it has already been restarted
+		ts.TaskMaster.StopExecutingTask(et)
 
 		if err != nil {
 			ts.logger.Printf("E! task %s finished with error: %s", et.Task.Name, err)
@@ -683,6 +1027,8 @@ type taskInfo struct {
 	DBRPs     []kapacitor.DBRP
 	Enabled   bool
 	Executing bool
+	Owner     string
+	Team      string
 }
 
 func (ts *Service) IsEnabled(name string) (e bool) {
@@ -718,6 +1064,8 @@ func (ts *Service) GetTaskInfo(tasks []string) ([]taskInfo, error) {
 				DBRPs:     t.DBRPs,
 				Enabled:   enabled,
 				Executing: ts.TaskMaster.IsExecuting(t.Name),
+				Owner:     t.Owner,
+				Team:      t.Team,
 			}
 			taskInfos = append(taskInfos, info)
 			return nil
@@ -741,3 +1089,418 @@ func (ts *Service) GetTaskInfo(tasks []string) ([]taskInfo, error) {
 
 	return taskInfos, nil
 }
+
+type rawTemplate struct {
+	// The name of the template.
+	Name string
+	// The TICKscript for the template.
+	TICKscript string
+	// The task type (stream|batch) of the template.
+	Type kapacitor.TaskType
+	// The user that defined the template, empty if it was defined without authentication.
+	Owner string
+	// The team the template is shared with, if any.
+	// Only the owner may change the template, the members of the team may define tasks from it.
+	Team string
+}
+
+type TemplateInfo struct {
+	Name       string
+	Type       kapacitor.TaskType
+	TICKscript string
+	// The vars declared in the template with their default values.
+	Vars map[string]Var
+	// The tasks defined from this template that the user can see.
+	Tasks []string
+	Owner string
+	Team  string
+}
+
+type templateInfo struct {
+	Name string
+	Type kapacitor.TaskType
+}
+
+func (ts *Service) handleTemplate(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		httpd.HttpError(w, "must pass template name", true, http.StatusBadRequest)
+		return
+	}
+
+	tmpl, code, err := ts.authorizeTemplate(name, u, httpd.ViewerRole)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, code)
+		return
+	}
+
+	declared, err := tick.DeclaredVars(tmpl.TICKscript)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	vars := make(map[string]Var, len(declared))
+	for name, v := range declared {
+		vars[name] = toVar(v)
+	}
+
+	tasks, err := ts.templateTasks(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+	info := TemplateInfo{
+		Name:       tmpl.Name,
+		Type:       tmpl.Type,
+		TICKscript: tmpl.TICKscript,
+		Vars:       vars,
+		Tasks:      make([]string, 0, len(tasks)),
+		Owner:      tmpl.Owner,
+		Team:       tmpl.Team,
+	}
+	for _, t := range tasks {
+		if u.Role(t.Owner, t.Team) >= httpd.ViewerRole {
+			info.Tasks = append(info.Tasks, t.Name)
+		}
+	}
+
+	w.Write(httpd.MarshalJSON(info, true))
+}
+
+// Convert a tick.Var into its serializable form.
+func toVar(v tick.Var) Var {
+	value := v.Value
+	switch v.Type {
+	case tick.TDuration:
+		value = influxql.FormatDuration(v.Value.(time.Duration))
+	case tick.TRegex:
+		value = v.Value.(*regexp.Regexp).String()
+	}
+	return Var{
+		Type:  v.Type.String(),
+		Value: value,
+	}
+}
+
+func (ts *Service) handleTemplates(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	templatesStr := r.URL.Query().Get("templates")
+	var templates []string
+	if templatesStr != "" {
+		templates = strings.Split(templatesStr, ",")
+	}
+
+	infos := make([]templateInfo, 0)
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(templatesBucket)
+		if b == nil {
+			return nil
+		}
+		f := func(k, v []byte) error {
+			tmpl, err := ts.LoadTemplate(string(k))
+			if err != nil {
+				return err
+			}
+			// Only list the templates the user can see.
+			if u.Role(tmpl.Owner, tmpl.Team) == httpd.NoRole {
+				return nil
+			}
+			infos = append(infos, templateInfo{
+				Name: tmpl.Name,
+				Type: tmpl.Type,
+			})
+			return nil
+		}
+		if len(templates) == 0 {
+			return b.ForEach(f)
+		}
+		for _, name := range templates {
+			err := f([]byte(name), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusNotFound)
+		return
+	}
+
+	type response struct {
+		Templates []templateInfo `json:"Templates"`
+	}
+
+	w.Write(httpd.MarshalJSON(response{infos}, true))
+}
+
+func (ts *Service) handleSaveTemplate(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		httpd.HttpError(w, "must pass template name", true, http.StatusBadRequest)
+		return
+	}
+	newTemplate := &rawTemplate{
+		Name: name,
+	}
+	if u != nil {
+		newTemplate.Owner = u.Name
+	}
+
+	// Check for existing template, only its owner may change it.
+	tmpl, err := ts.LoadTemplate(name)
+	exists := err == nil
+	if exists {
+		if code, err := checkTemplateRole(u, tmpl, httpd.OwnerRole); err != nil {
+			httpd.HttpError(w, err.Error(), true, code)
+			return
+		}
+		newTemplate = tmpl
+	}
+
+	// Get team
+	if team, ok := r.URL.Query()["team"]; ok {
+		if team[0] != "" && u != nil && !u.Authorize(httpd.AdminPrivilege) && !u.InTeam(team[0]) {
+			httpd.HttpError(w, fmt.Sprintf("user %q is not a member of team %q", u.Name, team[0]), true, http.StatusForbidden)
+			return
+		}
+		newTemplate.Team = team[0]
+	}
+
+	// Get template type
+	ttStr := r.URL.Query().Get("type")
+	switch ttStr {
+	case "stream":
+		newTemplate.Type = kapacitor.StreamTask
+	case "batch":
+		newTemplate.Type = kapacitor.BatchTask
+	default:
+		if !exists {
+			if ttStr == "" {
+				httpd.HttpError(w, fmt.Sprintf("no template with name %q exists cannot infer type.", name), true, http.StatusBadRequest)
+			} else {
+				httpd.HttpError(w, fmt.Sprintf("unknown type %q", ttStr), true, http.StatusBadRequest)
+			}
+			return
+		}
+	}
+
+	// Get tick script
+	tick, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		return
+	}
+	if len(tick) > 0 {
+		newTemplate.TICKscript = string(tick)
+	} else if !exists {
+		httpd.HttpError(w, fmt.Sprintf("must provide TICKscript via POST data."), true, http.StatusBadRequest)
+		return
+	}
+
+	// Saving the template redefines and reloads its tasks,
+	// so the user must be allowed to modify all of them.
+	if exists {
+		tasks, err := ts.templateTasks(name)
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+			return
+		}
+		for _, task := range tasks {
+			if u.Role(task.Owner, task.Team) < httpd.MemberRole {
+				httpd.HttpError(w, fmt.Sprintf("user %q is not allowed to modify all tasks defined from template %s", u.Name, name), true, http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	err = ts.SaveTemplate(newTemplate)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ts *Service) handleDeleteTemplate(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	name := r.URL.Query().Get("name")
+	if _, code, err := ts.authorizeTemplate(name, u, httpd.OwnerRole); err != nil {
+		httpd.HttpError(w, err.Error(), true, code)
+		return
+	}
+
+	err := ts.DeleteTemplate(name)
+	if err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		return
+	}
+}
+
+// Save a template.
+// All tasks defined from the template are validated against the new template
+// before it is saved. Enabled tasks are then reloaded so they run the new TICKscript.
+func (ts *Service) SaveTemplate(tmpl *rawTemplate) error {
+	// Validate template with its default vars
+	_, err := ts.TaskMaster.NewTask(tmpl.Name,
+		tmpl.TICKscript,
+		tmpl.Type,
+		nil,
+		ts.snapshotInterval,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("invalid template: %s", err)
+	}
+
+	// Validate all tasks defined from the template
+	tasks, err := ts.templateTasks(tmpl.Name)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		_, err := ts.newTaskFromTemplate(task, tmpl)
+		if err != nil {
+			return fmt.Errorf("invalid template for task %s: %s", task.Name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	err = enc.Encode(tmpl)
+	if err != nil {
+		return err
+	}
+
+	err = ts.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(templatesBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(tmpl.Name), buf.Bytes())
+	})
+	if err != nil {
+		return err
+	}
+
+	// Redefine and reload the tasks
+	for _, task := range tasks {
+		err := ts.Save(task)
+		if err != nil {
+			return err
+		}
+		if !ts.IsEnabled(task.Name) {
+			continue
+		}
+		err = ts.TaskMaster.StopTask(task.Name)
+		if err != nil {
+			return err
+		}
+		t, err := ts.Load(task.Name)
+		if err != nil {
+			return err
+		}
+		err = ts.StartTask(t)
+		if err != nil {
+			return fmt.Errorf("failed to reload task %s: %s", task.Name, err)
+		}
+	}
+	return nil
+}
+
+// Delete a template.
+// A template cannot be deleted while tasks are defined from it.
+func (ts *Service) DeleteTemplate(name string) error {
+	tasks, err := ts.templateTasks(name)
+	if err != nil {
+		return err
+	}
+	if len(tasks) > 0 {
+		names := make([]string, len(tasks))
+		for i, t := range tasks {
+			names[i] = t.Name
+		}
+		return fmt.Errorf("cannot delete template %s, it is used by tasks: %s", name, strings.Join(names, ","))
+	}
+	return ts.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(templatesBucket)
+		if b != nil {
+			return b.Delete([]byte(name))
+		}
+		return nil
+	})
+}
+
+// Load a template and check that the user has at least the role for it,
+// returning the status code and error if not.
+func (ts *Service) authorizeTemplate(name string, u *httpd.User, role httpd.Role) (*rawTemplate, int, error) {
+	tmpl, err := ts.LoadTemplate(name)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if code, err := checkTemplateRole(u, tmpl, role); err != nil {
+		return nil, code, err
+	}
+	return tmpl, http.StatusOK, nil
+}
+
+// Check that the user has at least the role for a template,
+// returning the status code and error if not.
+func checkTemplateRole(u *httpd.User, tmpl *rawTemplate, role httpd.Role) (int, error) {
+	switch got := u.Role(tmpl.Owner, tmpl.Team); {
+	case got >= role:
+		return http.StatusOK, nil
+	case got == httpd.NoRole:
+		return http.StatusNotFound, fmt.Errorf("unknown template %s", tmpl.Name)
+	default:
+		return http.StatusForbidden, fmt.Errorf("user %q is not allowed to modify template %s", u.Name, tmpl.Name)
+	}
+}
+
+func (ts *Service) LoadTemplate(name string) (*rawTemplate, error) {
+	var data []byte
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(templatesBucket)
+		if b == nil {
+			return errors.New("no templates bucket")
+		}
+		data = b.Get([]byte(name))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("unknown template %s", name)
+	}
+	buf := bytes.NewBuffer(data)
+	dec := gob.NewDecoder(buf)
+	tmpl := &rawTemplate{}
+	err = dec.Decode(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Return all tasks defined from the named template.
+func (ts *Service) templateTasks(name string) ([]*rawTask, error) {
+	tasks := make([]*rawTask, 0)
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tasksBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			t, err := ts.LoadRaw(string(k))
+			if err != nil {
+				return fmt.Errorf("found invalid task in db. name: %s, err: %s", string(k), err)
+			}
+			if t.Template == name {
+				tasks = append(tasks, t)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
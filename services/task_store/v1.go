@@ -0,0 +1,533 @@
+package task_store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+// The versioned task API.
+//
+// Tasks are JSON objects identified by their name:
+//
+//    GET    /v1/tasks        list tasks, paginated with offset and limit and with the fields selected by fields
+//    POST   /v1/tasks        create a task, the ID is part of the task
+//    GET    /v1/tasks/:id    show a task
+//    POST   /v1/tasks/:id    create a task with the ID
+//    PATCH  /v1/tasks/:id    update the fields of a task present in the body
+//    DELETE /v1/tasks/:id    delete a task
+//
+// Errors are the JSON object {"error": "..."}.
+const (
+	tasksPath      = httpd.APIPrefix + "tasks"
+	tasksPathSlash = tasksPath + "/"
+
+	defaultListLimit = 100
+)
+
+// The status of a task.
+const (
+	statusEnabled  = "enabled"
+	statusDisabled = "disabled"
+)
+
+// A task of the versioned API.
+type apiTask struct {
+	ID         string           `json:"id"`
+	TemplateID string           `json:"template-id,omitempty"`
+	Type       string           `json:"type"`
+	DBRPs      []kapacitor.DBRP `json:"dbrps"`
+	Script     string           `json:"script"`
+	Vars       map[string]Var   `json:"vars,omitempty"`
+	Dot        string           `json:"dot"`
+	Status     string           `json:"status"`
+	Executing  bool             `json:"executing"`
+	Error      string           `json:"error"`
+	Owner      string           `json:"owner,omitempty"`
+	Team       string           `json:"team,omitempty"`
+}
+
+// The fields of a task that can be selected when listing tasks.
+var apiTaskFields = map[string]bool{
+	"id":          true,
+	"template-id": true,
+	"type":        true,
+	"dbrps":       true,
+	"script":      true,
+	"vars":        true,
+	"dot":         true,
+	"status":      true,
+	"executing":   true,
+	"error":       true,
+	"owner":       true,
+	"team":        true,
+}
+
+// The fields of a task to create or update, absent fields are left unchanged.
+type apiTaskOptions struct {
+	ID         string           `json:"id"`
+	TemplateID *string          `json:"template-id"`
+	Type       *string          `json:"type"`
+	DBRPs      []kapacitor.DBRP `json:"dbrps"`
+	Script     *string          `json:"script"`
+	Vars       map[string]Var   `json:"vars"`
+	Status     *string          `json:"status"`
+	Team       *string          `json:"team"`
+}
+
+func (ts *Service) apiRoutes() []httpd.Route {
+	return []httpd.Route{
+		{
+			"api-task-list",
+			"GET",
+			tasksPath,
+			true,
+			true,
+			ts.handleAPIListTasks,
+			httpd.ReadPrivilege,
+			false,
+		},
+		{
+			"api-task-create",
+			"POST",
+			tasksPath,
+			true,
+			true,
+			ts.handleAPICreateTask,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"api-task-show",
+			"GET",
+			tasksPathSlash,
+			true,
+			true,
+			ts.handleAPITask,
+			httpd.ReadPrivilege,
+			false,
+		},
+		{
+			"api-task-create",
+			"POST",
+			tasksPathSlash,
+			true,
+			true,
+			ts.handleAPICreateTask,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"api-task-update",
+			"PATCH",
+			tasksPathSlash,
+			true,
+			true,
+			ts.handleAPIUpdateTask,
+			httpd.WritePrivilege,
+			false,
+		},
+		{
+			"api-task-delete",
+			"DELETE",
+			tasksPathSlash,
+			true,
+			true,
+			ts.handleAPIDeleteTask,
+			httpd.WritePrivilege,
+			false,
+		},
+	}
+}
+
+// Get the ID of the task from the path /v1/tasks/:id.
+func taskID(r *http.Request) (string, bool) {
+	id := strings.TrimPrefix(r.URL.Path, tasksPathSlash)
+	return id, id != "" && !strings.Contains(id, "/")
+}
+
+func (ts *Service) apiTask(raw *rawTask) apiTask {
+	info := ts.taskInfo(raw)
+	status := statusDisabled
+	if info.Enabled {
+		status = statusEnabled
+	}
+	return apiTask{
+		ID:         info.Name,
+		TemplateID: info.Template,
+		Type:       info.Type.String(),
+		DBRPs:      info.DBRPs,
+		Script:     info.TICKscript,
+		Vars:       info.Vars,
+		Dot:        info.Dot,
+		Status:     status,
+		Executing:  info.Executing,
+		Error:      info.Error,
+		Owner:      info.Owner,
+		Team:       info.Team,
+	}
+}
+
+// Load a task and check that the user has at least the role for it.
+func (ts *Service) loadAPITask(w http.ResponseWriter, r *http.Request, u *httpd.User, role httpd.Role) (*rawTask, bool) {
+	id, ok := taskID(r)
+	if !ok {
+		httpd.APIError(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+	raw, err := ts.LoadRaw(id)
+	if err != nil {
+		httpd.APIError(w, fmt.Sprintf("unknown task %s", id), http.StatusNotFound)
+		return nil, false
+	}
+	if code, err := checkRole(id, u, raw.Owner, raw.Team, role); err != nil {
+		httpd.APIError(w, err.Error(), code)
+		return nil, false
+	}
+	return raw, true
+}
+
+func (ts *Service) handleAPITask(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	raw, ok := ts.loadAPITask(w, r, u, httpd.ViewerRole)
+	if !ok {
+		return
+	}
+	w.Write(httpd.MarshalJSON(ts.apiTask(raw), true))
+}
+
+func (ts *Service) handleAPIListTasks(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		httpd.APIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := intParam(r, "limit", defaultListLimit)
+	if err != nil {
+		httpd.APIError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if offset < 0 || limit <= 0 {
+		httpd.APIError(w, "offset must not be negative and limit must be positive", http.StatusBadRequest)
+		return
+	}
+	var fields []string
+	if f := r.URL.Query().Get("fields"); f != "" {
+		fields = strings.Split(f, ",")
+		for _, field := range fields {
+			if !apiTaskFields[field] {
+				httpd.APIError(w, fmt.Sprintf("unknown field %q", field), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	names, err := ts.taskNames()
+	if err != nil {
+		httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Only list the tasks the user can see.
+	visible := make([]*rawTask, 0, len(names))
+	for _, name := range names {
+		raw, err := ts.LoadRaw(name)
+		if err != nil {
+			httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if u.Role(raw.Owner, raw.Team) >= httpd.ViewerRole {
+			visible = append(visible, raw)
+		}
+	}
+	page := visible[min(offset, len(visible)):min(offset+limit, len(visible))]
+
+	tasks := make([]interface{}, len(page))
+	for i, raw := range page {
+		t := ts.apiTask(raw)
+		if fields == nil {
+			tasks[i] = t
+			continue
+		}
+		tasks[i], err = selectFields(t, fields)
+		if err != nil {
+			httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type response struct {
+		Tasks  []interface{} `json:"tasks"`
+		Offset int           `json:"offset"`
+		Limit  int           `json:"limit"`
+		Total  int           `json:"total"`
+	}
+	w.Write(httpd.MarshalJSON(response{
+		Tasks:  tasks,
+		Offset: offset,
+		Limit:  limit,
+		Total:  len(visible),
+	}, true))
+}
+
+// Select the fields of the task, the ID is always selected.
+func selectFields(t apiTask, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]interface{})
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]interface{}{"id": t.ID}
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected, nil
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return i, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Return the names of all tasks in order.
+func (ts *Service) taskNames() ([]string, error) {
+	var names []string
+	err := ts.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tasksBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+	return names, err
+}
+
+func (ts *Service) handleAPICreateTask(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	var o apiTaskOptions
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		httpd.APIError(w, "invalid task: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Path != tasksPath {
+		id, ok := taskID(r)
+		if !ok {
+			httpd.APIError(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if o.ID != "" && o.ID != id {
+			httpd.APIError(w, fmt.Sprintf("task id %q does not match the path", o.ID), http.StatusBadRequest)
+			return
+		}
+		o.ID = id
+	}
+	if o.ID == "" || strings.Contains(o.ID, "/") {
+		httpd.APIError(w, "must provide a task id without '/'", http.StatusBadRequest)
+		return
+	}
+	if _, err := ts.LoadRaw(o.ID); err == nil {
+		httpd.APIError(w, fmt.Sprintf("task %s already exists", o.ID), http.StatusConflict)
+		return
+	}
+
+	task := &rawTask{
+		Name:             o.ID,
+		SnapshotInterval: ts.snapshotInterval,
+	}
+	if u != nil {
+		task.Owner = u.Name
+	}
+	if code, err := ts.applyTaskOptions(task, o, false, u); err != nil {
+		httpd.APIError(w, err.Error(), code)
+		return
+	}
+	raw, err := ts.LoadRaw(o.ID)
+	if err != nil {
+		httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", tasksPathSlash+o.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(httpd.MarshalJSON(ts.apiTask(raw), true))
+}
+
+func (ts *Service) handleAPIUpdateTask(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	task, ok := ts.loadAPITask(w, r, u, httpd.MemberRole)
+	if !ok {
+		return
+	}
+	var o apiTaskOptions
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		httpd.APIError(w, "invalid task: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if o.ID != "" && o.ID != task.Name {
+		httpd.APIError(w, "cannot change the id of a task", http.StatusBadRequest)
+		return
+	}
+	if code, err := ts.applyTaskOptions(task, o, true, u); err != nil {
+		httpd.APIError(w, err.Error(), code)
+		return
+	}
+	raw, err := ts.LoadRaw(task.Name)
+	if err != nil {
+		httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(httpd.MarshalJSON(ts.apiTask(raw), true))
+}
+
+func (ts *Service) handleAPIDeleteTask(w http.ResponseWriter, r *http.Request, u *httpd.User) {
+	task, ok := ts.loadAPITask(w, r, u, httpd.OwnerRole)
+	if !ok {
+		return
+	}
+	if err := ts.Delete(task.Name); err != nil {
+		httpd.APIError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Apply the options to the task, save it and set its status.
+// Enabled tasks are restarted if their definition changes,
+// and new tasks that fail to be enabled are deleted.
+// On error the status code of the response is returned.
+func (ts *Service) applyTaskOptions(task *rawTask, o apiTaskOptions, exists bool, u *httpd.User) (int, error) {
+	if o.Status != nil && *o.Status != statusEnabled && *o.Status != statusDisabled {
+		return http.StatusBadRequest, fmt.Errorf("invalid status %q, must be %q or %q", *o.Status, statusEnabled, statusDisabled)
+	}
+
+	// Only the owner may share the task with a team.
+	if o.Team != nil && *o.Team != task.Team {
+		if exists {
+			if code, err := checkRole(task.Name, u, task.Owner, task.Team, httpd.OwnerRole); err != nil {
+				return code, err
+			}
+		}
+		if *o.Team != "" && u != nil && !u.Authorize(httpd.AdminPrivilege) && !u.InTeam(*o.Team) {
+			return http.StatusForbidden, fmt.Errorf("user %q is not a member of team %q", u.Name, *o.Team)
+		}
+		task.Team = *o.Team
+	}
+
+	changed := false
+	if o.TemplateID != nil {
+		if *o.TemplateID == "" {
+			return http.StatusBadRequest, fmt.Errorf("template-id must not be empty, provide a script to define the task without a template")
+		}
+		if o.Script != nil {
+			return http.StatusBadRequest, fmt.Errorf("cannot set both script and template-id")
+		}
+		tmpl, code, err := ts.authorizeTemplate(*o.TemplateID, u, httpd.ViewerRole)
+		if err != nil {
+			if code == http.StatusNotFound {
+				code = http.StatusBadRequest
+			}
+			return code, err
+		}
+		task.Template = tmpl.Name
+		task.TICKscript = ""
+		task.Type = tmpl.Type
+		changed = true
+	}
+	if o.Script != nil {
+		task.TICKscript = *o.Script
+		task.Template = ""
+		task.Vars = nil
+		changed = true
+	}
+	if task.TICKscript == "" && task.Template == "" {
+		return http.StatusBadRequest, fmt.Errorf("must provide a script or a template-id")
+	}
+	if o.Vars != nil {
+		if task.Template == "" {
+			return http.StatusBadRequest, fmt.Errorf("vars can only be set on tasks defined from a template")
+		}
+		task.Vars = o.Vars
+		changed = true
+	}
+	if o.Type != nil {
+		var tt kapacitor.TaskType
+		switch *o.Type {
+		case "stream":
+			tt = kapacitor.StreamTask
+		case "batch":
+			tt = kapacitor.BatchTask
+		default:
+			return http.StatusBadRequest, fmt.Errorf("unknown type %q", *o.Type)
+		}
+		if task.Template != "" && tt != task.Type {
+			return http.StatusBadRequest, fmt.Errorf("task type %s does not match type %s of template %s", tt, task.Type, task.Template)
+		}
+		task.Type = tt
+		changed = true
+	} else if !exists && task.Template == "" {
+		return http.StatusBadRequest, fmt.Errorf("must provide the type of the task")
+	}
+	if o.DBRPs != nil {
+		task.DBRPs = o.DBRPs
+		changed = true
+	}
+	if len(task.DBRPs) == 0 {
+		return http.StatusBadRequest, fmt.Errorf("must provide at least one database and retention policy")
+	}
+
+	if err := ts.Save(task); err != nil {
+		if _, ok := err.(invalidTaskError); ok {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusInternalServerError, err
+	}
+
+	enabled := ts.IsEnabled(task.Name)
+	switch {
+	case o.Status != nil && *o.Status == statusDisabled:
+		if err := ts.Disable(task.Name); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	case enabled && changed:
+		if err := ts.Disable(task.Name); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		fallthrough
+	case o.Status != nil && *o.Status == statusEnabled:
+		if err := ts.Enable(task.Name); err != nil {
+			// Do not keep a new task that failed to be created as enabled.
+			if !exists {
+				if derr := ts.Delete(task.Name); derr != nil {
+					return http.StatusInternalServerError, fmt.Errorf("%s, and failed to delete the task: %s", err, derr)
+				}
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
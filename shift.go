@@ -0,0 +1,56 @@
+package kapacitor
+
+import (
+	"log"
+
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type ShiftNode struct {
+	node
+	s *pipeline.ShiftNode
+}
+
+// Create a new ShiftNode which shifts points and batches in time.
+func newShiftNode(et *ExecutingTask, n *pipeline.ShiftNode, l *log.Logger) (*ShiftNode, error) {
+	sn := &ShiftNode{
+		node: node{Node: n, et: et, logger: l},
+		s:    n,
+	}
+	sn.node.runF = sn.runShift
+	return sn, nil
+}
+
+func (s *ShiftNode) runShift([]byte) error {
+	switch s.Wants() {
+	case pipeline.StreamEdge:
+		for p, ok := s.ins[0].NextPoint(); ok; p, ok = s.ins[0].NextPoint() {
+			p.Time = p.Time.Add(s.s.Shift)
+			for _, child := range s.outs {
+				err := child.CollectPoint(p)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case pipeline.BatchEdge:
+		for b, ok := s.ins[0].NextBatch(); ok; b, ok = s.ins[0].NextBatch() {
+			b.TMax = b.TMax.Add(s.s.Shift)
+			// Copy the points so that the shared batch is not modified.
+			points := make([]models.BatchPoint, len(b.Points))
+			for i, p := range b.Points {
+				p.Time = p.Time.Add(s.s.Shift)
+				points[i] = p
+			}
+			b.Points = points
+			for _, child := range s.outs {
+				err := child.CollectBatch(b)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
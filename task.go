@@ -311,10 +311,14 @@ func (et *ExecutingTask) createNode(p pipeline.Node, l *log.Logger) (Node, error
 		return newBatchNode(et, t, l)
 	case *pipeline.WindowNode:
 		return newWindowNode(et, t, l)
+	case *pipeline.LateNode:
+		return newLateNode(et, t, l)
 	case *pipeline.HTTPOutNode:
 		return newHTTPOutNode(et, t, l)
 	case *pipeline.InfluxDBOutNode:
 		return newInfluxDBOutNode(et, t, l)
+	case *pipeline.KafkaOutNode:
+		return newKafkaOutNode(et, t, l)
 	case *pipeline.MapNode:
 		return newMapNode(et, t, l)
 	case *pipeline.ReduceNode:
@@ -335,6 +339,20 @@ func (et *ExecutingTask) createNode(p pipeline.Node, l *log.Logger) (Node, error
 		return newSampleNode(et, t, l)
 	case *pipeline.DerivativeNode:
 		return newDerivativeNode(et, t, l)
+	case *pipeline.ShiftNode:
+		return newShiftNode(et, t, l)
+	case *pipeline.AggregateNode:
+		return newAggregateNode(et, t, l)
+	case *pipeline.DedupNode:
+		return newDedupNode(et, t, l)
+	case *pipeline.ChangeDetectNode:
+		return newChangeDetectNode(et, t, l)
+	case *pipeline.HoltWintersNode:
+		return newHoltWintersNode(et, t, l)
+	case *pipeline.AnomalyNode:
+		return newAnomalyNode(et, t, l)
+	case *pipeline.ModifyNode:
+		return newModifyNode(et, t, l)
 	case *pipeline.UDFNode:
 		return newUDFNode(et, t, l)
 	case *pipeline.StatsNode:
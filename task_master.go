@@ -2,6 +2,7 @@ package kapacitor
 
 import (
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"sync"
@@ -23,6 +24,13 @@ type UDFService interface {
 	FunctionInfo(name string) (UDFProcessInfo, bool)
 }
 
+// Writes messages to a Kafka topic asynchronously.
+type KafkaWriter interface {
+	Write(key, value []byte, t time.Time) error
+	// Write the queued messages and stop the writer.
+	Close() error
+}
+
 var ErrTaskMasterClosed = errors.New("TaskMaster is closed")
 var ErrTaskMasterOpen = errors.New("TaskMaster is open")
 
@@ -75,6 +83,9 @@ type TaskMaster struct {
 	SensuService interface {
 		Alert(name, output string, level AlertLevel) error
 	}
+	KafkaService interface {
+		NewWriter(topic string, statMap *expvar.Map) (KafkaWriter, error)
+	}
 	LogService LogService
 
 	// Incoming streams
@@ -129,6 +140,7 @@ func (tm *TaskMaster) New() *TaskMaster {
 	n.SlackService = tm.SlackService
 	n.HipChatService = tm.HipChatService
 	n.AlertaService = tm.AlertaService
+	n.KafkaService = tm.KafkaService
 	return n
 }
 
@@ -180,6 +192,7 @@ func (tm *TaskMaster) NewTask(
 	tt TaskType,
 	dbrps []DBRP,
 	snapshotInterval time.Duration,
+	vars map[string]tick.Var,
 ) (*Task, error) {
 	t := &Task{
 		Name:             name,
@@ -197,14 +210,31 @@ func (tm *TaskMaster) NewTask(
 		srcEdge = pipeline.BatchEdge
 	}
 
-	p, err := pipeline.CreatePipeline(script, srcEdge, scope, tm.DeadmanService)
+	p, err := pipeline.CreatePipeline(script, srcEdge, scope, tm.DeadmanService, vars)
 	if err != nil {
+		// Report which task the script error belongs to.
+		if e, ok := err.(*tick.Error); ok {
+			e.Name = name
+		}
 		return nil, err
 	}
 	t.Pipeline = p
 	return t, nil
 }
 
+// Lint the TICKscript of a task of the given type.
+// See pipeline.Lint for the checks performed.
+func (tm *TaskMaster) LintTask(script string, tt TaskType, vars map[string]tick.Var) ([]tick.LintError, error) {
+	var srcEdge pipeline.EdgeType
+	switch tt {
+	case StreamTask:
+		srcEdge = pipeline.StreamEdge
+	case BatchTask:
+		srcEdge = pipeline.BatchEdge
+	}
+	return pipeline.Lint(script, srcEdge, tm.CreateTICKScope(), tm.DeadmanService, vars)
+}
+
 func (tm *TaskMaster) waitForForks() {
 	if tm.drained {
 		return
@@ -310,6 +340,17 @@ func (tm *TaskMaster) StopTask(name string) error {
 	return tm.stopTask(name)
 }
 
+// Stop the executing task if it is still running under its name.
+// A task that finishes after it was restarted must not stop its replacement.
+func (tm *TaskMaster) StopExecutingTask(et *ExecutingTask) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.tasks[et.Task.Name] != et {
+		return nil
+	}
+	return tm.stopTask(et.Task.Name)
+}
+
 // internal stopTask function. The caller must have acquired
 // the lock in order to call this function
 func (tm *TaskMaster) stopTask(name string) (err error) {
@@ -0,0 +1,97 @@
+// Tickfmt formats TICKscripts into their canonical form, see tick.Format.
+//
+// Usage:
+//
+//	tickfmt [flags] [path ...]
+//
+// Without a path tickfmt formats the standard input.
+// By default the formatted scripts are written to the standard output.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/influxdata/kapacitor/tick"
+)
+
+var (
+	write = flag.Bool("w", false, "write the result to the source file instead of the standard output")
+	list  = flag.Bool("l", false, "list the files whose formatting differs from tickfmt's")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: tickfmt [flags] [path ...]")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with the standard input")
+			os.Exit(2)
+		}
+		if err := formatFile("<standard input>", os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		err = formatFile(path, f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(path string, f *os.File) error {
+	src, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	res, err := tick.Format(string(src))
+	if err != nil {
+		if e, ok := err.(*tick.Error); ok {
+			e.Name = path
+		}
+		return err
+	}
+	changed := !bytes.Equal(src, []byte(res))
+	if *list && changed {
+		fmt.Println(path)
+	}
+	if *write {
+		if changed {
+			fi, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path, []byte(res), fi.Mode().Perm())
+		}
+		return nil
+	}
+	if !*list {
+		fmt.Print(res)
+	}
+	return nil
+}
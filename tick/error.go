@@ -0,0 +1,89 @@
+package tick
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// An error at a position in a TICKscript.
+// Parser, evaluation and StatefulExpr errors are all reported as an *Error.
+type Error struct {
+	// Name of the script, i.e. a file path or task name, may be empty.
+	Name string
+	// Line and character within the line where the error occurred.
+	// Both are 1 based, a Line of 0 means the position is unknown.
+	Line int
+	Char int
+	Msg  string
+	// The line of the script that contains the error, may be empty.
+	Snippet string
+}
+
+// Format the error as 'name:line:char: msg' followed by
+// the snippet and a caret pointing at the error, if known.
+func (e *Error) Error() string {
+	var b bytes.Buffer
+	if e.Name != "" {
+		b.WriteString(e.Name)
+		b.WriteString(":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", e.Line, e.Char)
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(e.Msg)
+	if e.Snippet != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Snippet)
+		b.WriteString("\n")
+		// Keep tabs from the snippet so the caret lines up.
+		for i, r := range e.Snippet {
+			if i >= e.Char-1 {
+				break
+			}
+			if r == '\t' {
+				b.WriteRune('\t')
+			} else {
+				b.WriteRune(' ')
+			}
+		}
+		b.WriteString("^")
+	}
+	return b.String()
+}
+
+// Wrap err with the position of the node.
+// Errors that already have a position are returned unchanged.
+func errorAt(n Node, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok || n == nil || n.Line() == 0 {
+		return err
+	}
+	return &Error{
+		Line: n.Line(),
+		Char: n.Char(),
+		Msg:  err.Error(),
+	}
+}
+
+// Add the snippet of the script the error refers to.
+func withSnippet(err error, script string) error {
+	if e, ok := err.(*Error); ok && e.Line > 0 && e.Snippet == "" {
+		e.Snippet = snippet(script, e.Line)
+	}
+	return err
+}
+
+// Return the given 1 based line of the script.
+func snippet(script string, line int) string {
+	lines := strings.Split(script, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
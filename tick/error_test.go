@@ -0,0 +1,56 @@
+package tick
+
+import (
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	testCases := []struct {
+		err *Error
+		exp string
+	}{
+		{
+			err: &Error{Msg: "no position"},
+			exp: "no position",
+		},
+		{
+			err: &Error{Name: "cpu", Msg: "no position"},
+			exp: "cpu: no position",
+		},
+		{
+			err: &Error{Line: 2, Char: 5, Msg: "bad"},
+			exp: "2:5: bad",
+		},
+		{
+			err: &Error{Name: "cpu.tick", Line: 2, Char: 5, Msg: "bad", Snippet: "\tvar x"},
+			exp: "cpu.tick:2:5: bad\n\tvar x\n\t   ^",
+		},
+	}
+	for _, tc := range testCases {
+		if got := tc.err.Error(); got != tc.exp {
+			t.Errorf("unexpected error string:\ngot %q\nexp %q", got, tc.exp)
+		}
+	}
+}
+
+func TestStatefulExpr_ErrorPosition(t *testing.T) {
+	root, err := parse("f(lambda: \"value\"\n\t> 'str')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda := root.(*ListNode).Nodes[0].(*FunctionNode).Args[0].(*LambdaNode)
+	se := NewStatefulExpr(lambda.Node)
+	scope := NewScope()
+	scope.Set("value", 1.0)
+	_, err = se.EvalBool(scope)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("unexpected error type %T", err)
+	}
+	if e.Line != 2 || e.Char != 2 {
+		t.Errorf("unexpected error position: got %d:%d exp 2:2", e.Line, e.Char)
+	}
+}
@@ -30,7 +30,15 @@ type SelfDescriber interface {
 // Parse and evaluate a given script for the scope.
 // This evaluation method uses reflection to call
 // methods on objects within the scope.
-func Evaluate(script string, scope *Scope) (err error) {
+func Evaluate(script string, scope *Scope) error {
+	return EvaluateWithVars(script, scope, nil)
+}
+
+// Parse and evaluate a given script for the scope,
+// overriding the default values of declared variables with vars.
+// Each var must be declared in the script with a literal
+// default value of the same type.
+func EvaluateWithVars(script string, scope *Scope, vars map[string]Var) (err error) {
 	defer func(errP *error) {
 		r := recover()
 		if r == ErrEmptyStack {
@@ -48,9 +56,14 @@ func Evaluate(script string, scope *Scope) (err error) {
 		return err
 	}
 
+	err = applyVars(root, vars)
+	if err != nil {
+		return withSnippet(err, script)
+	}
+
 	// Use a stack machine to evaluate the AST
 	stck := &stack{}
-	return eval(root, scope, stck)
+	return withSnippet(eval(root, scope, stck), script)
 }
 
 // Evaluate a node using a stack machine in a given scope
@@ -75,7 +88,10 @@ func eval(n Node, scope *Scope, stck *stack) (err error) {
 		if err != nil {
 			return
 		}
-		evalUnary(node.Operator, scope, stck)
+		err = evalUnary(node.Operator, scope, stck)
+		if err != nil {
+			return errorAt(node, err)
+		}
 	case *LambdaNode:
 		// Catch panic from resolveIdents and return as error.
 		err = func() (e error) {
@@ -103,7 +119,11 @@ func eval(n Node, scope *Scope, stck *stack) (err error) {
 		}
 		err = evalBinary(node.Operator, scope, stck)
 		if err != nil {
-			return
+			if node.Operator == TokenDot {
+				// The error came from the function or property on the right.
+				return errorAt(node.Right, err)
+			}
+			return errorAt(node, err)
 		}
 	case *FunctionNode:
 		args := make([]interface{}, len(node.Args))
@@ -118,13 +138,13 @@ func eval(n Node, scope *Scope, stck *stack) (err error) {
 				// Resolve identifier
 				a, err = scope.Get(typed.Ident)
 				if err != nil {
-					return err
+					return errorAt(arg, err)
 				}
 			case unboundFunc:
 				// Call global func
 				a, err = typed(nil)
 				if err != nil {
-					return err
+					return errorAt(arg, err)
 				}
 			}
 
@@ -160,6 +180,8 @@ func evalUnary(op tokenType, scope *Scope, stck *stack) error {
 			stck.Push(-1 * n)
 		case int64:
 			stck.Push(-1 * n)
+		case time.Duration:
+			stck.Push(-1 * n)
 		default:
 			return fmt.Errorf("invalid arugument to '-' %v", v)
 		}
@@ -229,9 +251,15 @@ func evalFunc(f *FunctionNode, scope *Scope, stck *stack, args []interface{}) er
 
 		}
 	}
-	fnc := unboundFunc(func(obj interface{}) (_ interface{}, err error) {
+	fnc := unboundFunc(func(obj interface{}) (ret interface{}, err error) {
 		//Setup recover method if there is a panic during the method call
 		defer rec(obj, &err)
+		// Record where the returned object was created
+		defer func() {
+			if err == nil {
+				scope.setNode(ret, f)
+			}
+		}()
 
 		if obj == nil {
 			// Object is nil, check for func in scope
@@ -400,9 +428,9 @@ func resolveIdents(n Node, scope *Scope) Node {
 	case *IdentifierNode:
 		v, err := scope.Get(node.Ident)
 		if err != nil {
-			panic(err)
+			panic(errorAt(node, err))
 		}
-		return valueToLiteralNode(node.pos, v)
+		return valueToLiteralNode(node.position, v)
 	case *UnaryNode:
 		node.Node = resolveIdents(node.Node, scope)
 	case *BinaryNode:
@@ -416,45 +444,52 @@ func resolveIdents(n Node, scope *Scope) Node {
 		for i, n := range node.Nodes {
 			node.Nodes[i] = resolveIdents(n, scope)
 		}
+	case *ListLiteralNode:
+		for i, n := range node.Elements {
+			node.Elements[i] = resolveIdents(n, scope)
+		}
 	}
 	return n
 }
 
 // Convert raw value to literal node, for all supported basic types.
-func valueToLiteralNode(pos pos, v interface{}) Node {
+func valueToLiteralNode(p position, v interface{}) Node {
 	switch value := v.(type) {
 	case bool:
 		return &BoolNode{
-			pos:  pos,
-			Bool: value,
+			position: p,
+			Bool:     value,
 		}
 	case int64:
 		return &NumberNode{
-			pos:   pos,
-			IsInt: true,
-			Int64: value,
+			position: p,
+			IsInt:    true,
+			Int64:    value,
 		}
 	case float64:
 		return &NumberNode{
-			pos:     pos,
-			IsFloat: true,
-			Float64: value,
+			position: p,
+			IsFloat:  true,
+			Float64:  value,
 		}
 	case time.Duration:
 		return &DurationNode{
-			pos: pos,
-			Dur: value,
+			position: p,
+			Dur:      value,
 		}
 	case string:
 		return &StringNode{
-			pos:     pos,
-			Literal: value,
+			position: p,
+			Literal:  value,
 		}
 	case *regexp.Regexp:
 		return &RegexNode{
-			pos:   pos,
-			Regex: value,
+			position: p,
+			Regex:    value,
 		}
+	case *ListLiteralNode:
+		// Vars declared as list literals are stored as their node.
+		return value
 	default:
 		panic(fmt.Errorf("unsupported literal type %T", v))
 	}
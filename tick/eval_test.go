@@ -2,6 +2,8 @@ package tick_test
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -122,6 +124,37 @@ s2.structC()
 	}
 }
 
+func TestEvaluate_NegativeDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	script := `
+var s2 = a.structB()
+	.field3(-15m)
+
+s2.structC()
+	.options('c', 21.5, -7h)
+`
+
+	scope := tick.NewScope()
+	a := &structA{}
+	scope.Set("a", a)
+
+	err := tick.Evaluate(script, scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s2I, err := scope.Get("s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := s2I.(*structB)
+	assert.Equal(-time.Minute*15, s2.Field3)
+	if assert.NotNil(s2.c) {
+		assert.Equal(-time.Hour*7, s2.c.field3)
+	}
+}
+
 func TestEvaluate_DynamicMethod(t *testing.T) {
 	script := `var x = a.dynamicMethod(1,'str', 10s).sad(FALSE)`
 
@@ -173,3 +206,141 @@ func TestEvaluate_DynamicMethod(t *testing.T) {
 		t.Errorf("unexpected x.args[1]: got %v exp %v", got, exp)
 	}
 }
+
+func TestEvaluate_Vars(t *testing.T) {
+	script := `
+var f = 1.5
+var i = 10
+var s = 'default'
+var d = 10s
+var r = /^default$/
+var b = TRUE
+var x = a.structB()
+	.field1(s)
+	.field2(i)
+	.field3(d)
+`
+
+	scope := tick.NewScope()
+	a := &structA{}
+	scope.Set("a", a)
+
+	i, err := tick.NewVar("int", 42.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := tick.NewVar("duration", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]tick.Var{
+		"i": i,
+		"d": d,
+		"s": {Type: tick.TString, Value: "override"},
+		"b": {Type: tick.TBool, Value: false},
+	}
+
+	err = tick.EvaluateWithVars(script, scope, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xI, err := scope.Get("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := xI.(*structB)
+	if got, exp := x.Field1, "override"; got != exp {
+		t.Errorf("unexpected x.Field1: got %v exp %v", got, exp)
+	}
+	if got, exp := x.Field2, int64(42); got != exp {
+		t.Errorf("unexpected x.Field2: got %v exp %v", got, exp)
+	}
+	if got, exp := x.Field3, time.Minute; got != exp {
+		t.Errorf("unexpected x.Field3: got %v exp %v", got, exp)
+	}
+	if b, err := scope.Get("b"); err != nil || b != false {
+		t.Errorf("unexpected b: got %v exp false", b)
+	}
+	if f, err := scope.Get("f"); err != nil || f != 1.5 {
+		t.Errorf("unexpected f: got %v exp 1.5", f)
+	}
+}
+
+func TestEvaluate_VarsErrors(t *testing.T) {
+	script := `
+var i = 10
+var x = a.structB().field2(i)
+`
+	testCases := []struct {
+		vars map[string]tick.Var
+		err  string
+	}{
+		{
+			vars: map[string]tick.Var{"i": {Type: tick.TFloat, Value: 1.0}},
+			err:  "2:5: invalid type for var \"i\": got float exp int\nvar i = 10\n    ^",
+		},
+		{
+			vars: map[string]tick.Var{"y": {Type: tick.TInt, Value: int64(1)}},
+			err:  "vars not declared in script: y",
+		},
+		{
+			vars: map[string]tick.Var{"x": {Type: tick.TInt, Value: int64(1)}},
+			err:  "3:5: cannot override var \"x\", its default value is not a literal\nvar x = a.structB().field2(i)\n    ^",
+		},
+	}
+
+	for _, tc := range testCases {
+		scope := tick.NewScope()
+		scope.Set("a", &structA{})
+		err := tick.EvaluateWithVars(script, scope, tc.vars)
+		if err == nil {
+			t.Errorf("expected error %q", tc.err)
+		} else if err.Error() != tc.err {
+			t.Errorf("unexpected error: got %q exp %q", err.Error(), tc.err)
+		}
+	}
+}
+
+func TestEvaluate_ErrorPosition(t *testing.T) {
+	script := `
+var x = a.structB()
+	.structC()
+	.nomethod()
+`
+	scope := tick.NewScope()
+	scope.Set("a", &structA{})
+	err := tick.Evaluate(script, scope)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	e, ok := err.(*tick.Error)
+	if !ok {
+		t.Fatalf("unexpected error type %T", err)
+	}
+	if e.Line != 4 || e.Char != 3 {
+		t.Errorf("unexpected error position: got %d:%d exp 4:3", e.Line, e.Char)
+	}
+	if exp := "\n\t.nomethod()\n\t ^"; !strings.HasSuffix(err.Error(), exp) {
+		t.Errorf("unexpected error snippet:\ngot %q\nexp suffix %q", err.Error(), exp)
+	}
+}
+
+func TestDeclaredVars(t *testing.T) {
+	script := `
+var f = -1.5
+var d = 10s
+var x = a.structB()
+`
+	vars, err := tick.DeclaredVars(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]tick.Var{
+		"f": {Type: tick.TFloat, Value: -1.5},
+		"d": {Type: tick.TDuration, Value: 10 * time.Second},
+	}
+	if !reflect.DeepEqual(vars, exp) {
+		t.Errorf("unexpected vars: got %v exp %v", vars, exp)
+	}
+}
@@ -0,0 +1,276 @@
+package tick
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/influxdb/influxdb/influxql"
+)
+
+// The string used to indent one level.
+const indentStep = "    "
+
+// Unary operators bind more tightly than any binary operator.
+const unaryPrecedence = 6
+
+// Format a TICKscript into its canonical form.
+//
+// Each statement is placed on its own line, separated by a blank line,
+// except for consecutive declarations of simple values which are grouped together.
+// Links of a chain that started on a new line are placed on their own line and indented,
+// links that were indented further than the other links in the chain,
+// i.e. property methods, are indented one more level.
+// Comments are kept on their own line before the statement or chain link they precede.
+func Format(script string) (string, error) {
+	root, err := parse(script)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	f := &formatter{buf: &buf}
+	f.program(root.(*ListNode))
+	return buf.String(), nil
+}
+
+type formatter struct {
+	buf *bytes.Buffer
+}
+
+func (f *formatter) write(s ...string) {
+	for _, str := range s {
+		f.buf.WriteString(str)
+	}
+}
+
+func (f *formatter) program(l *ListNode) {
+	for i, n := range l.Nodes {
+		if i > 0 {
+			f.write("\n")
+			if blankLineBefore(l.Nodes[i-1], l.Nodes[i:]) {
+				f.write("\n")
+			}
+		}
+		f.statement(n)
+	}
+	if len(l.Nodes) > 0 {
+		f.write("\n")
+	}
+}
+
+// Determine whether a blank line separates the node prev from the next node in rest.
+func blankLineBefore(prev Node, rest []Node) bool {
+	if c, ok := prev.(*CommentNode); ok {
+		// Keep comments attached to the following statement,
+		// unless they were separated in the original script.
+		return startLine(rest[0]) > c.Line()+len(c.Comments)
+	}
+	// Find the statement any comments belong to.
+	var next Node
+	for _, n := range rest {
+		if _, ok := n.(*CommentNode); !ok {
+			next = n
+			break
+		}
+	}
+	return next == nil || !isSimpleDeclaration(prev) || !isSimpleDeclaration(next)
+}
+
+// The line a statement starts on.
+// Binary nodes are positioned at their operator, so use their leftmost operand.
+func startLine(n Node) int {
+	for {
+		b, ok := n.(*BinaryNode)
+		if !ok {
+			return n.Line()
+		}
+		n = b.Left
+	}
+}
+
+// Reports whether n declares a var whose value is not a chain.
+func isSimpleDeclaration(n Node) bool {
+	b, ok := n.(*BinaryNode)
+	if !ok || b.Operator != TokenAsgn {
+		return false
+	}
+	if r, ok := b.Right.(*BinaryNode); ok && r.Operator == TokenDot {
+		return false
+	}
+	return true
+}
+
+func (f *formatter) statement(n Node) {
+	switch node := n.(type) {
+	case *CommentNode:
+		f.comment(node, "")
+	case *BinaryNode:
+		if node.Operator == TokenAsgn {
+			f.write("var ")
+			f.expr(node.Left, "")
+			f.write(" = ")
+			f.expr(node.Right, "")
+			return
+		}
+		f.expr(node, "")
+	default:
+		f.expr(node, "")
+	}
+}
+
+// Write the lines of a comment, each line is followed by a newline and indent.
+func (f *formatter) comment(c *CommentNode, indent string) {
+	for i, line := range c.Comments {
+		if i > 0 {
+			f.write("\n", indent)
+		}
+		f.write("//")
+		if line != "" {
+			f.write(" ", line)
+		}
+	}
+}
+
+// Write an expression, indent is the indentation of the line the expression starts on.
+func (f *formatter) expr(n Node, indent string) {
+	switch node := n.(type) {
+	case *BoolNode:
+		if node.Bool {
+			f.write("TRUE")
+		} else {
+			f.write("FALSE")
+		}
+	case *NumberNode:
+		f.write(formatNumber(node))
+	case *DurationNode:
+		f.write(influxql.FormatDuration(node.Dur))
+	case *StringNode:
+		f.write(formatString(node.Literal))
+	case *ReferenceNode:
+		f.write(`"`, strings.Replace(node.Reference, `"`, `\"`, -1), `"`)
+	case *RegexNode:
+		f.write("/", strings.Replace(node.Regex.String(), "/", `\/`, -1), "/")
+	case *StarNode:
+		f.write("*")
+	case *IdentifierNode:
+		f.write(node.Ident)
+	case *LambdaNode:
+		f.write("lambda: ")
+		f.expr(node.Node, indent)
+	case *ListLiteralNode:
+		f.write("[")
+		for i, e := range node.Elements {
+			if i > 0 {
+				f.write(", ")
+			}
+			f.expr(e, indent)
+		}
+		f.write("]")
+	case *FunctionNode:
+		f.write(node.Func, "(")
+		for i, arg := range node.Args {
+			if i > 0 {
+				f.write(", ")
+			}
+			f.expr(arg, indent)
+		}
+		f.write(")")
+	case *UnaryNode:
+		f.write(node.Operator.String())
+		f.operand(node.Node, unaryPrecedence, indent)
+	case *BinaryNode:
+		if node.Operator == TokenDot {
+			f.chain(node, indent)
+			return
+		}
+		p := precedence[node.Operator]
+		// The parser groups operators of the same precedence from the right,
+		// so only the left operand needs parens for the same precedence.
+		f.operand(node.Left, p+1, indent)
+		f.write(" ", node.Operator.String(), " ")
+		f.operand(node.Right, p, indent)
+	}
+}
+
+// Write an operand of an operator, adding parens if it binds less tightly than minPrecedence.
+func (f *formatter) operand(n Node, minPrecedence int, indent string) {
+	if b, ok := n.(*BinaryNode); ok && b.Operator != TokenDot && precedence[b.Operator] < minPrecedence {
+		f.write("(")
+		f.expr(n, indent)
+		f.write(")")
+		return
+	}
+	f.expr(n, indent)
+}
+
+// Write a chain of '.' operators.
+// Links that started on a new line in the original script are written on
+// their own line, and links indented further than the least indented link are
+// written one level deeper.
+func (f *formatter) chain(n *BinaryNode, indent string) {
+	// Flatten the left-associative chain into its root and links.
+	var links []*BinaryNode
+	var root Node = n
+	for {
+		b, ok := root.(*BinaryNode)
+		if !ok || b.Operator != TokenDot {
+			break
+		}
+		links = append(links, b)
+		root = b.Left
+	}
+	for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+		links[i], links[j] = links[j], links[i]
+	}
+
+	// Determine which links start a new line and the least indented of them.
+	newLine := make([]bool, len(links))
+	minChar := -1
+	prevLine := root.Line()
+	for i, link := range links {
+		fn, _ := link.Right.(*FunctionNode)
+		newLine[i] = link.Line() > prevLine || (fn != nil && fn.Comment != nil)
+		if newLine[i] && (minChar == -1 || link.Char() < minChar) {
+			minChar = link.Char()
+		}
+		prevLine = link.Right.Line()
+	}
+
+	f.expr(root, indent)
+	for i, link := range links {
+		linkIndent := indent
+		if newLine[i] {
+			linkIndent += indentStep
+			if link.Char() > minChar {
+				linkIndent += indentStep
+			}
+			f.write("\n", linkIndent)
+			if fn, ok := link.Right.(*FunctionNode); ok && fn.Comment != nil {
+				f.comment(fn.Comment, linkIndent)
+				f.write("\n", linkIndent)
+			}
+		}
+		f.write(".")
+		f.expr(link.Right, linkIndent)
+	}
+}
+
+func formatNumber(n *NumberNode) string {
+	if n.IsInt {
+		return strconv.FormatInt(n.Int64, 10)
+	}
+	s := strconv.FormatFloat(n.Float64, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		// Keep the number a float.
+		s += ".0"
+	}
+	return s
+}
+
+// Quote a string literal, multi-line strings use triple quotes when possible.
+func formatString(s string) string {
+	if strings.Contains(s, "\n") && !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'") {
+		return "'''" + s + "'''"
+	}
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
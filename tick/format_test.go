@@ -0,0 +1,109 @@
+package tick
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		script string
+		exp    string
+	}{
+		{
+			script: `var  a=1
+var b =   'x'
+var c = stream.from()`,
+			exp: `var a = 1
+var b = 'x'
+
+var c = stream.from()
+`,
+		},
+		{
+			script: `
+//header
+
+// Window the data
+var w = stream
+  .from().measurement('cpu')
+  //group by host
+  .groupBy('host')
+  .window()
+      .period(60s)
+      // every minute
+      .every(1m)
+  .mapReduce(influxql.mean('value'))
+w.httpOut('mean')
+// trailing`,
+			exp: `// header
+
+// Window the data
+var w = stream
+    .from().measurement('cpu')
+    // group by host
+    .groupBy('host')
+    .window()
+        .period(1m)
+        // every minute
+        .every(1m)
+    .mapReduce(influxql.mean('value'))
+
+w.httpOut('mean')
+
+// trailing
+`,
+		},
+		{
+			script: `stream.where(lambda:("a"+"b")*2>10.  AND  !("c"=='x'))`,
+			exp: `stream.where(lambda: ("a" + "b") * 2 > 10.0 AND !("c" == 'x'))
+`,
+		},
+		{
+			script: `stream.where(lambda: ("a" - "b") - "c" > "a" - "b" - "c")`,
+			exp: `stream.where(lambda: ("a" - "b") - "c" > "a" - "b" - "c")
+`,
+		},
+		{
+			script: `stream.where(lambda: "host" =~ /^a\/b$/ AND "\"q\"" != '''it's''' OR "d" == -5h)`,
+			exp: `stream.where(lambda: "host" =~ /^a\/b$/ AND "\"q\"" != 'it\'s' OR "d" == -5h)
+`,
+		},
+		{
+			script: `stream.where(lambda: "host"  NOT  IN ['a','b', ] AND "cpu" IN [1,-2.5])`,
+			exp: `stream.where(lambda: "host" NOT IN ['a', 'b'] AND "cpu" IN [1, -2.5])
+`,
+		},
+		{
+			script: `batch.query('''SELECT mean(value)
+FROM cpu''')`,
+			exp: `batch.query('''SELECT mean(value)
+FROM cpu''')
+`,
+		},
+	}
+	for _, tc := range testCases {
+		got, err := Format(tc.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.exp {
+			t.Errorf("unexpected format:\ngot\n%s\nexp\n%s", got, tc.exp)
+			continue
+		}
+		// Formatting is idempotent.
+		again, err := Format(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != got {
+			t.Errorf("formatting is not idempotent:\ngot\n%s\nexp\n%s", again, got)
+		}
+	}
+}
+
+func TestFormat_ParseError(t *testing.T) {
+	_, err := Format("var x = ")
+	if err == nil {
+		t.Fatal("expected parse error")
+	}
+}
@@ -2,6 +2,7 @@ package tick
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -27,11 +28,14 @@ const (
 	TokenDuration
 	TokenLParen
 	TokenRParen
+	TokenLBracket
+	TokenRBracket
 	TokenComma
 	TokenNot
 	TokenTrue
 	TokenFalse
 	TokenRegex
+	TokenComment
 
 	// begin operator tokens
 	begin_tok_operator
@@ -61,6 +65,8 @@ const (
 	TokenGreaterEqual
 	TokenRegexEqual
 	TokenRegexNotEqual
+	TokenIn
+	TokenNotIn
 
 	//end comparison operators
 	end_tok_operator_comp
@@ -86,6 +92,8 @@ var operatorStr = [...]string{
 	TokenRegexNotEqual: "!~",
 	TokenAnd:           "AND",
 	TokenOr:            "OR",
+	TokenIn:            "IN",
+	TokenNotIn:         "NOT IN",
 }
 
 var strToOperator map[string]tokenType
@@ -93,6 +101,8 @@ var strToOperator map[string]tokenType
 var keywords = map[string]tokenType{
 	"AND":    TokenAnd,
 	"OR":     TokenOr,
+	"IN":     TokenIn,
+	"NOT":    TokenNotIn,
 	"TRUE":   TokenTrue,
 	"FALSE":  TokenFalse,
 	"var":    TokenVar,
@@ -127,6 +137,8 @@ func (t tokenType) String() string {
 		return "string"
 	case t == TokenRegex:
 		return "regex"
+	case t == TokenComment:
+		return "comment"
 	case t == TokenDot:
 		return "."
 	case t == TokenAsgn:
@@ -135,6 +147,10 @@ func (t tokenType) String() string {
 		return "("
 	case t == TokenRParen:
 		return ")"
+	case t == TokenLBracket:
+		return "["
+	case t == TokenRBracket:
+		return "]"
 	case t == TokenComma:
 		return ","
 	case t == TokenNot:
@@ -179,12 +195,19 @@ type lexer struct {
 	pos    int        // current position in the input.
 	width  int        // width of last rune read from input.
 	tokens chan token // channel of scanned tokens.
+	lines  []int      // start position of each line in the input.
 }
 
 func lex(input string) *lexer {
 	l := &lexer{
 		input:  input,
 		tokens: make(chan token),
+		lines:  []int{0},
+	}
+	for i, c := range input {
+		if c == '\n' {
+			l.lines = append(l.lines, i+1)
+		}
 	}
 
 	go l.run()
@@ -213,11 +236,17 @@ func (l *lexer) nextToken() (token, bool) {
 	return tok, closed
 }
 
-// lineNumber reports which line number and start of line position of a given position is on in the input
+// lineNumber reports which line number and character within the line a given position is on in the input.
+// Both are 1 based.
 func (l *lexer) lineNumber(pos int) (int, int) {
-	line := 1 + strings.Count(l.input[:pos], "\n")
-	i := strings.LastIndex(l.input[:pos], "\n")
-	return line, pos - i
+	line := sort.Search(len(l.lines), func(i int) bool { return l.lines[i] > pos })
+	return line, pos - l.lines[line-1] + 1
+}
+
+// position returns the position of the given byte offset in the input.
+func (l *lexer) position(pos int) position {
+	line, char := l.lineNumber(pos)
+	return position{pos: pos, line: line, char: char}
 }
 
 // next returns the next rune in the input.
@@ -269,6 +298,26 @@ func (l *lexer) ignoreSpace() {
 	l.backup()
 }
 
+// skip a contiguous block of spaces within the current token.
+func (l *lexer) skipSpace() {
+	for isSpace(l.next()) {
+	}
+	l.backup()
+}
+
+// expect the next runes to be the word w, not followed by other identifier characters.
+func (l *lexer) expectWord(w string) bool {
+	if !strings.HasPrefix(l.input[l.pos:], w) {
+		return false
+	}
+	end := l.pos + len(w)
+	if r, _ := utf8.DecodeRuneInString(l.input[end:]); end < len(l.input) && isValidIdent(r) {
+		return false
+	}
+	l.pos = end
+	return true
+}
+
 // expect the next rune to be r
 func (l *lexer) expect(r rune) bool {
 	if l.peek() == r {
@@ -302,6 +351,12 @@ func lexToken(l *lexer) stateFn {
 		case r == ')':
 			l.emit(TokenRParen)
 			return lexToken
+		case r == '[':
+			l.emit(TokenLBracket)
+			return lexToken
+		case r == ']':
+			l.emit(TokenRBracket)
+			return lexToken
 		case r == '.':
 			l.emit(TokenDot)
 			return lexToken
@@ -393,6 +448,13 @@ func lexIdentOrKeyword(l *lexer) stateFn {
 				if t == TokenLambda && l.next() != ':' {
 					return l.errorf("missing ':' on lambda keyword")
 				}
+				if t == TokenNotIn {
+					// NOT is only valid as part of the NOT IN operator.
+					l.skipSpace()
+					if !l.expectWord("IN") {
+						return l.errorf("missing 'IN' after NOT keyword")
+					}
+				}
 				l.emit(t)
 			} else {
 				l.emit(TokenIdent)
@@ -533,7 +595,8 @@ func lexComment(l *lexer) stateFn {
 	for {
 		switch r := l.next(); {
 		case r == '\n' || r == eof:
-			l.ignore()
+			l.backup()
+			l.emit(TokenComment)
 			return lexToken
 		}
 	}
@@ -152,6 +152,20 @@ func TestLexer(t *testing.T) {
 				token{TokenEOF, 1, ""},
 			},
 		},
+		{
+			in: "[",
+			tokens: []token{
+				token{TokenLBracket, 0, "["},
+				token{TokenEOF, 1, ""},
+			},
+		},
+		{
+			in: "]",
+			tokens: []token{
+				token{TokenRBracket, 0, "]"},
+				token{TokenEOF, 1, ""},
+			},
+		},
 		// Keywords
 		{
 			in: "AND",
@@ -167,6 +181,26 @@ func TestLexer(t *testing.T) {
 				token{TokenEOF, 2, ""},
 			},
 		},
+		{
+			in: "IN",
+			tokens: []token{
+				token{TokenIn, 0, "IN"},
+				token{TokenEOF, 2, ""},
+			},
+		},
+		{
+			in: "NOT  IN",
+			tokens: []token{
+				token{TokenNotIn, 0, "NOT  IN"},
+				token{TokenEOF, 7, ""},
+			},
+		},
+		{
+			in: "NOT INT",
+			tokens: []token{
+				token{TokenError, 0, "missing 'IN' after NOT keyword"},
+			},
+		},
 		{
 			in: "TRUE",
 			tokens: []token{
@@ -479,6 +513,7 @@ func TestLexer(t *testing.T) {
 				token{TokenIdent, 8, "avg"},
 				token{TokenLParen, 11, "("},
 				token{TokenRParen, 12, ")"},
+				token{TokenComment, 14, "// Comment all of this is ignored"},
 				token{TokenIdent, 48, "x"},
 				token{TokenDot, 49, "."},
 				token{TokenIdent, 50, "groupby"},
@@ -497,6 +532,7 @@ func TestLexer(t *testing.T) {
 				token{TokenIdent, 8, "avg"},
 				token{TokenLParen, 11, "("},
 				token{TokenRParen, 12, ")"},
+				token{TokenComment, 14, "// Comment all of this is ignored"},
 				token{TokenEOF, 47, ""},
 			},
 		},
@@ -0,0 +1,262 @@
+package tick
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A problem found while linting a TICKscript.
+type LintError struct {
+	Line int
+	Char int
+	Msg  string
+}
+
+func (e LintError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Char, e.Msg)
+}
+
+// Create a LintError at the position of a node.
+// A nil node means the position is unknown.
+func NewLintError(n Node, format string, args ...interface{}) LintError {
+	e := LintError{Msg: fmt.Sprintf(format, args...)}
+	if n != nil {
+		e.Line = n.Line()
+		e.Char = n.Char()
+	}
+	return e
+}
+
+// Return type of the functions available to lambda expressions.
+var funcTypes = map[string]ValueType{
+	"bool":  TBool,
+	"int":   TInt,
+	"float": TFloat,
+	"count": TInt,
+}
+
+// Lint a TICKscript for problems that can be found without evaluating it.
+// Currently reports unused variables and type errors in lambda expressions.
+// Only a parse error is returned as an error, all other problems are returned as LintErrors.
+func Lint(script string) ([]LintError, error) {
+	root, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{
+		types: make(map[string]ValueType),
+		used:  make(map[string]bool),
+		funcs: NewFunctions(),
+	}
+
+	decls := declarations(root)
+	for _, d := range decls {
+		ident := d.Left.(*IdentifierNode)
+		if v, ok := literalValue(d.Right); ok {
+			l.types[ident.Ident] = TypeOf(v)
+		}
+	}
+
+	l.walk(root)
+
+	for _, d := range decls {
+		ident := d.Left.(*IdentifierNode)
+		if !l.used[ident.Ident] {
+			l.errorf(ident, "var %s is declared but never used", ident.Ident)
+		}
+	}
+	sort.Stable(LintErrors(l.errs))
+	return l.errs, nil
+}
+
+type linter struct {
+	// Types of vars with literal values.
+	types map[string]ValueType
+	// Set of referenced vars.
+	used  map[string]bool
+	funcs Funcs
+	errs  []LintError
+}
+
+func (l *linter) errorf(n Node, format string, args ...interface{}) {
+	l.errs = append(l.errs, NewLintError(n, format, args...))
+}
+
+// Walk the AST marking used vars and type checking lambda expressions.
+func (l *linter) walk(n Node) {
+	switch node := n.(type) {
+	case *IdentifierNode:
+		l.used[node.Ident] = true
+	case *UnaryNode:
+		l.walk(node.Node)
+	case *BinaryNode:
+		if node.Operator == TokenAsgn {
+			// Do not count the declaration as a use.
+			l.walk(node.Right)
+			return
+		}
+		l.walk(node.Left)
+		l.walk(node.Right)
+	case *FunctionNode:
+		for _, arg := range node.Args {
+			l.walk(arg)
+		}
+	case *LambdaNode:
+		l.walk(node.Node)
+		l.typeOf(node.Node)
+	case *ListNode:
+		for _, n := range node.Nodes {
+			l.walk(n)
+		}
+	case *ListLiteralNode:
+		for _, e := range node.Elements {
+			l.walk(e)
+		}
+	}
+}
+
+// Determine the type of a lambda expression, reporting any type errors.
+// InvalidType is returned if the type cannot be known before the expression
+// is evaluated, i.e. it depends on a field value.
+func (l *linter) typeOf(n Node) ValueType {
+	switch node := n.(type) {
+	case *BoolNode:
+		return TBool
+	case *NumberNode:
+		if node.IsInt {
+			return TInt
+		}
+		return TFloat
+	case *DurationNode:
+		return TDuration
+	case *StringNode:
+		return TString
+	case *RegexNode:
+		return TRegex
+	case *IdentifierNode:
+		return l.types[node.Ident]
+	case *FunctionNode:
+		for _, arg := range node.Args {
+			l.typeOf(arg)
+		}
+		if _, ok := l.funcs[node.Func]; !ok {
+			l.errorf(node, "undefined function %s", node.Func)
+			return InvalidType
+		}
+		if t, ok := funcTypes[node.Func]; ok {
+			return t
+		}
+		// All other functions are math functions.
+		return TFloat
+	case *UnaryNode:
+		t := l.typeOf(node.Node)
+		switch node.Operator {
+		case TokenMinus:
+			if t != InvalidType && t != TInt && t != TFloat {
+				l.errorf(node, "invalid argument to '-' of type %s", t)
+				return InvalidType
+			}
+		case TokenNot:
+			if t != InvalidType && t != TBool {
+				l.errorf(node, "invalid argument to '!' of type %s", t)
+			}
+			return TBool
+		}
+		return t
+	case *BinaryNode:
+		return l.binaryType(node)
+	}
+	return InvalidType
+}
+
+func (l *linter) binaryType(node *BinaryNode) ValueType {
+	lt := l.typeOf(node.Left)
+	rt := l.typeOf(node.Right)
+	op := node.Operator
+	mismatched := func() {
+		l.errorf(node, "mismatched type to binary operator. got %s %v %s", typeName(lt), op, typeName(rt))
+	}
+	switch {
+	case isMathOperator(op):
+		if lt == InvalidType && rt == InvalidType {
+			return InvalidType
+		}
+		if lt == InvalidType || rt == InvalidType {
+			// Only one side is known, it must be a number.
+			t := lt
+			if t == InvalidType {
+				t = rt
+			}
+			if t != TInt && t != TFloat {
+				mismatched()
+				return InvalidType
+			}
+			return t
+		}
+		if lt != rt || (lt != TInt && lt != TFloat) || (lt == TFloat && op == TokenMod) {
+			mismatched()
+			return InvalidType
+		}
+		return lt
+	case op == TokenAnd || op == TokenOr:
+		if (lt != InvalidType && lt != TBool) || (rt != InvalidType && rt != TBool) {
+			mismatched()
+		}
+	case op == TokenIn || op == TokenNotIn:
+		list, ok := node.Right.(*ListLiteralNode)
+		if !ok {
+			if rt != InvalidType {
+				l.errorf(node, "mismatched type to binary operator. got %s %v %s", typeName(lt), op, typeName(rt))
+			}
+			break
+		}
+		if lt == TRegex || lt == TDuration {
+			l.errorf(node, "mismatched type to binary operator. got %s %v list", typeName(lt), op)
+		}
+		for _, e := range list.Elements {
+			switch et := l.typeOf(e); et {
+			case TRegex, TDuration:
+				l.errorf(e, "invalid list element of type %s", et)
+			}
+		}
+	case op == TokenRegexEqual || op == TokenRegexNotEqual:
+		if (lt != InvalidType && lt != TString) || (rt != InvalidType && rt != TRegex) {
+			mismatched()
+		}
+	case isCompOperator(op):
+		if lt == InvalidType || rt == InvalidType {
+			if lt == TRegex || rt == TRegex || lt == TDuration || rt == TDuration {
+				mismatched()
+			}
+			break
+		}
+		numeric := (lt == TInt || lt == TFloat) && (rt == TInt || rt == TFloat)
+		ordered := op != TokenEqual && op != TokenNotEqual
+		switch {
+		case numeric:
+		case lt != rt, lt == TRegex, lt == TDuration, lt == TBool && ordered:
+			mismatched()
+		}
+	}
+	return TBool
+}
+
+// Name of a type, where InvalidType means the type is only known once evaluated.
+func typeName(t ValueType) string {
+	if t == InvalidType {
+		return "unknown"
+	}
+	return t.String()
+}
+
+// Sorts LintErrors by their position in the script.
+type LintErrors []LintError
+
+func (e LintErrors) Len() int      { return len(e) }
+func (e LintErrors) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e LintErrors) Less(i, j int) bool {
+	if e[i].Line == e[j].Line {
+		return e[i].Char < e[j].Char
+	}
+	return e[i].Line < e[j].Line
+}
@@ -0,0 +1,73 @@
+package tick
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		script string
+		errs   []LintError
+	}{
+		{
+			script: `var x = 5
+stream.where(lambda: "value" > x)`,
+		},
+		{
+			script: `var x = 5
+var y = 'unused'
+stream.where(lambda: "value" > x)`,
+			errs: []LintError{
+				{Line: 2, Char: 5, Msg: "var y is declared but never used"},
+			},
+		},
+		{
+			script: `stream.where(lambda: "value" > 1 AND 'a' > 1.0)`,
+			errs: []LintError{
+				{Line: 1, Char: 42, Msg: "mismatched type to binary operator. got string > float"},
+			},
+		},
+		{
+			script: `stream.where(lambda: "host" =~ 'a' OR 10s)`,
+			errs: []LintError{
+				{Line: 1, Char: 29, Msg: "mismatched type to binary operator. got unknown =~ string"},
+				{Line: 1, Char: 36, Msg: "mismatched type to binary operator. got bool OR duration"},
+			},
+		},
+		{
+			script: `stream.eval(lambda: "value" + 1.5 % 2.0, lambda: nofunc("value"))`,
+			errs: []LintError{
+				{Line: 1, Char: 35, Msg: "mismatched type to binary operator. got float % float"},
+				{Line: 1, Char: 50, Msg: "undefined function nofunc"},
+			},
+		},
+		{
+			script: `var r = /^a/
+stream.where(lambda: !"value" AND int("x") == 1 AND "host" !~ r)`,
+		},
+		{
+			script: `var hosts = ['a', 'b']
+stream.where(lambda: "host" IN hosts AND "cpu" NOT IN [1, 2.5, -3])`,
+		},
+		{
+			script: `var d = 5m
+var r = /^a/
+stream.where(lambda: "host" IN [d] OR r NOT IN ['a'])`,
+			errs: []LintError{
+				{Line: 3, Char: 33, Msg: "invalid list element of type duration"},
+				{Line: 3, Char: 41, Msg: "mismatched type to binary operator. got regex NOT IN list"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		errs, err := Lint(tc.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(errs, tc.errs) {
+			t.Errorf("unexpected lint errors for %q:\ngot %v\nexp %v", tc.script, errs, tc.errs)
+		}
+	}
+}
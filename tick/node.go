@@ -15,19 +15,43 @@ type unboundFunc func(obj interface{}) (interface{}, error)
 type Node interface {
 	String() string
 	Position() int // byte position of start of node in full original input string
+	Line() int     // line number of the start of the node, starting at 1
+	Char() int     // character within the line of the start of the node, starting at 1
 }
 
-type pos int
+// The position of a node in the original input string.
+type position struct {
+	pos  int
+	line int
+	char int
+}
+
+func (p position) Position() int {
+	return p.pos
+}
 
-func (p pos) Position() int {
-	return int(p)
+func (p position) Line() int {
+	return p.line
+}
+
+func (p position) Char() int {
+	return p.char
+}
+
+// Get the position of any node.
+func positionOf(n Node) position {
+	return position{
+		pos:  n.Position(),
+		line: n.Line(),
+		char: n.Char(),
+	}
 }
 
 // numberNode holds a number: signed or unsigned integer or float.
 // The value is parsed and stored under all the types that can represent the value.
 // This simulates in a small amount of code the behavior of Go's ideal constants.
 type NumberNode struct {
-	pos
+	position
 	IsInt   bool    // Number has an integral value.
 	IsFloat bool    // Number has a floating-point value.
 	Int64   int64   // The integer value.
@@ -35,9 +59,9 @@ type NumberNode struct {
 }
 
 // create a new number from a text string
-func newNumber(p int, text string) (*NumberNode, error) {
+func newNumber(p position, text string) (*NumberNode, error) {
 	n := &NumberNode{
-		pos: pos(p),
+		position: p,
 	}
 	i, err := strconv.ParseInt(text, 10, 64)
 	if err == nil {
@@ -73,14 +97,14 @@ func (n *NumberNode) String() string {
 // The value is parsed and stored under all the types that can represent the value.
 // This simulates in a small amount of code the behavior of Go's ideal constants.
 type DurationNode struct {
-	pos
+	position
 	Dur time.Duration //the duration
 }
 
 // create a new number from a text string
-func newDur(p int, text string) (*DurationNode, error) {
+func newDur(p position, text string) (*DurationNode, error) {
 	n := &DurationNode{
-		pos: pos(p),
+		position: p,
 	}
 	d, err := influxql.ParseDuration(text)
 	if err != nil {
@@ -96,18 +120,18 @@ func (d *DurationNode) String() string {
 
 // boolNode holds one argument and an operator.
 type BoolNode struct {
-	pos
+	position
 	Bool bool
 }
 
-func newBool(p int, text string) (*BoolNode, error) {
+func newBool(p position, text string) (*BoolNode, error) {
 	b, err := strconv.ParseBool(text)
 	if err != nil {
 		return nil, err
 	}
 	return &BoolNode{
-		pos:  pos(p),
-		Bool: b,
+		position: p,
+		Bool:     b,
 	}, nil
 }
 
@@ -117,16 +141,16 @@ func (b *BoolNode) String() string {
 
 // unaryNode holds one argument and an operator.
 type UnaryNode struct {
-	pos
+	position
 	Node     Node
 	Operator tokenType
 }
 
-func newUnary(operator token, n Node) *UnaryNode {
+func newUnary(p position, operator tokenType, n Node) *UnaryNode {
 	return &UnaryNode{
-		pos:      pos(operator.pos),
+		position: p,
 		Node:     n,
-		Operator: operator.typ,
+		Operator: operator,
 	}
 }
 
@@ -136,18 +160,18 @@ func (u *UnaryNode) String() string {
 
 // binaryNode holds two arguments and an operator.
 type BinaryNode struct {
-	pos
+	position
 	Left     Node
 	Right    Node
 	Operator tokenType
 }
 
-func newBinary(operator token, left, right Node) *BinaryNode {
+func newBinary(p position, operator tokenType, left, right Node) *BinaryNode {
 	return &BinaryNode{
-		pos:      pos(operator.pos),
+		position: p,
 		Left:     left,
 		Right:    right,
-		Operator: operator.typ,
+		Operator: operator,
 	}
 }
 
@@ -157,14 +181,14 @@ func (b *BinaryNode) String() string {
 
 //Holds the textual representation of an identifier
 type IdentifierNode struct {
-	pos
+	position
 	Ident string // The identifier
 }
 
-func newIdent(p int, ident string) *IdentifierNode {
+func newIdent(p position, ident string) *IdentifierNode {
 	return &IdentifierNode{
-		pos:   pos(p),
-		Ident: ident,
+		position: p,
+		Ident:    ident,
 	}
 }
 
@@ -174,11 +198,11 @@ func (i *IdentifierNode) String() string {
 
 //Holds the textual representation of an identifier
 type ReferenceNode struct {
-	pos
+	position
 	Reference string // The field reference
 }
 
-func newReference(p int, txt string) *ReferenceNode {
+func newReference(p position, txt string) *ReferenceNode {
 	// Remove leading and trailing quotes
 	literal := txt[1 : len(txt)-1]
 	// Unescape quotes
@@ -196,7 +220,7 @@ func newReference(p int, txt string) *ReferenceNode {
 	literal = buf.String()
 
 	return &ReferenceNode{
-		pos:       pos(p),
+		position:  p,
 		Reference: literal,
 	}
 }
@@ -207,11 +231,11 @@ func (r *ReferenceNode) String() string {
 
 //Holds the textual representation of a string literal
 type StringNode struct {
-	pos
+	position
 	Literal string // The string literal
 }
 
-func newString(p int, txt string) *StringNode {
+func newString(p position, txt string) *StringNode {
 
 	// Remove leading and trailing quotes
 	var literal string
@@ -236,8 +260,8 @@ func newString(p int, txt string) *StringNode {
 	}
 
 	return &StringNode{
-		pos:     pos(p),
-		Literal: literal,
+		position: p,
+		Literal:  literal,
 	}
 }
 
@@ -247,11 +271,11 @@ func (s *StringNode) String() string {
 
 //Holds the textual representation of a regex literal
 type RegexNode struct {
-	pos
+	position
 	Regex *regexp.Regexp
 }
 
-func newRegex(p int, txt string) (*RegexNode, error) {
+func newRegex(p position, txt string) (*RegexNode, error) {
 
 	// Remove leading and trailing quotes
 	literal := txt[1 : len(txt)-1]
@@ -275,8 +299,8 @@ func newRegex(p int, txt string) (*RegexNode, error) {
 	}
 
 	return &RegexNode{
-		pos:   pos(p),
-		Regex: r,
+		position: p,
+		Regex:    r,
 	}, nil
 }
 
@@ -286,12 +310,12 @@ func (s *RegexNode) String() string {
 
 // Represents a standalone '*' token.
 type StarNode struct {
-	pos
+	position
 }
 
-func newStar(p int) *StarNode {
+func newStar(p position) *StarNode {
 	return &StarNode{
-		pos: pos(p),
+		position: p,
 	}
 }
 
@@ -301,16 +325,17 @@ func (s *StarNode) String() string {
 
 //Holds the a function call with its args
 type FunctionNode struct {
-	pos
-	Func string // The identifier
-	Args []Node
+	position
+	Func    string // The identifier
+	Args    []Node
+	Comment *CommentNode // Comments preceding the function in a chain, may be nil
 }
 
-func newFunc(p int, ident string, args []Node) *FunctionNode {
+func newFunc(p position, ident string, args []Node) *FunctionNode {
 	return &FunctionNode{
-		pos:  pos(p),
-		Func: ident,
-		Args: args,
+		position: p,
+		Func:     ident,
+		Args:     args,
 	}
 }
 
@@ -320,14 +345,14 @@ func (f *FunctionNode) String() string {
 
 // Represents the begining of a lambda expression
 type LambdaNode struct {
-	pos
+	position
 	Node Node
 }
 
-func newLambda(p int, node Node) *LambdaNode {
+func newLambda(p position, node Node) *LambdaNode {
 	return &LambdaNode{
-		pos:  pos(p),
-		Node: node,
+		position: p,
+		Node:     node,
 	}
 }
 
@@ -335,15 +360,33 @@ func (l *LambdaNode) String() string {
 	return fmt.Sprintf("LambdaNode@%d{%v}", l.pos, l.Node)
 }
 
+// Holds a list literal, i.e. ['a', 'b'].
+// The elements are literals or identifiers of vars.
+type ListLiteralNode struct {
+	position
+	Elements []Node
+}
+
+func newListLiteral(p position, elements []Node) *ListLiteralNode {
+	return &ListLiteralNode{
+		position: p,
+		Elements: elements,
+	}
+}
+
+func (l *ListLiteralNode) String() string {
+	return fmt.Sprintf("ListLiteralNode@%d{%v}", l.pos, l.Elements)
+}
+
 //Holds a function call with its args
 type ListNode struct {
-	pos
+	position
 	Nodes []Node
 }
 
-func newList(p int) *ListNode {
+func newList(p position) *ListNode {
 	return &ListNode{
-		pos: pos(p),
+		position: p,
 	}
 }
 
@@ -354,3 +397,20 @@ func (l *ListNode) Add(n Node) {
 func (l *ListNode) String() string {
 	return fmt.Sprintf("ListNode@%d{%v}", l.pos, l.Nodes)
 }
+
+// Holds the text of consecutive line comments, without the leading '//'.
+type CommentNode struct {
+	position
+	Comments []string
+}
+
+func newComment(p position, comments []string) *CommentNode {
+	return &CommentNode{
+		position: p,
+		Comments: comments,
+	}
+}
+
+func (c *CommentNode) String() string {
+	return fmt.Sprintf("CommentNode@%d{%v}", c.pos, c.Comments)
+}
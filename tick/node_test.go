@@ -21,14 +21,14 @@ func TestnumberNode(t *testing.T) {
 	}
 
 	test := func(tc testCase) {
-		n, err := newNumber(tc.Pos, tc.Text)
+		n, err := newNumber(position{pos: tc.Pos}, tc.Text)
 		if tc.Err != nil {
 			assert.Equal(tc.Err, err)
 		} else {
 			if !assert.NotNil(n) {
 				t.FailNow()
 			}
-			assert.Equal(tc.Pos, int(n.pos))
+			assert.Equal(tc.Pos, n.Position())
 			assert.Equal(tc.IsInt, n.IsInt)
 			assert.Equal(tc.IsFloat, n.IsFloat)
 			assert.Equal(tc.Int64, n.Int64)
@@ -92,11 +92,11 @@ func TestNewBinaryNode(t *testing.T) {
 	}
 
 	test := func(tc testCase) {
-		n := newBinary(tc.Operator, tc.Left, tc.Right)
+		n := newBinary(position{pos: tc.Operator.pos}, tc.Operator.typ, tc.Left, tc.Right)
 		if !assert.NotNil(n) {
 			t.FailNow()
 		}
-		assert.Equal(tc.Operator.pos, int(n.pos))
+		assert.Equal(tc.Operator.pos, n.Position())
 		assert.Equal(tc.Left, n.Right)
 		assert.Equal(tc.Right, n.Left)
 		assert.Equal(tc.Operator.typ, n.Operator)
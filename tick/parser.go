@@ -15,6 +15,7 @@ type parser struct {
 	lex       *lexer
 	token     [2]token //two-token lookahead for parser
 	peekCount int
+	comments  []token // comments read but not yet added to the tree
 }
 
 // parse returns a Node, created by parsing the DSL described in the
@@ -38,7 +39,7 @@ func (p *parser) next() token {
 	if p.peekCount > 0 {
 		p.peekCount--
 	} else {
-		p.token[0], _ = p.lex.nextToken()
+		p.token[0] = p.nextToken()
 	}
 	return p.token[p.peekCount]
 }
@@ -55,20 +56,74 @@ func (p *parser) peek() token {
 	}
 	p.peekCount = 1
 	p.token[1] = p.token[0]
-	p.token[0], _ = p.lex.nextToken()
+	p.token[0] = p.nextToken()
 	return p.token[0]
 }
 
+// nextToken returns the next token from the lexer that is not a comment.
+// Comments are kept until they can be added to the tree.
+func (p *parser) nextToken() token {
+	for {
+		tok, _ := p.lex.nextToken()
+		if tok.typ != TokenComment {
+			return tok
+		}
+		p.comments = append(p.comments, tok)
+	}
+}
+
+// commentGroups returns the pending comments, split into groups
+// of comments on consecutive lines.
+func (p *parser) commentGroups() []*CommentNode {
+	var groups []*CommentNode
+	var c *CommentNode
+	for _, tok := range p.comments {
+		pos := p.position(tok)
+		if c == nil || pos.line > c.line+len(c.Comments) {
+			c = newComment(pos, nil)
+			groups = append(groups, c)
+		}
+		c.Comments = append(c.Comments, commentText(tok.val))
+	}
+	p.comments = nil
+	return groups
+}
+
+// comment returns all pending comments as a single node, or nil if there are none.
+func (p *parser) comment() *CommentNode {
+	if len(p.comments) == 0 {
+		return nil
+	}
+	c := newComment(p.position(p.comments[0]), nil)
+	for _, tok := range p.comments {
+		c.Comments = append(c.Comments, commentText(tok.val))
+	}
+	p.comments = nil
+	return c
+}
+
+// commentText strips the leading '//' and a single space from a comment.
+func commentText(comment string) string {
+	text := strings.TrimPrefix(comment, "//")
+	text = strings.TrimPrefix(text, " ")
+	return strings.TrimRight(text, " \t\r")
+}
+
 // errorf formats the error and terminates processing.
-func (p *parser) errorf(format string, args ...interface{}) {
+func (p *parser) errorf(pos int, format string, args ...interface{}) {
 	p.Root = nil
-	format = fmt.Sprintf("parser: %s", format)
-	panic(fmt.Errorf(format, args...))
+	line, char := p.lex.lineNumber(pos)
+	panic(&Error{
+		Line:    line,
+		Char:    char,
+		Msg:     fmt.Sprintf(format, args...),
+		Snippet: snippet(p.Text, line),
+	})
 }
 
 // error terminates processing.
-func (p *parser) error(err error) {
-	p.errorf("%s", err)
+func (p *parser) error(pos int, err error) {
+	p.errorf(pos, "%s", err)
 }
 
 // expect consumes the next token and guarantees it has the required type.
@@ -82,24 +137,6 @@ func (p *parser) expect(expected tokenType) token {
 
 // unexpected complains about the token and terminates processing.
 func (p *parser) unexpected(tok token, expected ...tokenType) {
-	const bufSize = 10
-	start := tok.pos - bufSize
-	if start < 0 {
-		start = 0
-	}
-	// Skip any new lines just show a single line
-	if i := strings.LastIndexByte(p.Text[start:tok.pos], '\n'); i != -1 {
-		start = start + i + 1
-	}
-	stop := tok.pos + bufSize
-	if stop > len(p.Text) {
-		stop = len(p.Text)
-	}
-	// Skip any new lines just show a single line
-	if i := strings.IndexByte(p.Text[tok.pos:stop], '\n'); i != -1 {
-		stop = tok.pos + i
-	}
-	line, char := p.lex.lineNumber(tok.pos)
 	expectedStrs := make([]string, len(expected))
 	for i := range expected {
 		expectedStrs[i] = fmt.Sprintf("%q", expected[i])
@@ -109,7 +146,12 @@ func (p *parser) unexpected(tok token, expected ...tokenType) {
 	if tok.typ == TokenError {
 		tokStr = tok.val
 	}
-	p.errorf("unexpected %s line %d char %d in \"%s\". expected: %s", tokStr, line, char, p.Text[start:stop], expectedStr)
+	p.errorf(tok.pos, "unexpected %s. expected: %s", tokStr, expectedStr)
+}
+
+// position returns the position of the token in the input.
+func (p *parser) position(tok token) position {
+	return p.lex.position(tok.pos)
 }
 
 // recover is the handler that turns panics into returns from the top level of Parse.
@@ -155,9 +197,14 @@ func (p *parser) parse() {
 
 //parse a complete program
 func (p *parser) program() Node {
-	l := newList(p.peek().pos)
+	l := newList(p.position(p.peek()))
 	for {
-		switch p.peek().typ {
+		// peek first so the comments preceding the statement have been read.
+		typ := p.peek().typ
+		for _, c := range p.commentGroups() {
+			l.Add(c)
+		}
+		switch typ {
 		case TokenEOF:
 			return l
 		default:
@@ -183,14 +230,14 @@ func (p *parser) declaration() Node {
 	v := p.vr()
 	op := p.expect(TokenAsgn)
 	b := p.expression()
-	return newBinary(op, v, b)
+	return newBinary(p.position(op), op.typ, v, b)
 }
 
 //parse a 'var ident' expression
 func (p *parser) vr() Node {
 	p.expect(TokenVar)
 	ident := p.expect(TokenIdent)
-	return newIdent(ident.pos, ident.val)
+	return newIdent(p.position(ident), ident.val)
 }
 
 //parse an expression
@@ -210,7 +257,11 @@ func (p *parser) chain(lhs Node) Node {
 	for look := p.peek().typ; look == TokenDot; look = p.peek().typ {
 		op := p.next()
 		rhs := p.funcOrIdent()
-		lhs = newBinary(op, lhs, rhs)
+		if f, ok := rhs.(*FunctionNode); ok {
+			// Keep the comments preceding the link with the function.
+			f.Comment = p.comment()
+		}
+		lhs = newBinary(p.position(op), op.typ, lhs, rhs)
 	}
 	return lhs
 }
@@ -230,7 +281,7 @@ func (p *parser) funcOrIdent() (n Node) {
 //parse an identifier
 func (p *parser) identifier() Node {
 	ident := p.expect(TokenIdent)
-	n := newIdent(ident.pos, ident.val)
+	n := newIdent(p.position(ident), ident.val)
 	return n
 }
 
@@ -241,7 +292,7 @@ func (p *parser) function() Node {
 	args := p.parameters()
 	p.expect(TokenRParen)
 
-	n := newFunc(ident.pos, ident.val, args)
+	n := newFunc(p.position(ident), ident.val, args)
 	return n
 }
 
@@ -266,7 +317,7 @@ func (p *parser) parameter() (n Node) {
 	case TokenLambda:
 		lambda := p.next()
 		l := p.lambdaExpr()
-		n = newLambda(lambda.pos, l)
+		n = newLambda(p.position(lambda), l)
 	default:
 		n = p.primary()
 	}
@@ -286,6 +337,8 @@ var precedence = [...]int{
 	TokenNotEqual:      2,
 	TokenRegexEqual:    2,
 	TokenRegexNotEqual: 2,
+	TokenIn:            2,
+	TokenNotIn:         2,
 	TokenGreater:       3,
 	TokenGreaterEqual:  3,
 	TokenLess:          3,
@@ -310,7 +363,7 @@ func (p *parser) precedence(lhs Node, minP int) Node {
 			rhs = p.precedence(rhs, precedence[look.typ])
 			look = p.peek()
 		}
-		lhs = newBinary(op, lhs, rhs)
+		lhs = newBinary(p.position(op), op.typ, lhs, rhs)
 	}
 	return lhs
 }
@@ -322,7 +375,7 @@ func (p *parser) lfunction() Node {
 	args := p.lparameters()
 	p.expect(TokenRParen)
 
-	n := newFunc(ident.pos, ident.val, args)
+	n := newFunc(p.position(ident), ident.val, args)
 	return n
 }
 
@@ -380,7 +433,9 @@ func (p *parser) primary() Node {
 		return p.identifier()
 	case tok.typ == TokenMinus, tok.typ == TokenNot:
 		p.next()
-		return newUnary(tok, p.primary())
+		return newUnary(p.position(tok), tok.typ, p.primary())
+	case tok.typ == TokenLBracket:
+		return p.listLiteral()
 	default:
 		p.unexpected(
 			tok,
@@ -392,6 +447,7 @@ func (p *parser) primary() Node {
 			TokenFalse,
 			TokenEqual,
 			TokenLParen,
+			TokenLBracket,
 			TokenMinus,
 			TokenNot,
 		)
@@ -399,12 +455,56 @@ func (p *parser) primary() Node {
 	}
 }
 
+// parse a list literal
+func (p *parser) listLiteral() Node {
+	lb := p.expect(TokenLBracket)
+	var elements []Node
+	for p.peek().typ != TokenRBracket {
+		elements = append(elements, p.listElement())
+		if p.peek().typ != TokenComma {
+			break
+		}
+		p.next()
+	}
+	p.expect(TokenRBracket)
+	return newListLiteral(p.position(lb), elements)
+}
+
+// parse an element of a list literal, only literals and vars are allowed.
+func (p *parser) listElement() Node {
+	switch tok := p.peek(); tok.typ {
+	case TokenNumber:
+		return p.number()
+	case TokenString:
+		return p.string()
+	case TokenTrue, TokenFalse:
+		return p.boolean()
+	case TokenIdent:
+		return p.identifier()
+	case TokenMinus:
+		p.next()
+		return newUnary(p.position(tok), tok.typ, p.number())
+	default:
+		p.unexpected(
+			tok,
+			TokenNumber,
+			TokenString,
+			TokenTrue,
+			TokenFalse,
+			TokenIdent,
+			TokenMinus,
+			TokenRBracket,
+		)
+		return nil
+	}
+}
+
 //parse a duration literal
 func (p *parser) duration() Node {
 	token := p.expect(TokenDuration)
-	num, err := newDur(token.pos, token.val)
+	num, err := newDur(p.position(token), token.val)
 	if err != nil {
-		p.error(err)
+		p.error(token.pos, err)
 	}
 	return num
 }
@@ -412,9 +512,9 @@ func (p *parser) duration() Node {
 //parse a number literal
 func (p *parser) number() Node {
 	token := p.expect(TokenNumber)
-	num, err := newNumber(token.pos, token.val)
+	num, err := newNumber(p.position(token), token.val)
 	if err != nil {
-		p.error(err)
+		p.error(token.pos, err)
 	}
 	return num
 }
@@ -422,16 +522,16 @@ func (p *parser) number() Node {
 //parse a string literal
 func (p *parser) string() Node {
 	token := p.expect(TokenString)
-	s := newString(token.pos, token.val)
+	s := newString(p.position(token), token.val)
 	return s
 }
 
 //parse a regex literal
 func (p *parser) regex() Node {
 	token := p.expect(TokenRegex)
-	r, err := newRegex(token.pos, token.val)
+	r, err := newRegex(p.position(token), token.val)
 	if err != nil {
-		p.error(err)
+		p.error(token.pos, err)
 	}
 	return r
 }
@@ -439,21 +539,21 @@ func (p *parser) regex() Node {
 // parse '*' literal
 func (p *parser) star() Node {
 	tok := p.expect(TokenMult)
-	return newStar(tok.pos)
+	return newStar(p.position(tok))
 }
 
 //parse a reference literal
 func (p *parser) reference() Node {
 	token := p.expect(TokenReference)
-	r := newReference(token.pos, token.val)
+	r := newReference(p.position(token), token.val)
 	return r
 }
 
 func (p *parser) boolean() Node {
 	n := p.next()
-	num, err := newBool(n.pos, n.val)
+	num, err := newBool(p.position(n), n.val)
 	if err != nil {
-		p.error(err)
+		p.error(n.pos, err)
 	}
 	return num
 }
@@ -47,15 +47,15 @@ func TestParseErrors(t *testing.T) {
 	cases := []testCase{
 		testCase{
 			Text:  "a\n\n\nvar b = ",
-			Error: `parser: unexpected EOF line 4 char 9 in "var b = ". expected: "number","string","duration","identifier","TRUE","FALSE","==","(","-","!"`,
+			Error: "4:9: unexpected EOF. expected: \"number\",\"string\",\"duration\",\"identifier\",\"TRUE\",\"FALSE\",\"==\",\"(\",\"[\",\"-\",\"!\"\nvar b = \n        ^",
 		},
 		testCase{
 			Text:  "a\n\n\nvar b = stream.window()var period)\n\nvar x = 1",
-			Error: `parser: unexpected ) line 4 char 34 in "var period)". expected: "="`,
+			Error: "4:34: unexpected ). expected: \"=\"\nvar b = stream.window()var period)\n                                 ^",
 		},
 		testCase{
 			Text:  "a\n\n\nvar b = stream.window(\nb.period(10s)",
-			Error: `parser: unexpected EOF line 5 char 14 in "eriod(10s)". expected: ")"`,
+			Error: "5:14: unexpected EOF. expected: \")\"\nb.period(10s)\n             ^",
 		},
 	}
 
@@ -130,17 +130,18 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = 'str'`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &StringNode{
-							pos:     8,
-							Literal: "str",
+							position: position{pos: 8, line: 1, char: 9},
+							Literal:  "str",
 						},
 					},
 				},
@@ -149,17 +150,18 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = TRUE`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &BoolNode{
-							pos:  8,
-							Bool: true,
+							position: position{pos: 8, line: 1, char: 9},
+							Bool:     true,
 						},
 					},
 				},
@@ -168,20 +170,21 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = !FALSE`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &UnaryNode{
-							pos:      8,
+							position: position{pos: 8, line: 1, char: 9},
 							Operator: TokenNot,
 							Node: &BoolNode{
-								pos:  9,
-								Bool: false,
+								position: position{pos: 9, line: 1, char: 10},
+								Bool:     false,
 							},
 						},
 					},
@@ -191,18 +194,19 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = 1`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &NumberNode{
-							pos:   8,
-							IsInt: true,
-							Int64: 1,
+							position: position{pos: 8, line: 1, char: 9},
+							IsInt:    true,
+							Int64:    1,
 						},
 					},
 				},
@@ -211,21 +215,22 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = -1`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &UnaryNode{
-							pos:      8,
+							position: position{pos: 8, line: 1, char: 9},
 							Operator: TokenMinus,
 							Node: &NumberNode{
-								pos:   9,
-								IsInt: true,
-								Int64: 1,
+								position: position{pos: 9, line: 1, char: 10},
+								IsInt:    true,
+								Int64:    1,
 							},
 						},
 					},
@@ -235,18 +240,19 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = 1.0`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &NumberNode{
-							pos:     8,
-							IsFloat: true,
-							Float64: 1.0,
+							position: position{pos: 8, line: 1, char: 9},
+							IsFloat:  true,
+							Float64:  1.0,
 						},
 					},
 				},
@@ -255,21 +261,22 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = -1.0`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &UnaryNode{
-							pos:      8,
+							position: position{pos: 8, line: 1, char: 9},
 							Operator: TokenMinus,
 							Node: &NumberNode{
-								pos:     9,
-								IsFloat: true,
-								Float64: 1.0,
+								position: position{pos: 9, line: 1, char: 10},
+								IsFloat:  true,
+								Float64:  1.0,
 							},
 						},
 					},
@@ -279,17 +286,18 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = 5h`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &DurationNode{
-							pos: 8,
-							Dur: time.Hour * 5,
+							position: position{pos: 8, line: 1, char: 9},
+							Dur:      time.Hour * 5,
 						},
 					},
 				},
@@ -298,20 +306,21 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = -5h`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &UnaryNode{
-							pos:      8,
+							position: position{pos: 8, line: 1, char: 9},
 							Operator: TokenMinus,
 							Node: &DurationNode{
-								pos: 9,
-								Dur: time.Hour * 5,
+								position: position{pos: 9, line: 1, char: 10},
+								Dur:      time.Hour * 5,
 							},
 						},
 					},
@@ -321,17 +330,18 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = /.*\//`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &RegexNode{
-							pos:   8,
-							Regex: regexp.MustCompile(".*/"),
+							position: position{pos: 8, line: 1, char: 9},
+							Regex:    regexp.MustCompile(".*/"),
 						},
 					},
 				},
@@ -340,24 +350,25 @@ func TestParseStatements(t *testing.T) {
 		{
 			script: `var x = a.f()`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "x",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "x",
 						},
 						Right: &BinaryNode{
-							pos:      9,
+							position: position{pos: 9, line: 1, char: 10},
 							Operator: TokenDot,
 							Left: &IdentifierNode{
-								pos:   8,
-								Ident: "a",
+								position: position{pos: 8, line: 1, char: 9},
+								Ident:    "a",
 							},
 							Right: &FunctionNode{
-								pos:  10,
-								Func: "f",
+								position: position{pos: 10, line: 1, char: 11},
+								Func:     "f",
 							},
 						},
 					},
@@ -369,43 +380,44 @@ func TestParseStatements(t *testing.T) {
 			stream.where(lambda: "value" > t)
 			`,
 			Root: &ListNode{
+				position: position{pos: 0, line: 1, char: 1},
 				Nodes: []Node{
 					&BinaryNode{
-						pos:      6,
+						position: position{pos: 6, line: 1, char: 7},
 						Operator: TokenAsgn,
 						Left: &IdentifierNode{
-							pos:   4,
-							Ident: "t",
+							position: position{pos: 4, line: 1, char: 5},
+							Ident:    "t",
 						},
 						Right: &NumberNode{
-							pos:   8,
-							IsInt: true,
-							Int64: 42,
+							position: position{pos: 8, line: 1, char: 9},
+							IsInt:    true,
+							Int64:    42,
 						},
 					},
 					&BinaryNode{
-						pos:      20,
+						position: position{pos: 20, line: 2, char: 10},
 						Operator: TokenDot,
 						Left: &IdentifierNode{
-							pos:   14,
-							Ident: "stream",
+							position: position{pos: 14, line: 2, char: 4},
+							Ident:    "stream",
 						},
 						Right: &FunctionNode{
-							pos:  21,
-							Func: "where",
+							position: position{pos: 21, line: 2, char: 11},
+							Func:     "where",
 							Args: []Node{
 								&LambdaNode{
-									pos: 27,
+									position: position{pos: 27, line: 2, char: 17},
 									Node: &BinaryNode{
-										pos:      43,
+										position: position{pos: 43, line: 2, char: 33},
 										Operator: TokenGreater,
 										Left: &ReferenceNode{
-											pos:       35,
+											position:  position{pos: 35, line: 2, char: 25},
 											Reference: "value",
 										},
 										Right: &IdentifierNode{
-											pos:   45,
-											Ident: "t",
+											position: position{pos: 45, line: 2, char: 35},
+											Ident:    "t",
 										},
 									},
 								},
@@ -423,77 +435,77 @@ var x = stream
 		.every(1m)
 		.map(influxql.agg.mean('value'))`,
 			Root: &ListNode{
-				pos: 1,
+				position: position{pos: 1, line: 2, char: 1},
 				Nodes: []Node{&BinaryNode{
-					pos:      7,
+					position: position{pos: 7, line: 2, char: 7},
 					Operator: TokenAsgn,
 					Left: &IdentifierNode{
-						pos:   5,
-						Ident: "x",
+						position: position{pos: 5, line: 2, char: 5},
+						Ident:    "x",
 					},
 					Right: &BinaryNode{
-						pos:      57,
+						position: position{pos: 57, line: 6, char: 3},
 						Operator: TokenDot,
 						Left: &BinaryNode{
-							pos:      44,
+							position: position{pos: 44, line: 5, char: 3},
 							Operator: TokenDot,
 							Left: &BinaryNode{
-								pos:      30,
+								position: position{pos: 30, line: 4, char: 3},
 								Operator: TokenDot,
 								Left: &BinaryNode{
-									pos:      18,
+									position: position{pos: 18, line: 3, char: 3},
 									Operator: TokenDot,
 									Left: &IdentifierNode{
-										pos:   9,
-										Ident: "stream",
+										position: position{pos: 9, line: 2, char: 9},
+										Ident:    "stream",
 									},
 									Right: &FunctionNode{
-										pos:  19,
-										Func: "window",
+										position: position{pos: 19, line: 3, char: 4},
+										Func:     "window",
 									},
 								},
 								Right: &FunctionNode{
-									pos:  31,
-									Func: "period",
+									position: position{pos: 31, line: 4, char: 4},
+									Func:     "period",
 									Args: []Node{&DurationNode{
-										pos: 38,
-										Dur: 5 * time.Minute,
+										position: position{pos: 38, line: 4, char: 11},
+										Dur:      5 * time.Minute,
 									}},
 								},
 							},
 							Right: &FunctionNode{
-								pos:  45,
-								Func: "every",
+								position: position{pos: 45, line: 5, char: 4},
+								Func:     "every",
 								Args: []Node{&DurationNode{
-									pos: 51,
-									Dur: time.Minute,
+									position: position{pos: 51, line: 5, char: 10},
+									Dur:      time.Minute,
 								}},
 							},
 						},
 						Right: &FunctionNode{
-							pos:  58,
-							Func: "map",
+							position: position{pos: 58, line: 6, char: 4},
+							Func:     "map",
 							Args: []Node{&BinaryNode{
-								pos:      74,
+								position: position{pos: 74, line: 6, char: 20},
 								Operator: TokenDot,
 								Left: &BinaryNode{
-									pos:      70,
+									position: position{pos: 70, line: 6, char: 16},
 									Operator: TokenDot,
 									Left: &IdentifierNode{
-										pos:   62,
-										Ident: "influxql",
+										position: position{pos: 62, line: 6, char: 8},
+										Ident:    "influxql",
 									},
 									Right: &IdentifierNode{
-										pos:   71,
-										Ident: "agg",
+										position: position{pos: 71, line: 6, char: 17},
+										Ident:    "agg",
 									},
 								},
 								Right: &FunctionNode{
-									pos:  75,
-									Func: "mean",
+									position: position{pos: 75, line: 6, char: 21},
+									Func:     "mean",
 									Args: []Node{&StringNode{
-										pos:     80,
-										Literal: "value",
+										position: position{pos: 80, line: 6, char: 26},
+										Literal:  "value",
 									}},
 								},
 							}},
@@ -515,3 +527,44 @@ var x = stream
 		}
 	}
 }
+
+func TestParseComments(t *testing.T) {
+	script := `// a
+// b
+
+// c
+var x = stream
+	// d
+	.window()
+// e`
+	root, err := parse(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := root.(*ListNode)
+	if exp, got := 4, len(l.Nodes); got != exp {
+		t.Fatalf("unexpected number of statements: got %d exp %d", got, exp)
+	}
+	expComments := []struct {
+		i        int
+		line     int
+		comments []string
+	}{
+		{0, 1, []string{"a", "b"}},
+		{1, 4, []string{"c"}},
+		{3, 8, []string{"e"}},
+	}
+	for _, exp := range expComments {
+		c, ok := l.Nodes[exp.i].(*CommentNode)
+		if !ok {
+			t.Fatalf("expected comment node at %d got %v", exp.i, l.Nodes[exp.i])
+		}
+		if c.Line() != exp.line || !reflect.DeepEqual(c.Comments, exp.comments) {
+			t.Errorf("unexpected comment at %d: got %d %v exp %d %v", exp.i, c.Line(), c.Comments, exp.line, exp.comments)
+		}
+	}
+	f := l.Nodes[2].(*BinaryNode).Right.(*BinaryNode).Right.(*FunctionNode)
+	if f.Comment == nil || !reflect.DeepEqual(f.Comment.Comments, []string{"d"}) {
+		t.Errorf("unexpected function comment: got %v", f.Comment)
+	}
+}
@@ -2,6 +2,7 @@ package tick
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 )
 
@@ -12,6 +13,9 @@ type Scope struct {
 	variables map[string]interface{}
 
 	dynamicMethods map[string]DynamicMethod
+
+	// Function call nodes that created objects.
+	nodes map[interface{}]Node
 }
 
 //Initialize a new Scope object.
@@ -19,6 +23,7 @@ func NewScope() *Scope {
 	return &Scope{
 		variables:      make(map[string]interface{}),
 		dynamicMethods: make(map[string]DynamicMethod),
+		nodes:          make(map[interface{}]Node),
 	}
 }
 
@@ -46,3 +51,27 @@ func (s *Scope) SetDynamicMethod(name string, m DynamicMethod) {
 func (s *Scope) DynamicMethod(name string) DynamicMethod {
 	return s.dynamicMethods[name]
 }
+
+// Record the function call node that returned obj.
+// Only the first call is recorded, so chained property methods
+// that return their receiver keep the node of the creating call.
+// Only pointers are recorded.
+func (s *Scope) setNode(obj interface{}, n Node) {
+	if obj == nil || reflect.TypeOf(obj).Kind() != reflect.Ptr {
+		return
+	}
+	if _, ok := s.nodes[obj]; !ok {
+		s.nodes[obj] = n
+	}
+}
+
+// NodeOf returns the node of the function call in the script
+// that created obj during evaluation.
+// Use the node's Line and Char to get its position in the script.
+func (s *Scope) NodeOf(obj interface{}) (Node, bool) {
+	if obj == nil || reflect.TypeOf(obj).Kind() != reflect.Ptr {
+		return nil, false
+	}
+	n, ok := s.nodes[obj]
+	return n, ok
+}
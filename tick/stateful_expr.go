@@ -15,13 +15,47 @@ var ErrInvalidExpr = errors.New("expression is invalid, could not evaluate")
 type StatefulExpr struct {
 	Node  Node
 	Funcs Funcs
+
+	// Sets of values for the list literals used with IN and NOT IN.
+	sets map[*ListLiteralNode]valueSet
 }
 
 func NewStatefulExpr(n Node) *StatefulExpr {
-	return &StatefulExpr{
+	s := &StatefulExpr{
 		Node:  n,
 		Funcs: NewFunctions(),
+		sets:  make(map[*ListLiteralNode]valueSet),
+	}
+	s.buildSets(n)
+	return s
+}
+
+// Precompute the sets of all valid list literals in the expression.
+// Invalid lists are reported when the expression is evaluated.
+func (s *StatefulExpr) buildSets(n Node) {
+	switch node := n.(type) {
+	case *ListLiteralNode:
+		if set, err := newValueSet(node); err == nil {
+			s.sets[node] = set
+		}
+	case *UnaryNode:
+		s.buildSets(node.Node)
+	case *BinaryNode:
+		s.buildSets(node.Left)
+		s.buildSets(node.Right)
+	case *FunctionNode:
+		for _, arg := range node.Args {
+			s.buildSets(arg)
+		}
+	}
+}
+
+// Return the set of values for the list literal.
+func (s *StatefulExpr) set(l *ListLiteralNode) (valueSet, error) {
+	if set, ok := s.sets[l]; ok {
+		return set, nil
 	}
+	return newValueSet(l)
 }
 
 // Reset the state
@@ -43,14 +77,14 @@ func (s *StatefulExpr) EvalBool(scope *Scope) (bool, error) {
 		if ref, ok := value.(*ReferenceNode); ok {
 			value, err = scope.Get(ref.Reference)
 			if err != nil {
-				return false, err
+				return false, errorAt(ref, err)
 			}
 		}
 		b, ok := value.(bool)
 		if ok {
 			return b, nil
 		} else {
-			return false, fmt.Errorf("expression returned unexpected type %T", value)
+			return false, errorAt(s.Node, fmt.Errorf("expression returned unexpected type %T", value))
 		}
 	}
 	return false, ErrInvalidExpr
@@ -68,14 +102,14 @@ func (s *StatefulExpr) EvalNum(scope *Scope) (float64, error) {
 		if ref, ok := value.(*ReferenceNode); ok {
 			value, err = scope.Get(ref.Reference)
 			if err != nil {
-				return math.NaN(), err
+				return math.NaN(), errorAt(ref, err)
 			}
 		}
 		n, ok := value.(float64)
 		if ok {
 			return n, nil
 		} else {
-			return math.NaN(), fmt.Errorf("expression returned unexpected type %T", value)
+			return math.NaN(), errorAt(s.Node, fmt.Errorf("expression returned unexpected type %T", value))
 		}
 	}
 	return math.NaN(), ErrInvalidExpr
@@ -102,7 +136,10 @@ func (s *StatefulExpr) eval(n Node, scope *Scope, stck *stack) (err error) {
 		if err != nil {
 			return
 		}
-		s.evalUnary(node.Operator, scope, stck)
+		err = s.evalUnary(node.Operator, scope, stck)
+		if err != nil {
+			return errorAt(node, err)
+		}
 	case *BinaryNode:
 		err = s.eval(node.Left, scope, stck)
 		if err != nil {
@@ -114,7 +151,7 @@ func (s *StatefulExpr) eval(n Node, scope *Scope, stck *stack) (err error) {
 		}
 		err = s.evalBinary(node.Operator, scope, stck)
 		if err != nil {
-			return
+			return errorAt(node, err)
 		}
 	case *FunctionNode:
 		args := make([]interface{}, len(node.Args))
@@ -127,7 +164,7 @@ func (s *StatefulExpr) eval(n Node, scope *Scope, stck *stack) (err error) {
 			if r, ok := a.(*ReferenceNode); ok {
 				a, err = scope.Get(r.Reference)
 				if err != nil {
-					return err
+					return errorAt(r, err)
 				}
 			}
 			args[i] = a
@@ -135,11 +172,11 @@ func (s *StatefulExpr) eval(n Node, scope *Scope, stck *stack) (err error) {
 		// Call function
 		f := s.Funcs[node.Func]
 		if f == nil {
-			return fmt.Errorf("undefined function %s", node.Func)
+			return errorAt(node, fmt.Errorf("undefined function %s", node.Func))
 		}
 		ret, err := f.Call(args...)
 		if err != nil {
-			return fmt.Errorf("error calling %s: %s", node.Func, err)
+			return errorAt(node, fmt.Errorf("error calling %s: %s", node.Func, err))
 		}
 		stck.Push(ret)
 	default:
@@ -170,6 +207,38 @@ func (s *StatefulExpr) evalUnary(op tokenType, scope *Scope, stck *stack) error
 	return nil
 }
 
+// A set of the values of a list literal.
+// Numbers are stored as float64 so that ints and floats compare equal,
+// like they do for the other comparison operators.
+type valueSet map[interface{}]bool
+
+func newValueSet(l *ListLiteralNode) (valueSet, error) {
+	set := make(valueSet, len(l.Elements))
+	for _, e := range l.Elements {
+		v, ok := literalValue(e)
+		if !ok {
+			return nil, errorAt(e, errors.New("invalid list element, must be a literal"))
+		}
+		k, ok := setKey(v)
+		if !ok {
+			return nil, errorAt(e, fmt.Errorf("invalid list element of type %T", v))
+		}
+		set[k] = true
+	}
+	return set, nil
+}
+
+// Return the key of a value within a valueSet.
+func setKey(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case int64:
+		return float64(value), true
+	case float64, string, bool:
+		return value, true
+	}
+	return nil, false
+}
+
 func errMismatched(op tokenType, l, r interface{}) error {
 	return fmt.Errorf("mismatched type to binary operator. got %T %v %T. see bool(), int(), float()", l, op, r)
 }
@@ -192,6 +261,20 @@ func (s *StatefulExpr) evalBinary(op tokenType, scope *Scope, stck *stack) (err
 	}
 	var v interface{}
 	switch {
+	case op == TokenIn || op == TokenNotIn:
+		list, ok := r.(*ListLiteralNode)
+		if !ok {
+			return errMismatched(op, l, r)
+		}
+		set, err := s.set(list)
+		if err != nil {
+			return err
+		}
+		k, ok := setKey(l)
+		if !ok {
+			return errMismatched(op, l, r)
+		}
+		v = set[k] == (op == TokenIn)
 	case isMathOperator(op):
 		switch ln := l.(type) {
 		case int64:
@@ -0,0 +1,66 @@
+package tick
+
+import (
+	"testing"
+)
+
+func TestStatefulExpr_In(t *testing.T) {
+	testCases := []struct {
+		expr  string
+		value interface{}
+		exp   bool
+	}{
+		{expr: `"value" IN [1, 2.5, -3]`, value: 1.0, exp: true},
+		{expr: `"value" IN [1, 2.5, -3]`, value: int64(-3), exp: true},
+		{expr: `"value" IN [1, 2.5, -3]`, value: 2.0, exp: false},
+		{expr: `"value" NOT IN ['a', 'b']`, value: "a", exp: false},
+		{expr: `"value" NOT IN ['a', 'b']`, value: "c", exp: true},
+		{expr: `"value" IN [TRUE]`, value: true, exp: true},
+		{expr: `"value" IN []`, value: "a", exp: false},
+	}
+	for _, tc := range testCases {
+		root, err := parse("f(lambda: " + tc.expr + ")")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lambda := root.(*ListNode).Nodes[0].(*FunctionNode).Args[0].(*LambdaNode)
+		se := NewStatefulExpr(lambda.Node)
+		scope := NewScope()
+		scope.Set("value", tc.value)
+		got, err := se.EvalBool(scope)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if got != tc.exp {
+			t.Errorf("%s with value %v: got %v exp %v", tc.expr, tc.value, got, tc.exp)
+		}
+	}
+}
+
+func TestStatefulExpr_InErrors(t *testing.T) {
+	testCases := []struct {
+		expr string
+		exp  string
+	}{
+		{expr: `"value" IN [x]`, exp: "1:23: invalid list element, must be a literal"},
+		{expr: `"value" IN "other"`, exp: "1:19: mismatched type to binary operator. got string IN string. see bool(), int(), float()"},
+	}
+	for _, tc := range testCases {
+		root, err := parse("f(lambda: " + tc.expr + ")")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lambda := root.(*ListNode).Nodes[0].(*FunctionNode).Args[0].(*LambdaNode)
+		se := NewStatefulExpr(lambda.Node)
+		scope := NewScope()
+		scope.Set("value", "a")
+		scope.Set("other", "b")
+		_, err = se.EvalBool(scope)
+		if err == nil {
+			t.Fatalf("%s: expected error", tc.expr)
+		}
+		if got := err.Error(); got != tc.exp {
+			t.Errorf("%s: unexpected error:\ngot %q\nexp %q", tc.expr, got, tc.exp)
+		}
+	}
+}
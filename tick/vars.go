@@ -0,0 +1,254 @@
+package tick
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdb/influxdb/influxql"
+)
+
+// The type of a literal value in a TICKscript.
+type ValueType int
+
+const (
+	InvalidType ValueType = iota
+	TFloat
+	TInt
+	TString
+	TBool
+	TRegex
+	TDuration
+)
+
+var valueTypeNames = map[ValueType]string{
+	TFloat:    "float",
+	TInt:      "int",
+	TString:   "string",
+	TBool:     "bool",
+	TRegex:    "regex",
+	TDuration: "duration",
+}
+
+func (t ValueType) String() string {
+	if s, ok := valueTypeNames[t]; ok {
+		return s
+	}
+	return "invalid type"
+}
+
+// Parse the name of a type, i.e. 'int' or 'duration'.
+func ParseValueType(s string) (ValueType, error) {
+	for t, name := range valueTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	return InvalidType, fmt.Errorf("unknown type %q", s)
+}
+
+// Determine the ValueType of a Go value.
+func TypeOf(v interface{}) ValueType {
+	switch v.(type) {
+	case float64:
+		return TFloat
+	case int64:
+		return TInt
+	case string:
+		return TString
+	case bool:
+		return TBool
+	case *regexp.Regexp:
+		return TRegex
+	case time.Duration:
+		return TDuration
+	default:
+		return InvalidType
+	}
+}
+
+// A typed value for a variable declared in a TICKscript.
+// Vars are used to override the default values of the
+// declarations in a template script.
+type Var struct {
+	Type  ValueType
+	Value interface{}
+}
+
+// Create a new Var of the named type.
+// The value is converted to the Go type for the ValueType,
+// so loosely typed values, like those decoded from JSON, are accepted.
+// Durations and regexes can be given as strings.
+func NewVar(typ string, value interface{}) (Var, error) {
+	t, err := ParseValueType(typ)
+	if err != nil {
+		return Var{}, err
+	}
+	var v interface{}
+	switch t {
+	case TFloat:
+		switch value := value.(type) {
+		case float64:
+			v = value
+		case int64:
+			v = float64(value)
+		case int:
+			v = float64(value)
+		}
+	case TInt:
+		switch value := value.(type) {
+		case int64:
+			v = value
+		case int:
+			v = int64(value)
+		case float64:
+			if value == float64(int64(value)) {
+				v = int64(value)
+			}
+		}
+	case TString:
+		if s, ok := value.(string); ok {
+			v = s
+		}
+	case TBool:
+		if b, ok := value.(bool); ok {
+			v = b
+		}
+	case TRegex:
+		switch value := value.(type) {
+		case *regexp.Regexp:
+			v = value
+		case string:
+			r, err := regexp.Compile(value)
+			if err != nil {
+				return Var{}, err
+			}
+			v = r
+		}
+	case TDuration:
+		switch value := value.(type) {
+		case time.Duration:
+			v = value
+		case string:
+			d, err := influxql.ParseDuration(value)
+			if err != nil {
+				return Var{}, err
+			}
+			v = d
+		}
+	}
+	if v == nil {
+		return Var{}, fmt.Errorf("invalid value %v for type %s", value, t)
+	}
+	return Var{Type: t, Value: v}, nil
+}
+
+// Return the variables declared in the script that have literal default values.
+// Only these variables can be overridden via EvaluateWithVars.
+func DeclaredVars(script string) (map[string]Var, error) {
+	root, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]Var)
+	for _, d := range declarations(root) {
+		ident := d.Left.(*IdentifierNode)
+		if v, ok := literalValue(d.Right); ok {
+			vars[ident.Ident] = Var{Type: TypeOf(v), Value: v}
+		}
+	}
+	return vars, nil
+}
+
+// Return all top level declaration statements of the AST.
+func declarations(root Node) []*BinaryNode {
+	l, ok := root.(*ListNode)
+	if !ok {
+		return nil
+	}
+	var decls []*BinaryNode
+	for _, n := range l.Nodes {
+		b, ok := n.(*BinaryNode)
+		if !ok || b.Operator != TokenAsgn {
+			continue
+		}
+		if _, ok := b.Left.(*IdentifierNode); ok {
+			decls = append(decls, b)
+		}
+	}
+	return decls
+}
+
+// Get the value of a literal node.
+// Negated numbers and durations are considered literals.
+func literalValue(n Node) (interface{}, bool) {
+	switch node := n.(type) {
+	case *BoolNode:
+		return node.Bool, true
+	case *NumberNode:
+		if node.IsInt {
+			return node.Int64, true
+		}
+		return node.Float64, true
+	case *DurationNode:
+		return node.Dur, true
+	case *StringNode:
+		return node.Literal, true
+	case *RegexNode:
+		return node.Regex, true
+	case *UnaryNode:
+		if node.Operator != TokenMinus {
+			return nil, false
+		}
+		switch v, _ := literalValue(node.Node); v := v.(type) {
+		case int64:
+			return -v, true
+		case float64:
+			return -v, true
+		case time.Duration:
+			return -v, true
+		}
+	}
+	return nil, false
+}
+
+// Replace the default values of declarations in the AST with the values from vars.
+// The type of each var must match the type of the declared default.
+func applyVars(root Node, vars map[string]Var) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	found := make(map[string]bool, len(vars))
+	for _, d := range declarations(root) {
+		ident := d.Left.(*IdentifierNode)
+		v, ok := vars[ident.Ident]
+		if !ok {
+			continue
+		}
+		found[ident.Ident] = true
+		def, ok := literalValue(d.Right)
+		if !ok {
+			return errorAt(ident, fmt.Errorf("cannot override var %q, its default value is not a literal", ident.Ident))
+		}
+		if t := TypeOf(def); t != v.Type {
+			return errorAt(ident, fmt.Errorf("invalid type for var %q: got %s exp %s", ident.Ident, v.Type, t))
+		}
+		if TypeOf(v.Value) != v.Type {
+			return errorAt(ident, fmt.Errorf("invalid value for var %q: %v is not of type %s", ident.Ident, v.Value, v.Type))
+		}
+		d.Right = valueToLiteralNode(positionOf(d.Right), v.Value)
+	}
+	if len(found) != len(vars) {
+		var missing []string
+		for name := range vars {
+			if !found[name] {
+				missing = append(missing, name)
+			}
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("vars not declared in script: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package kapacitor
 
 import (
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,13 +16,39 @@ import (
 type WindowNode struct {
 	node
 	w *pipeline.WindowNode
+
+	statMap *expvar.Map
 }
 
 // Create a new  WindowNode, which windows data for a period of time and emits the window.
 func newWindowNode(et *ExecutingTask, n *pipeline.WindowNode, l *log.Logger) (*WindowNode, error) {
+	counted := n.PeriodCount != 0 || n.EveryCount != 0
+	timed := n.Period != 0 || n.Every != 0
+	switch {
+	case n.PeriodCount < 0 || n.EveryCount < 0:
+		return nil, errors.New("periodCount and everyCount must not be negative")
+	case n.EveryCount > 0 && n.PeriodCount == 0:
+		return nil, errors.New("everyCount requires periodCount")
+	case counted && timed:
+		return nil, errors.New("cannot use periodCount or everyCount with period or every")
+	case n.SessionGap > 0 && (counted || timed):
+		return nil, errors.New("cannot use session with period, every, periodCount or everyCount")
+	case n.Lateness < 0:
+		return nil, errors.New("lateness must not be negative")
+	case n.Lateness > 0 && (counted || n.SessionGap > 0):
+		return nil, errors.New("lateness can only be used with period and every")
+	}
+	for _, c := range n.Children() {
+		if _, ok := c.(*pipeline.LateNode); ok && n.Lateness == 0 {
+			return nil, errors.New("late can only be used with lateness")
+		}
+	}
+	sm := newNodeStatistics(et, n)
+	sm.Add(statLate, 0)
 	wn := &WindowNode{
-		w:    n,
-		node: node{Node: n, et: et, logger: l},
+		w:       n,
+		node:    node{Node: n, et: et, logger: l},
+		statMap: sm,
 	}
 	wn.node.runF = wn.runWindow
 	return wn, nil
@@ -27,6 +56,15 @@ func newWindowNode(et *ExecutingTask, n *pipeline.WindowNode, l *log.Logger) (*W
 
 func (w *WindowNode) runWindow([]byte) error {
 	windows := make(map[models.GroupID]*window)
+	// Late points are only sent to the side outputs of the window.
+	var outs, lateOuts []*Edge
+	for i, child := range w.children {
+		if _, ok := child.(*LateNode); ok {
+			lateOuts = append(lateOuts, w.outs[i])
+		} else {
+			outs = append(outs, w.outs[i])
+		}
+	}
 	// Loops through points windowing by group
 	for p, ok := w.ins[0].NextPoint(); ok; p, ok = w.ins[0].NextPoint() {
 		wnd := windows[p.Group]
@@ -39,26 +77,50 @@ func (w *WindowNode) runWindow([]byte) error {
 			if w.w.AlignFlag {
 				nextEmit = nextEmit.Truncate(w.w.Every)
 			}
+			everyCount := w.w.EveryCount
+			if everyCount == 0 {
+				everyCount = w.w.PeriodCount
+			}
 			wnd = &window{
-				buf:      &windowBuffer{logger: w.logger},
-				nextEmit: nextEmit,
-				period:   w.w.Period,
-				every:    w.w.Every,
-				name:     p.Name,
-				group:    p.Group,
-				tags:     tags,
-				logger:   w.logger,
+				buf:         &windowBuffer{logger: w.logger},
+				nextEmit:    nextEmit,
+				period:      w.w.Period,
+				every:       w.w.Every,
+				periodCount: int(w.w.PeriodCount),
+				everyCount:  int(everyCount),
+				lateness:    w.w.Lateness,
+				gap:         w.w.SessionGap,
+				name:        p.Name,
+				group:       p.Group,
+				tags:        tags,
+				logger:      w.logger,
 			}
 			windows[p.Group] = wnd
 		}
-		if !p.Time.Before(wnd.nextEmit) {
-			points := wnd.emit()
+		batches, late := wnd.collect(p)
+		if late {
+			w.statMap.Add(statLate, 1)
+			b := models.Batch{
+				Name:   p.Name,
+				Group:  p.Group,
+				Tags:   wnd.tags,
+				TMax:   p.Time,
+				Points: []models.BatchPoint{models.BatchPointFromPoint(p)},
+			}
+			for _, child := range lateOuts {
+				err := child.CollectBatch(b)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, points := range batches {
 			// Send window to all children
-			for _, child := range w.outs {
+			for _, child := range outs {
 				child.CollectBatch(points)
 			}
 		}
-		wnd.buf.insert(p)
 	}
 	return nil
 }
@@ -68,26 +130,105 @@ type window struct {
 	nextEmit time.Time
 	period   time.Duration
 	every    time.Duration
-	name     string
-	group    models.GroupID
-	tags     map[string]string
-	logger   *log.Logger
+	// The number of points in the window and the number of points between emits,
+	// both are zero unless the window is counted.
+	periodCount int
+	everyCount  int
+	// The number of points since the last emit of a counted window.
+	count int
+	// The lateness of the points and the newest time seen of a window with lateness.
+	lateness time.Duration
+	newest   time.Time
+	// The quiet period of a session window and the time of its last point.
+	gap    time.Duration
+	last   time.Time
+	name   string
+	group  models.GroupID
+	tags   map[string]string
+	logger *log.Logger
+}
+
+// Add a point to the window and return the batches to emit,
+// or true if the point is late and was not added.
+func (w *window) collect(p models.Point) ([]models.Batch, bool) {
+	switch {
+	case w.periodCount > 0:
+		w.buf.insert(p)
+		w.count++
+		if w.count < w.everyCount {
+			return nil, false
+		}
+		w.count = 0
+		w.buf.purgeCount(w.periodCount)
+		return []models.Batch{w.batch(p.Time)}, false
+	case w.gap > 0:
+		var batches []models.Batch
+		if w.buf.len() > 0 && p.Time.Sub(w.last) >= w.gap {
+			batches = append(batches, w.batch(w.last))
+			w.buf.purge(p.Time)
+		}
+		w.buf.insert(p)
+		w.last = p.Time
+		return batches, false
+	case w.lateness > 0:
+		// Points before the start of the oldest window that is still open
+		// belong only to windows that have been emitted.
+		if p.Time.Before(w.nextEmit.Add(-w.period)) {
+			return nil, true
+		}
+		w.buf.insertSorted(p)
+		if p.Time.After(w.newest) {
+			w.newest = p.Time
+		}
+		// Emit every window that ended before the watermark.
+		var batches []models.Batch
+		watermark := w.newest.Add(-w.lateness)
+		for !watermark.Before(w.nextEmit) {
+			batches = append(batches, w.emit())
+		}
+		return batches, false
+	default:
+		var batches []models.Batch
+		if !p.Time.Before(w.nextEmit) {
+			batches = append(batches, w.emit())
+		}
+		w.buf.insert(p)
+		return batches, false
+	}
 }
 
 func (w *window) emit() models.Batch {
 	oldest := w.nextEmit.Add(-1 * w.period)
 	w.buf.purge(oldest)
 
+	batch := w.batch(w.nextEmit)
+	if w.lateness > 0 {
+		// The buffer contains points after the end of the window.
+		batch.Points = pointsBefore(batch.Points, w.nextEmit)
+	}
+
+	w.nextEmit = w.nextEmit.Add(w.every)
+	return batch
+}
+
+// Returns the current buffer as a batch of the window.
+func (w *window) batch(tmax time.Time) models.Batch {
 	batch := w.buf.batch()
 	batch.Name = w.name
 	batch.Group = w.group
 	batch.Tags = w.tags
-	batch.TMax = w.nextEmit
-
-	w.nextEmit = w.nextEmit.Add(w.every)
+	batch.TMax = tmax
 	return batch
 }
 
+// Returns the points, sorted by time, that are before stop.
+func pointsBefore(points []models.BatchPoint, stop time.Time) []models.BatchPoint {
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(stop)
+	})
+	return points[:i]
+}
+
 // implements a purpose built ring buffer for the window of points
 type windowBuffer struct {
 	sync.Mutex
@@ -141,7 +282,29 @@ func (b *windowBuffer) insert(p models.Point) {
 	b.stop++
 }
 
+// Insert a single point into the buffer keeping the points sorted by time,
+// for points that can arrive out of order.
+func (b *windowBuffer) insertSorted(p models.Point) {
+	b.insert(p)
+	b.Lock()
+	defer b.Unlock()
+	l := len(b.window)
+	i := b.stop - 1
+	for n := 1; n < b.size; n++ {
+		j := i - 1
+		if j < 0 {
+			j = l - 1
+		}
+		if !b.window[j].Time.After(b.window[i].Time) {
+			break
+		}
+		b.window[i], b.window[j] = b.window[j], b.window[i]
+		i = j
+	}
+}
+
 // Purge expired data from the window.
+// The points in the buffer must be sorted by time.
 func (b *windowBuffer) purge(oldest time.Time) {
 	b.Lock()
 	defer b.Unlock()
@@ -175,6 +338,25 @@ func (b *windowBuffer) purge(oldest time.Time) {
 	}
 }
 
+// Purge the oldest points so that at most n points remain in the window.
+func (b *windowBuffer) purgeCount(n int) {
+	b.Lock()
+	defer b.Unlock()
+	for ; b.size > n; b.size-- {
+		b.start++
+		if b.start == len(b.window) && b.size > 1 {
+			b.start = 0
+		}
+	}
+}
+
+// Returns the number of points in the buffer.
+func (b *windowBuffer) len() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.size
+}
+
 // Returns a copy of the current buffer.
 func (b *windowBuffer) batch() models.Batch {
 	b.Lock()
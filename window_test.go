@@ -75,3 +75,133 @@ func TestWindowBuffer(t *testing.T) {
 		}
 	}
 }
+
+func TestWindowBuffer_PurgeCount(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := &windowBuffer{logger: logger}
+
+	// Keep the last 3 points while inserting, wrapping around the buffer.
+	for i := 1; i <= 20; i++ {
+		buf.insert(models.Point{Time: time.Unix(int64(i), 0)})
+		buf.purgeCount(3)
+
+		expected := 3
+		if i < 3 {
+			expected = i
+		}
+		assert.Equal(expected, buf.len(), "i: %d", i)
+		batch := buf.batch()
+		if assert.Equal(expected, len(batch.Points), "i: %d", i) {
+			for j, p := range batch.Points {
+				assert.Equal(time.Unix(int64(i-expected+j+1), 0), p.Time, "i: %d j: %d", i, j)
+			}
+		}
+	}
+
+	// Purge all points and reuse the buffer.
+	buf.purgeCount(0)
+	assert.Equal(0, buf.len())
+	assert.Equal(0, len(buf.batch().Points))
+	buf.insert(models.Point{Time: time.Unix(21, 0)})
+	batch := buf.batch()
+	if assert.Equal(1, len(batch.Points)) {
+		assert.Equal(time.Unix(21, 0), batch.Points[0].Time)
+	}
+}
+
+func TestWindow_LatenessOverlapping(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &window{
+		buf:      &windowBuffer{logger: logger},
+		nextEmit: time.Unix(5, 0),
+		period:   10 * time.Second,
+		every:    5 * time.Second,
+		lateness: 2 * time.Second,
+		logger:   logger,
+	}
+	collect := func(sec int64) ([]models.Batch, bool) {
+		return w.collect(models.Point{Time: time.Unix(sec, 0)})
+	}
+	times := func(b models.Batch) []int64 {
+		ts := make([]int64, len(b.Points))
+		for i, p := range b.Points {
+			ts[i] = p.Time.Unix()
+		}
+		return ts
+	}
+
+	for _, sec := range []int64{0, 1} {
+		batches, late := collect(sec)
+		assert.Empty(batches)
+		assert.False(late)
+	}
+	batches, late := collect(7)
+	assert.False(late)
+	if assert.Len(batches, 1) {
+		assert.Equal([]int64{0, 1}, times(batches[0]))
+	}
+
+	// The window [0s, 10s) is still open, so the point is not late.
+	batches, late = collect(3)
+	assert.Empty(batches)
+	assert.False(late)
+
+	batches, late = collect(13)
+	assert.False(late)
+	if assert.Len(batches, 1) {
+		assert.Equal([]int64{0, 1, 3, 7}, times(batches[0]))
+	}
+
+	// The oldest open window is now [5s, 15s).
+	_, late = collect(4)
+	assert.True(late)
+	_, late = collect(6)
+	assert.False(late)
+}
+
+func TestWindow_LatenessOutOfOrderWrap(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &window{
+		buf:      &windowBuffer{logger: logger},
+		nextEmit: time.Unix(10, 0),
+		period:   40 * time.Second,
+		every:    10 * time.Second,
+		lateness: 5 * time.Second,
+		logger:   logger,
+	}
+
+	// Deliver a point every second with every 7th point 4 points late,
+	// enough points for the ring buffer to wrap several times.
+	var order []int64
+	for i := int64(0); i < 196; i++ {
+		if i%7 != 0 {
+			order = append(order, i)
+		}
+		if i%7 == 4 {
+			order = append(order, i-4)
+		}
+	}
+
+	emitted := 0
+	for _, sec := range order {
+		batches, late := w.collect(models.Point{Time: time.Unix(sec, 0)})
+		assert.False(late, "sec: %d", sec)
+		for _, b := range batches {
+			emitted++
+			stop := b.TMax.Unix()
+			start := stop - 40
+			if start < 0 {
+				start = 0
+			}
+			if assert.Len(b.Points, int(stop-start), "window ending at %d", stop) {
+				for j, p := range b.Points {
+					assert.Equal(start+int64(j), p.Time.Unix(), "window ending at %d", stop)
+				}
+			}
+		}
+	}
+	assert.Equal(19, emitted)
+}